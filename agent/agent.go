@@ -12,58 +12,29 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"os"
 	"strings"
+	"time"
+
+	"github.com/emyjamalian/laas-ladybug/redact"
+	"github.com/emyjamalian/laas-ladybug/report"
+	"github.com/emyjamalian/laas-ladybug/telemetry"
+	"github.com/emyjamalian/laas-ladybug/tools"
 )
 
+// DefaultModel is the model used when IONOS_MODEL is not set.
+const DefaultModel = "meta-llama/Llama-3.3-70B-Instruct"
+
 const (
 	baseURL      = "https://openai.inference.de-txl.ionos.com/v1/chat/completions"
-	defaultModel = "meta-llama/Llama-3.3-70B-Instruct"
 	apiKeyEnvVar = "IONOS_API_KEY"
 )
 
-const systemPrompt = `You are the Fix Fast agent, inspired by Facebook's regression detection system.
-Your mission: analyze bugs and regressions through the Fix Fast framework, then produce an actionable report.
-
-The Fix Fast framework has four principles:
-1. SHIFT LEFT   — Detect problems as early as possible (IDE > local_test > CI > code_review > staging > production).
-                  A production bug costs 100x more than one caught in the IDE.
-2. SIGNAL QUALITY — Focus on meaningful, actionable signals. Reduce noise.
-3. FASTER ATTRIBUTION — Route issues to the right owner fast using the multisect principle.
-4. GET CLEAN, STAY CLEAN — Fix the root cause AND add safeguards to prevent recurrence.
-
-You MUST use ALL FOUR tools in order for every analysis:
-  Step 1: detect_regression  — identify regression type and severity
-  Step 2: triage_issue       — calculate CPD score, determine P0/P1/P2/P3 priority
-  Step 3: attribute_to_owner — find the highest-confidence owner/component
-  Step 4: generate_fix_plan  — produce the complete fix and prevention plan
-
-After all four tools have run, synthesize a final report in this structure:
-## Fix Fast Analysis Report
-
-### Detection
-[regression type, severity, confidence]
-
-### Triage (CPD Score)
-[CPD score, priority, cost rationale]
-
-### Attribution
-[component owner, confidence, signals]
-
-### Fix Plan
-[immediate actions, fix steps, estimated effort]
-
-### Shift Left Recommendations
-[how to catch this class of bug earlier next time]
-
-### Prevention
-[measures to prevent recurrence]
-
-Be direct, concrete, and actionable. Engineers need to act fast.`
-
 // chatMessage represents a single message in the conversation.
 type chatMessage struct {
 	Role       string     `json:"role"`
@@ -95,32 +66,80 @@ type chatResponse struct {
 		Message      chatMessage `json:"message"`
 		FinishReason string      `json:"finish_reason"`
 	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage,omitempty"`
 	Error *struct {
 		Message string `json:"message"`
 		Type    string `json:"type"`
 	} `json:"error,omitempty"`
 }
 
-// Agent wraps the HTTP client and tool definitions.
+// Hooks observe tool calls as the agent runs. Either may be nil.
+type Hooks struct {
+	ToolStarted  func(name, args string)
+	ToolFinished func(name, args, result string, err error)
+}
+
+// Agent wraps the HTTP client and tool registry.
 type Agent struct {
-	apiKey string
-	model  string
-	http   *http.Client
-	tools  []toolDef
+	apiKey   string
+	model    string
+	http     *http.Client
+	tools    *Registry
+	hooks    Hooks
+	log      *slog.Logger
+	redactor *redact.Redactor
 }
 
 // New creates a new Fix Fast agent. Reads IONOS_API_KEY from the environment.
+// Its tools are the built-in Fix Fast tools, the optional tools enabled by
+// the tools package configuration, and any added with RegisterTool. Tool
+// calls are limited to DefaultToolTimeout unless changed with SetToolTimeout.
+// It logs to slog.Default and scrubs what it sends the model with
+// redact.Default. LADYBUG_REPLAY or LADYBUG_RECORD name a directory to
+// replay model responses from or record them in (see Replay and Record).
 func New() *Agent {
-	model := defaultModel
+	model := DefaultModel
 	if m := os.Getenv("IONOS_MODEL"); m != "" {
 		model = m
 	}
-	return &Agent{
-		apiKey: os.Getenv(apiKeyEnvVar),
-		model:  model,
-		http:   &http.Client{},
-		tools:  allTools(),
+	a := &Agent{
+		apiKey:   os.Getenv(apiKeyEnvVar),
+		model:    model,
+		http:     &http.Client{},
+		tools:    newRegistry(DefaultToolTimeout, builtinTimeouts),
+		log:      slog.Default(),
+		redactor: redact.Default(),
+	}
+	if dir := os.Getenv("LADYBUG_REPLAY"); dir != "" {
+		a.Replay(dir)
+	} else if dir := os.Getenv("LADYBUG_RECORD"); dir != "" {
+		a.Record(dir)
+	}
+	for name, d := range registeredTimeouts() {
+		a.tools.SetTimeout(name, d)
+	}
+	var builtin []Tool
+	for _, d := range allTools() {
+		builtin = append(builtin, d)
+	}
+	// propose_patch and the browsing tools need a checkout.
+	if tools.RepoRoot() != "" {
+		for _, d := range repoTools() {
+			builtin = append(builtin, d)
+		}
+		builtin = append(builtin, proposePatchTool(a.complete))
+	}
+	if tools.RepoRoot() != "" || tools.CurrentDeployConfig() != nil {
+		builtin = append(builtin, planRollbackTool())
+	}
+	for _, t := range append(builtin, registeredTools()...) {
+		// Names were checked by RegisterTool, so this cannot fail.
+		_ = a.tools.Register(t)
 	}
+	return a
 }
 
 // NewWithKey creates a new Fix Fast agent with an explicit API key.
@@ -130,27 +149,135 @@ func NewWithKey(apiKey string) *Agent {
 	return a
 }
 
+// SetModel overrides the model ID chosen by New.
+func (a *Agent) SetModel(model string) {
+	a.model = model
+}
+
+// Tools returns the agent's tool registry.
+func (a *Agent) Tools() *Registry {
+	return a.tools
+}
+
+// Register adds a tool to this agent only.
+func (a *Agent) Register(t Tool) error {
+	return a.tools.Register(t)
+}
+
+// WithTools returns a copy of the agent restricted to the tools matching
+// allow (all if empty) and not matching deny, for a single run. The
+// original agent is unchanged.
+func (a *Agent) WithTools(allow, deny []string) (*Agent, error) {
+	if len(allow) == 0 && len(deny) == 0 {
+		return a, nil
+	}
+	reg, err := a.tools.Filter(allow, deny)
+	if err != nil {
+		return nil, err
+	}
+	b := *a
+	b.tools = reg
+	return &b, nil
+}
+
+// SystemPrompt returns the system prompt for the agent's current tools.
+func (a *Agent) SystemPrompt() string {
+	return buildSystemPrompt(a.tools)
+}
+
+// SetLogger replaces the logger chosen by New.
+func (a *Agent) SetLogger(l *slog.Logger) {
+	a.log = l
+}
+
+// SetRedactor replaces the redactor chosen by New; nil sends text to the
+// model unredacted.
+func (a *Agent) SetRedactor(r *redact.Redactor) {
+	a.redactor = r
+}
+
+// SetHooks installs tool-call observers, e.g. for a live view.
+func (a *Agent) SetHooks(h Hooks) {
+	a.hooks = h
+}
+
 // Run executes the Fix Fast analysis for the given bug report or diff.
-// Streams progress to w and returns the final analysis text.
+// Streams progress to w and returns the final analysis text. Cancelling ctx
+// stops the model request or tool call in progress and ends the run.
 func (a *Agent) Run(ctx context.Context, input string, w io.Writer) (string, error) {
-	if a.apiKey == "" {
-		return "", fmt.Errorf("%s environment variable is not set", apiKeyEnvVar)
+	rep, err := a.Analyze(ctx, input, w)
+	if err != nil {
+		return "", err
 	}
+	return rep.Summary, nil
+}
 
-	strPtr := func(s string) *string { return &s }
-
-	messages := []chatMessage{
-		{Role: "system", Content: strPtr(systemPrompt)},
-		{Role: "user", Content: strPtr(input)},
+// Analyze runs the same analysis as Run but returns the structured report,
+// including every tool's input and output.
+func (a *Agent) Analyze(ctx context.Context, input string, w io.Writer) (*report.Report, error) {
+	s, err := a.Start(ctx, input, w)
+	if err != nil {
+		return nil, err
 	}
+	return s.Report, nil
+}
 
+// Start runs the analysis and returns it as a session, so follow-up
+// questions can be asked with Ask.
+func (a *Agent) Start(ctx context.Context, input string, w io.Writer) (s *Session, err error) {
+	if a.apiKey == "" {
+		return nil, fmt.Errorf("%s environment variable is not set", apiKeyEnvVar)
+	}
+	ctx, span := telemetry.Start(ctx, "analyze", telemetry.String("gen_ai.request.model", a.model))
+	began := time.Now()
+	defer func() {
+		analysisDuration.Observe(time.Since(began).Seconds(), outcome(err))
+		span.RecordError(err)
+		span.End()
+	}()
+
+	input, findings := a.redactor.Redact("input", input)
+	s = newSession(a.model, a.SystemPrompt(), input)
+	a.audit(ctx, s, findings)
+	for _, t := range a.tools.Tools() {
+		s.Tools = append(s.Tools, t.Name())
+	}
 	fmt.Fprintln(w, "\n--- Fix Fast Agent Running ---")
+	text, err := a.converse(ctx, s, w)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintln(w, "\n\n--- Analysis Complete ---")
+	s.Report.Summary = text
+
+	rep := s.Report
+	a.logger(ctx).Info("analysis complete", "priority", rep.Priority(), "regression_type", rep.RegressionType(),
+		"component", rep.Component(), "duration", time.Since(began).Round(time.Millisecond))
+	analysesTotal.Inc(orNone(rep.Priority()), rep.RegressionType())
+	span.SetAttributes(
+		telemetry.String("ladybug.priority", rep.Priority()),
+		telemetry.String("ladybug.regression_type", rep.RegressionType()),
+		telemetry.String("ladybug.severity", rep.Severity()),
+		telemetry.String("ladybug.component", rep.Component()),
+	)
+	return s, nil
+}
 
+// converse runs the agentic loop on the session's conversation until the
+// model stops calling tools, recording tool results in the session's report.
+// It returns the text the model produced along the way.
+func (a *Agent) converse(ctx context.Context, s *Session, w io.Writer) (string, error) {
 	var finalText strings.Builder
+	iterations := 0
+	defer func() {
+		agentIterations.Observe(float64(iterations))
+		telemetry.SpanFromContext(ctx).SetAttributes(telemetry.Int("ladybug.iterations", iterations))
+	}()
 
 	// Agentic loop: keep going until the model stops calling tools.
 	for {
-		resp, err := a.call(ctx, messages)
+		iterations++
+		resp, err := a.call(ctx, s.Messages)
 		if err != nil {
 			return "", err
 		}
@@ -169,11 +296,11 @@ func (a *Agent) Run(ctx context.Context, input string, w io.Writer) (string, err
 		}
 
 		// Append assistant turn to history.
-		messages = append(messages, msg)
+		s.Messages = append(s.Messages, msg)
 
 		// No tool calls → model is done.
 		if finishReason == "stop" || len(msg.ToolCalls) == 0 {
-			fmt.Fprintln(w, "\n\n--- Analysis Complete ---")
+			s.UpdatedAt = time.Now().UTC()
 			return finalText.String(), nil
 		}
 
@@ -181,40 +308,124 @@ func (a *Agent) Run(ctx context.Context, input string, w io.Writer) (string, err
 		for _, tc := range msg.ToolCalls {
 			fmt.Fprintf(w, "\n\n[tool: %s]\n", tc.Function.Name)
 
-			result, toolErr := dispatch(a.tools, tc.Function.Name, tc.Function.Arguments)
+			if a.hooks.ToolStarted != nil {
+				a.hooks.ToolStarted(tc.Function.Name, tc.Function.Arguments)
+			}
+			result, toolErr := a.tools.dispatch(tools.WithAttribution(ctx, s.Report.Attribution), tc.Function.Name, tc.Function.Arguments)
+			field := "tool:" + tc.Function.Name
+			// The report keeps the raw result: redaction patterns also match
+			// ordinary code, and a scrubbed patch no longer applies. Only
+			// what is shown and sent to the model is redacted.
+			sent := result
+			if toolErr != nil {
+				a.logger(ctx).Warn("tool call failed", "tool", tc.Function.Name, "outcome", outcome(toolErr), "err", toolErr)
+			} else {
+				a.logger(ctx).Debug("tool call", "tool", tc.Function.Name)
+				sent = a.scrub(ctx, s, field, result)
+			}
+			if a.hooks.ToolFinished != nil {
+				a.hooks.ToolFinished(tc.Function.Name, tc.Function.Arguments, sent, toolErr)
+			}
 			toolCallID := tc.ID
 
 			var content string
 			if toolErr != nil {
 				fmt.Fprintf(w, "[tool error: %v]\n", toolErr)
-				content = fmt.Sprintf("error: %v", toolErr)
+				content = a.scrub(ctx, s, field, toolErrorContent(tc.Function.Name, toolErr))
 			} else {
 				// Pretty-print for readability.
 				var pretty interface{}
-				if jsonErr := json.Unmarshal([]byte(result), &pretty); jsonErr == nil {
+				if jsonErr := json.Unmarshal([]byte(sent), &pretty); jsonErr == nil {
 					prettyBytes, _ := json.MarshalIndent(pretty, "", "  ")
 					fmt.Fprintf(w, "%s\n", string(prettyBytes))
 				}
-				content = result
+				content = sent
+				if recErr := s.Report.Record(tc.Function.Name, tc.Function.Arguments, result); recErr != nil {
+					fmt.Fprintf(w, "[report: %v]\n", recErr)
+				}
 			}
 
-			messages = append(messages, chatMessage{
+			s.Messages = append(s.Messages, chatMessage{
 				Role:       "tool",
 				ToolCallID: toolCallID,
 				Content:    &content,
 			})
+			if ctx.Err() != nil {
+				return "", fmt.Errorf("analysis cancelled: %w", ctx.Err())
+			}
 		}
 	}
 }
 
 // call sends a single chat completions request to the IONOS Model Hub.
 func (a *Agent) call(ctx context.Context, messages []chatMessage) (*chatResponse, error) {
-	req := chatRequest{
+	return a.send(ctx, chatRequest{
 		Model:     a.model,
 		Messages:  messages,
-		Tools:     toolParams(a.tools),
+		Tools:     a.tools.definitions(),
 		MaxTokens: 8192,
+	})
+}
+
+// complete sends a one-off, tool-less prompt and returns the reply text.
+// Tools that need the model themselves (e.g. propose_patch) use it.
+func (a *Agent) complete(ctx context.Context, system, prompt string) (string, error) {
+	prompt = a.scrub(ctx, nil, "completion", prompt)
+	resp, err := a.send(ctx, chatRequest{
+		Model: a.model,
+		Messages: []chatMessage{
+			{Role: "system", Content: &system},
+			{Role: "user", Content: &prompt},
+		},
+		MaxTokens: 4096,
+	})
+	if err != nil {
+		return "", err
 	}
+	if len(resp.Choices) == 0 || resp.Choices[0].Message.Content == nil {
+		return "", fmt.Errorf("empty response from API")
+	}
+	return *resp.Choices[0].Message.Content, nil
+}
+
+// send posts one chat completions request, traced as a "chat" span with
+// the model, finish reason and token usage.
+func (a *Agent) send(ctx context.Context, req chatRequest) (resp *chatResponse, err error) {
+	ctx, span := telemetry.Start(ctx, "chat "+req.Model,
+		telemetry.String("gen_ai.operation.name", "chat"),
+		telemetry.String("gen_ai.system", "ionos"),
+		telemetry.String("gen_ai.request.model", req.Model),
+		telemetry.Int("gen_ai.request.max_tokens", req.MaxTokens),
+		telemetry.Int("ladybug.tools_offered", len(req.Tools)),
+	)
+	began := time.Now()
+	defer func() {
+		elapsed := time.Since(began)
+		modelDuration.Observe(elapsed.Seconds(), req.Model, outcome(err))
+		log := a.logger(ctx).With("model", req.Model, "duration", elapsed.Round(time.Millisecond))
+		if err != nil {
+			log.Warn("model request failed", "err", err)
+		}
+		if resp != nil {
+			var reasons []string
+			for _, c := range resp.Choices {
+				reasons = append(reasons, c.FinishReason)
+			}
+			span.SetAttributes(telemetry.Strings("gen_ai.response.finish_reasons", reasons))
+			if u := resp.Usage; u != nil {
+				log = log.With("input_tokens", u.PromptTokens, "output_tokens", u.CompletionTokens)
+				modelTokens.Add(float64(u.PromptTokens), req.Model, "input")
+				modelTokens.Add(float64(u.CompletionTokens), req.Model, "output")
+				span.SetAttributes(
+					telemetry.Int("gen_ai.usage.input_tokens", u.PromptTokens),
+					telemetry.Int("gen_ai.usage.output_tokens", u.CompletionTokens),
+				)
+			}
+			log.Debug("model request", "finish_reasons", reasons)
+		}
+		span.RecordError(err)
+		span.End()
+	}()
 
 	body, err := json.Marshal(req)
 	if err != nil {
@@ -254,3 +465,57 @@ func (a *Agent) call(ctx context.Context, messages []chatMessage) (*chatResponse
 
 	return &chatResp, nil
 }
+
+// toolErrorContent is what the model sees when a tool call fails: JSON
+// whose status tells a timeout, a cancellation and a tool failure apart.
+// Invalid input names each bad field and its allowed values, so the model
+// can correct the call and retry.
+func toolErrorContent(tool string, err error) string {
+	body := struct {
+		Status        ToolOutcome        `json:"status"`
+		Error         string             `json:"error"`
+		InvalidFields []tools.FieldError `json:"invalid_fields,omitempty"`
+		Hint          string             `json:"hint,omitempty"`
+	}{Status: ToolFailed, Error: err.Error()}
+	var terr *ToolError
+	if errors.As(err, &terr) {
+		body.Status = terr.Outcome
+	}
+	var verr *tools.ValidationError
+	switch {
+	case body.Status == ToolTimedOut:
+		body.Hint = "Do not repeat the same call. Narrow the input or continue without " + tool + "."
+	case body.Status == ToolCancelled:
+		body.Hint = "The run was cancelled."
+	case errors.As(err, &verr):
+		body.Error, body.InvalidFields = "invalid input", verr.Fields
+		body.Hint = "Correct these fields and call " + tool + " again."
+	}
+	data, _ := json.Marshal(body)
+	return string(data)
+}
+
+// logger returns the agent's logger, tagged with the trace of ctx if any.
+func (a *Agent) logger(ctx context.Context) *slog.Logger {
+	if id := telemetry.SpanFromContext(ctx).TraceID(); id != "" {
+		return a.log.With("trace_id", id)
+	}
+	return a.log
+}
+
+// scrub redacts text bound for the model and audits what it removed in
+// the session's report (if s is not nil) and the log.
+func (a *Agent) scrub(ctx context.Context, s *Session, field, text string) string {
+	text, findings := a.redactor.Redact(field, text)
+	a.audit(ctx, s, findings)
+	return text
+}
+
+func (a *Agent) audit(ctx context.Context, s *Session, findings []redact.Finding) {
+	for _, f := range findings {
+		a.logger(ctx).Info("redacted sensitive data", "field", f.Field, "rule", f.Rule, "count", f.Count)
+	}
+	if s != nil && len(findings) > 0 {
+		s.Report.Redactions = redact.Merge(s.Report.Redactions, findings)
+	}
+}
@@ -0,0 +1,59 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/emyjamalian/laas-ladybug/tools"
+)
+
+func TestPatchSurvivesRedaction(t *testing.T) {
+	hermetic(t)
+	patch := "--- a/auth/client.go\n+++ b/auth/client.go\n@@ -10,3 +10,4 @@ func (c *Client) Login(req *http.Request) {\n" +
+		" \tc.user = req.Header.Get(\"X-User\")\n" +
+		"+\tc.token = req.Header.Get(\"X-Token\")\n" +
+		"+\tc.password = cfg.Password\n" +
+		" }\n"
+	out, err := json.Marshal(tools.ProposePatchOutput{File: "auth/client.go", Patch: patch, Files: []string{"auth/client.go"}, Valid: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := New()
+	a.SetLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err := a.Register(NewTool("propose_patch", "Returns a fixed patch.", tools.Schema(tools.ProposePatchInput{}),
+		func(ctx context.Context, inputJSON string) (string, error) { return string(out), nil })); err != nil {
+		t.Fatal(err)
+	}
+	a.apiKey = "test"
+	var turns []scriptTurn
+	if err := json.Unmarshal([]byte(`[
+		{"tool_calls": [{"name": "propose_patch", "arguments": {"file": "auth/client.go", "regression_type": "null_pointer", "root_cause": "token is never read"}}]},
+		{"content": "Patch proposed."}
+	]`), &turns); err != nil {
+		t.Fatal(err)
+	}
+	a.http = &http.Client{Transport: &script{turns: turns}}
+
+	s, err := a.Start(context.Background(), "login fails after the token change", io.Discard)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if s.Report.Patch == nil || s.Report.Patch.Patch != patch {
+		t.Errorf("report patch = %+v, want it stored unredacted:\n%s", s.Report.Patch, patch)
+	}
+	// The model still only sees the redacted copy.
+	for _, m := range s.Messages {
+		if m.Role == "tool" && m.Content != nil && !strings.Contains(*m.Content, "[REDACTED:api_key]") {
+			t.Errorf("tool result sent to the model = %s, want the token assignment redacted", *m.Content)
+		}
+	}
+	if len(s.Report.Redactions) == 0 {
+		t.Error("report does not audit the redaction of the tool result")
+	}
+}
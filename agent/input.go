@@ -0,0 +1,30 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/emyjamalian/laas-ladybug/tools"
+)
+
+// FormatInput renders a structured detect_regression input as the free-text
+// prompt the agent expects, so machine-generated reports (webhooks, batch files)
+// go through the same analysis as a human-written description.
+func FormatInput(in tools.DetectRegressionInput) string {
+	var b strings.Builder
+	if in.Environment != "" {
+		fmt.Fprintf(&b, "[Detected in: %s]\n\n", in.Environment)
+	}
+	b.WriteString(strings.TrimSpace(in.Description))
+	b.WriteString("\n")
+	if len(in.FilesChanged) > 0 {
+		fmt.Fprintf(&b, "\nFiles changed: %s\n", strings.Join(in.FilesChanged, ", "))
+	}
+	if len(in.RunHistory) > 0 {
+		fmt.Fprintf(&b, "\nRun history (oldest first): %s\n", strings.Join(in.RunHistory, ", "))
+	}
+	if in.ErrorMessage != "" {
+		fmt.Fprintf(&b, "\nError message:\n%s\n", strings.TrimSpace(in.ErrorMessage))
+	}
+	return b.String()
+}
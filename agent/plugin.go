@@ -0,0 +1,169 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/emyjamalian/laas-ladybug/tools"
+)
+
+// External tool plugins are executables speaking JSON over stdin/stdout.
+//
+// Run with --describe, a plugin prints the tools it provides:
+//
+//	[{"name": "query_incidents", "description": "...", "parameters": {JSON Schema}}]
+//
+// To call a tool, the plugin is run without arguments and given
+//
+//	{"tool": "query_incidents", "input": {the model's arguments}}
+//
+// on stdin. It replies on stdout with {"output": <any JSON>} or
+// {"error": "message"}. A non-zero exit status is a failure; stderr is
+// included in the error.
+
+// pluginDescribeTimeout bounds a plugin's --describe run. Calls are bounded
+// by the registry's tool timeouts.
+const pluginDescribeTimeout = 30 * time.Second
+
+type pluginTool struct {
+	path        string
+	name        string
+	description string
+	schema      map[string]interface{}
+}
+
+func (t pluginTool) Name() string                   { return t.name }
+func (t pluginTool) Description() string            { return t.description }
+func (t pluginTool) Schema() map[string]interface{} { return t.schema }
+
+func (t pluginTool) Call(ctx context.Context, inputJSON string) (string, error) {
+	if strings.TrimSpace(inputJSON) == "" {
+		inputJSON = "{}"
+	}
+	if err := tools.ValidateInput(t.schema, inputJSON); err != nil {
+		return "", err
+	}
+	req, err := json.Marshal(struct {
+		Tool  string          `json:"tool"`
+		Input json.RawMessage `json:"input"`
+	}{t.name, json.RawMessage(inputJSON)})
+	if err != nil {
+		return "", fmt.Errorf("invalid input: %w", err)
+	}
+	out, err := runPlugin(ctx, t.path, req)
+	if err != nil {
+		return "", err
+	}
+	var resp struct {
+		Output json.RawMessage `json:"output"`
+		Error  string          `json:"error"`
+	}
+	if err := json.Unmarshal(out, &resp); err != nil {
+		return "", fmt.Errorf("plugin %s: bad response: %w", filepath.Base(t.path), err)
+	}
+	if resp.Error != "" {
+		return "", fmt.Errorf("%s", resp.Error)
+	}
+	if len(resp.Output) == 0 {
+		return "null", nil
+	}
+	return string(resp.Output), nil
+}
+
+func runPlugin(ctx context.Context, path string, stdin []byte, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, path, args...)
+	// Don't wait on children that outlive a killed plugin.
+	cmd.WaitDelay = time.Second
+	cmd.Stdin = bytes.NewReader(stdin)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("plugin %s: %w", filepath.Base(path), ctx.Err())
+	}
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return nil, fmt.Errorf("plugin %s: %s", filepath.Base(path), msg)
+	}
+	return out, nil
+}
+
+// LoadPlugin runs the executable at path with --describe and returns the
+// tools it provides.
+func LoadPlugin(path string) ([]Tool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), pluginDescribeTimeout)
+	defer cancel()
+	out, err := runPlugin(ctx, path, nil, "--describe")
+	if errors.Is(err, context.DeadlineExceeded) {
+		return nil, fmt.Errorf("plugin %s: --describe timed out after %s", filepath.Base(path), pluginDescribeTimeout)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var descs []struct {
+		Name        string                 `json:"name"`
+		Description string                 `json:"description"`
+		Parameters  map[string]interface{} `json:"parameters"`
+	}
+	if err := json.Unmarshal(out, &descs); err != nil {
+		return nil, fmt.Errorf("plugin %s: bad --describe output: %w", filepath.Base(path), err)
+	}
+	var tools []Tool
+	for _, d := range descs {
+		if !validToolName.MatchString(d.Name) || d.Description == "" {
+			return nil, fmt.Errorf("plugin %s: tool %q needs a valid name and a description", filepath.Base(path), d.Name)
+		}
+		if d.Parameters == nil {
+			d.Parameters = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+		}
+		tools = append(tools, pluginTool{path: path, name: d.Name, description: d.Description, schema: d.Parameters})
+	}
+	return tools, nil
+}
+
+// LoadPlugins loads every plugin named in a PATH-style list. Directories in
+// the list contribute each executable file they contain.
+func LoadPlugins(list string) ([]Tool, error) {
+	var tools []Tool
+	for _, p := range filepath.SplitList(list) {
+		if p == "" {
+			continue
+		}
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, fmt.Errorf("plugin: %w", err)
+		}
+		paths := []string{p}
+		if info.IsDir() {
+			paths = nil
+			entries, err := os.ReadDir(p)
+			if err != nil {
+				return nil, fmt.Errorf("plugin: %w", err)
+			}
+			for _, e := range entries {
+				if fi, err := e.Info(); err == nil && fi.Mode().IsRegular() && fi.Mode()&0o111 != 0 {
+					paths = append(paths, filepath.Join(p, e.Name()))
+				}
+			}
+		}
+		for _, path := range paths {
+			t, err := LoadPlugin(path)
+			if err != nil {
+				return nil, err
+			}
+			tools = append(tools, t...)
+		}
+	}
+	return tools, nil
+}
@@ -0,0 +1,117 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+)
+
+const promptIntro = `You are the Fix Fast agent, inspired by Facebook's regression detection system.
+Your mission: analyze bugs and regressions through the Fix Fast framework, then produce an actionable report.
+
+The Fix Fast framework has four principles:
+1. SHIFT LEFT   — Detect problems as early as possible (IDE > local_test > CI > code_review > staging > production).
+                  A production bug costs 100x more than one caught in the IDE.
+2. SIGNAL QUALITY — Focus on meaningful, actionable signals. Reduce noise.
+3. FASTER ATTRIBUTION — Route issues to the right owner fast using the multisect principle.
+4. GET CLEAN, STAY CLEAN — Fix the root cause AND add safeguards to prevent recurrence.`
+
+// coreSteps are the Fix Fast pipeline tools, in the order they must run.
+var coreSteps = [][2]string{
+	{"detect_regression", "identify regression type and severity"},
+	{"triage_issue", "calculate CPD score, determine P0/P1/P2/P3 priority"},
+	{"attribute_to_owner", "find the highest-confidence owner/component"},
+	{"generate_fix_plan", "produce the complete fix and prevention plan"},
+}
+
+// toolGuidance says when to use an optional tool. It is only included when
+// the tools it names are registered.
+var toolGuidance = []struct {
+	tools []string
+	text  string
+}{
+	{[]string{"read_file", "search_code", "list_dir", "git_log"}, `If the read_file, search_code, list_dir and git_log tools are available, use them between
+attribute_to_owner and generate_fix_plan: open the code around the failing frame, confirm the root cause
+instead of guessing, and cite exact path:line references in the report.`},
+	{[]string{"propose_patch"}, `If the propose_patch tool is available, call it after attribute_to_owner and generate_fix_plan with
+the failing file/line (or stack trace) and the root cause. Include the patch in the report only if it
+comes back valid.`},
+	{[]string{"plan_rollback"}, `If the plan_rollback tool is available and the introducing commit or release is known, call it for
+P0/P1 regressions and put its commands in the Fix Plan. Never claim a rollback was performed.`},
+	{[]string{"generate_repro_test"}, `When a stack trace or failing file is known, call generate_repro_test after generate_fix_plan so the
+reproduce step starts from a failing test; mention its test_path in the Fix Plan.`},
+}
+
+const promptReport = `Then synthesize a final report in this structure:
+## Fix Fast Analysis Report
+
+### Detection
+[regression type, severity, confidence]
+
+### Triage (CPD Score)
+[CPD score, priority, cost rationale]
+
+### Attribution
+[component owner, confidence, signals]
+
+### Fix Plan
+[immediate actions, fix steps, estimated effort, the reproduction test path, and the proposed patch if one was validated]
+
+### Shift Left Recommendations
+[the earliest realistic catch stage from triage, the savings along the shift-left chain, and how to catch this class of bug there next time]
+
+### Prevention
+[measures to prevent recurrence]
+
+Leave out sections whose tool is not available rather than guessing their content.
+
+After the report the user may ask follow-up questions ("why this component?", "re-plan assuming
+this is a flake"). Answer them directly from the tool results above. Call a tool again only when the
+question changes one of its inputs, and do not repeat the whole report unless asked.
+
+Be direct, concrete, and actionable. Engineers need to act fast.`
+
+// buildSystemPrompt describes the workflow for the tools in reg: the core
+// pipeline steps that are registered, guidance for the optional tools, and
+// a list of any other tools (e.g. team plugins).
+func buildSystemPrompt(reg *Registry) string {
+	var b strings.Builder
+	b.WriteString(promptIntro)
+
+	covered := map[string]bool{}
+	var steps []string
+	for _, s := range coreSteps {
+		covered[s[0]] = true
+		if reg.Has(s[0]) {
+			steps = append(steps, fmt.Sprintf("  Step %d: %-18s — %s", len(steps)+1, s[0], s[1]))
+		}
+	}
+	if len(steps) > 0 {
+		b.WriteString("\n\nYou MUST use these tools in order for every analysis:\n")
+		b.WriteString(strings.Join(steps, "\n"))
+	}
+
+	for _, g := range toolGuidance {
+		present := false
+		for _, name := range g.tools {
+			covered[name] = true
+			present = present || reg.Has(name)
+		}
+		if present {
+			b.WriteString("\n\n" + g.text)
+		}
+	}
+
+	var others []string
+	for _, t := range reg.Tools() {
+		if !covered[t.Name()] {
+			others = append(others, "  - "+t.Name()+": "+t.Description())
+		}
+	}
+	if len(others) > 0 {
+		b.WriteString("\n\nThese additional tools are available; call them whenever their description fits the analysis:\n")
+		b.WriteString(strings.Join(others, "\n"))
+	}
+
+	b.WriteString("\n\n" + promptReport)
+	return b.String()
+}
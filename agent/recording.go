@@ -0,0 +1,149 @@
+package agent
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// Recording is one model request and the API's response, as saved by
+// Recorder. Request is the chat completions request body; the API key is
+// never recorded.
+type Recording struct {
+	Request  json.RawMessage `json:"request"`
+	Status   int             `json:"status"`
+	Response json.RawMessage `json:"response"`
+}
+
+// RequestHash identifies a chat completions request body: recordings are
+// saved as <hash>.json and replayed by it. Requests only match if the
+// model, tools and every message, including tool results, are identical.
+func RequestHash(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Recorder is an http.RoundTripper that saves each model request and its
+// response in Dir as a Recording, for Replayer to serve back later.
+type Recorder struct {
+	Dir string
+	// Next sends the requests; nil means http.DefaultTransport.
+	Next http.RoundTripper
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	next := r.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	resp, err := next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	rec := Recording{Request: body, Status: resp.StatusCode, Response: respBody}
+	if !json.Valid(respBody) {
+		// Keep error pages readable, and the file valid JSON.
+		rec.Response, _ = json.Marshal(string(respBody))
+	}
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("record model request: %w", err)
+	}
+	if err := os.MkdirAll(r.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("record model request: %w", err)
+	}
+	path := filepath.Join(r.Dir, RequestHash(body)+".json")
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return nil, fmt.Errorf("record model request: %w", err)
+	}
+	return resp, nil
+}
+
+// Replayer is an http.RoundTripper that answers model requests with the
+// responses Recorder saved in Dir, without calling the API. A request with
+// no recording fails, naming the hash it was looked up by.
+type Replayer struct {
+	Dir string
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	hash := RequestHash(body)
+	data, err := os.ReadFile(filepath.Join(r.Dir, hash+".json"))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no recorded response for request %s in %s (the conversation diverged from the recording; re-record it)", hash, r.Dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rec Recording
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("recording %s: %w", hash, err)
+	}
+	respBody := []byte(rec.Response)
+	var text string
+	if json.Unmarshal(rec.Response, &text) == nil {
+		respBody = []byte(text)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", rec.Status, http.StatusText(rec.Status)),
+		StatusCode:    rec.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(respBody)),
+		ContentLength: int64(len(respBody)),
+		Request:       req,
+	}, nil
+}
+
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// Record saves every model request the agent makes, and the response, in
+// dir.
+func (a *Agent) Record(dir string) {
+	a.http = &http.Client{Transport: &Recorder{Dir: dir, Next: a.http.Transport}}
+}
+
+// Replay answers the agent's model requests from the recordings in dir
+// instead of the API, so no API key is needed. Runs are deterministic as
+// long as the tools return what they did when recording; time-aware triage
+// reads the clock, which tools.SetClock pins.
+func (a *Agent) Replay(dir string) {
+	a.http = &http.Client{Transport: &Replayer{Dir: dir}}
+	if a.apiKey == "" {
+		a.apiKey = "replay"
+	}
+}
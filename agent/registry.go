@@ -0,0 +1,388 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emyjamalian/laas-ladybug/telemetry"
+	"github.com/emyjamalian/laas-ladybug/tools"
+)
+
+// Tool is a function the model can call. Built-in Fix Fast tools, tools
+// added through the Go API and external plugins all implement it.
+type Tool interface {
+	Name() string
+	// Description tells the model what the tool does and when to call it.
+	Description() string
+	// Schema is the JSON Schema of the tool's input object.
+	Schema() map[string]interface{}
+	// Call runs the tool with the model's JSON arguments and returns a JSON
+	// result. ctx carries the tool's timeout and the run's cancellation; a
+	// tool that blocks should return once it is done.
+	Call(ctx context.Context, inputJSON string) (string, error)
+}
+
+// DefaultToolTimeout limits a tool call unless the tool has its own limit.
+const DefaultToolTimeout = 30 * time.Second
+
+// ToolOutcome classifies a tool call that produced no result.
+type ToolOutcome string
+
+const (
+	// ToolTimedOut means the call exceeded the tool's timeout.
+	ToolTimedOut ToolOutcome = "timeout"
+	// ToolCancelled means the run was cancelled while the tool was running.
+	ToolCancelled ToolOutcome = "cancelled"
+	// ToolFailed means the tool returned an error.
+	ToolFailed ToolOutcome = "failed"
+)
+
+// ToolError is returned by a tool call that did not produce a result.
+type ToolError struct {
+	Tool    string
+	Outcome ToolOutcome
+	// Timeout is the limit that was exceeded, for ToolTimedOut.
+	Timeout time.Duration
+	Err     error
+}
+
+func (e *ToolError) Error() string {
+	switch e.Outcome {
+	case ToolTimedOut:
+		return fmt.Sprintf("%s timed out after %s", e.Tool, e.Timeout)
+	case ToolCancelled:
+		return e.Tool + " was cancelled"
+	}
+	return e.Err.Error()
+}
+
+func (e *ToolError) Unwrap() error { return e.Err }
+
+// NewTool wraps a Go function as a Tool. The schema is usually generated
+// from the tool's input struct with tools.Schema.
+func NewTool(name, description string, schema map[string]interface{}, fn func(ctx context.Context, inputJSON string) (string, error)) Tool {
+	return funcTool{name: name, description: description, schema: schema, fn: fn}
+}
+
+type funcTool struct {
+	name, description string
+	schema            map[string]interface{}
+	fn                func(context.Context, string) (string, error)
+}
+
+func (t funcTool) Name() string                   { return t.name }
+func (t funcTool) Description() string            { return t.description }
+func (t funcTool) Schema() map[string]interface{} { return t.schema }
+func (t funcTool) Call(ctx context.Context, in string) (string, error) {
+	if err := tools.ValidateInput(t.schema, in); err != nil {
+		return "", err
+	}
+	return t.fn(ctx, in)
+}
+
+// validToolName is what OpenAI-compatible APIs accept as a function name.
+var validToolName = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// Registry is an ordered set of tools with unique names. It is safe for
+// concurrent use.
+type Registry struct {
+	mu       sync.RWMutex
+	tools    []Tool
+	index    map[string]int
+	timeout  time.Duration
+	timeouts map[string]time.Duration
+}
+
+// NewRegistry returns a registry holding tools, in order. Calls are limited
+// to DefaultToolTimeout until changed with SetTimeout.
+func NewRegistry(tools ...Tool) (*Registry, error) {
+	r := newRegistry(DefaultToolTimeout, nil)
+	for _, t := range tools {
+		if err := r.Register(t); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Register adds t. Names must be unique and match [A-Za-z0-9_-]{1,64}.
+func (r *Registry) Register(t Tool) error {
+	name := t.Name()
+	if !validToolName.MatchString(name) {
+		return fmt.Errorf("invalid tool name %q", name)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, dup := r.index[name]; dup {
+		return fmt.Errorf("tool %q is already registered", name)
+	}
+	r.index[name] = len(r.tools)
+	r.tools = append(r.tools, t)
+	return nil
+}
+
+func newRegistry(timeout time.Duration, timeouts map[string]time.Duration) *Registry {
+	r := &Registry{index: map[string]int{}, timeout: timeout, timeouts: map[string]time.Duration{}}
+	for name, d := range timeouts {
+		r.timeouts[name] = d
+	}
+	return r
+}
+
+// derive returns an empty registry with r's timeouts.
+func (r *Registry) derive() *Registry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return newRegistry(r.timeout, r.timeouts)
+}
+
+// SetTimeout limits each call of the named tool to d. An empty name sets
+// the default for tools without a limit of their own. Zero means no limit.
+func (r *Registry) SetTimeout(name string, d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if name == "" {
+		r.timeout = d
+	} else {
+		r.timeouts[name] = d
+	}
+}
+
+// Timeout returns the limit on one call of the named tool; zero means none.
+func (r *Registry) Timeout(name string) time.Duration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if d, ok := r.timeouts[name]; ok {
+		return d
+	}
+	return r.timeout
+}
+
+// Lookup returns the named tool.
+func (r *Registry) Lookup(name string) (Tool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	i, ok := r.index[name]
+	if !ok {
+		return nil, false
+	}
+	return r.tools[i], true
+}
+
+// Tools returns the registered tools in registration order.
+func (r *Registry) Tools() []Tool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]Tool(nil), r.tools...)
+}
+
+// Has reports whether the named tool is registered.
+func (r *Registry) Has(name string) bool {
+	_, ok := r.Lookup(name)
+	return ok
+}
+
+// Filter returns a registry with only the tools matching an allow pattern
+// (all tools if allow is empty) and no deny pattern. Patterns are tool names
+// or path.Match globs such as "git_*". A plain name that matches no tool is
+// an error, to catch typos.
+func (r *Registry) Filter(allow, deny []string) (*Registry, error) {
+	tools := r.Tools()
+	for _, p := range append(append([]string(nil), allow...), deny...) {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid tool pattern %q", p)
+		}
+		if !strings.ContainsAny(p, "*?[") && !r.Has(p) {
+			return nil, fmt.Errorf("unknown tool %q", p)
+		}
+	}
+	out := r.derive()
+	for _, t := range tools {
+		if (len(allow) == 0 || matchTool(allow, t.Name())) && !matchTool(deny, t.Name()) {
+			out.index[t.Name()] = len(out.tools)
+			out.tools = append(out.tools, t)
+		}
+	}
+	return out, nil
+}
+
+// only returns a registry with the named tools that are registered.
+func (r *Registry) only(names []string) *Registry {
+	out := r.derive()
+	for _, name := range names {
+		if t, ok := r.Lookup(name); ok {
+			out.index[name] = len(out.tools)
+			out.tools = append(out.tools, t)
+		}
+	}
+	return out
+}
+
+func matchTool(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// definitions converts the registry to API tool definitions.
+func (r *Registry) definitions() []ionosTool {
+	tools := r.Tools()
+	out := make([]ionosTool, len(tools))
+	for i, t := range tools {
+		out[i] = ionosTool{Type: "function", Function: functionDef{Name: t.Name(), Description: t.Description(), Parameters: t.Schema()}}
+	}
+	return out
+}
+
+// dispatch finds and executes the named tool within its timeout, returning
+// a JSON string result. A call that does not produce one returns a
+// *ToolError saying whether it timed out, was cancelled with ctx or failed.
+// A tool that ignores its context is abandoned, not waited for, once the
+// context is done.
+func (r *Registry) dispatch(ctx context.Context, name, inputJSON string) (out string, err error) {
+	ctx, span := telemetry.Start(ctx, "execute_tool "+name,
+		telemetry.String("gen_ai.operation.name", "execute_tool"),
+		telemetry.String("gen_ai.tool.name", name))
+	began := time.Now()
+	defer func() {
+		toolCalls.Inc(name, outcome(err))
+		toolDuration.Observe(time.Since(began).Seconds(), name)
+		span.SetAttributes(telemetry.String("ladybug.tool.outcome", outcome(err)))
+		span.RecordError(err)
+		span.End()
+	}()
+
+	t, ok := r.Lookup(name)
+	if !ok {
+		var names []string
+		for _, t := range r.Tools() {
+			names = append(names, t.Name())
+		}
+		return "", &ToolError{Tool: name, Outcome: ToolFailed,
+			Err: fmt.Errorf("unknown tool: %s (available: %s)", name, strings.Join(names, ", "))}
+	}
+
+	timeout := r.Timeout(name)
+	callCtx, cancel := ctx, context.CancelFunc(func() {})
+	if timeout > 0 {
+		callCtx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	defer cancel()
+
+	type result struct {
+		out string
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- result{err: fmt.Errorf("%s panicked: %v", name, p)}
+			}
+		}()
+		out, err := t.Call(callCtx, inputJSON)
+		done <- result{out, err}
+	}()
+
+	select {
+	case res := <-done:
+		if res.err == nil {
+			return res.out, nil
+		}
+		err = res.err
+	case <-callCtx.Done():
+		err = callCtx.Err()
+	}
+	switch {
+	case ctx.Err() != nil:
+		return "", &ToolError{Tool: name, Outcome: ToolCancelled, Err: ctx.Err()}
+	case errors.Is(callCtx.Err(), context.DeadlineExceeded):
+		return "", &ToolError{Tool: name, Outcome: ToolTimedOut, Timeout: timeout, Err: err}
+	}
+	return "", &ToolError{Tool: name, Outcome: ToolFailed, Err: err}
+}
+
+var (
+	extraToolsMu sync.RWMutex
+	extraTools   []Tool
+)
+
+// RegisterTool adds a tool to every agent created afterwards with New, e.g.
+// a team-specific "query our incident DB" tool or a loaded plugin. Built-in
+// tool names are reserved.
+func RegisterTool(t Tool) error {
+	if !validToolName.MatchString(t.Name()) {
+		return fmt.Errorf("invalid tool name %q", t.Name())
+	}
+	if isBuiltin(t.Name()) {
+		return fmt.Errorf("tool %q is a built-in tool", t.Name())
+	}
+	extraToolsMu.Lock()
+	defer extraToolsMu.Unlock()
+	for _, e := range extraTools {
+		if e.Name() == t.Name() {
+			return fmt.Errorf("tool %q is already registered", t.Name())
+		}
+	}
+	extraTools = append(extraTools, t)
+	return nil
+}
+
+var (
+	toolTimeoutsMu sync.RWMutex
+	toolTimeouts   = map[string]time.Duration{}
+)
+
+// SetToolTimeout sets the call limit of the named tool, or with an empty
+// name the default limit, for every agent created afterwards with New.
+// Zero means no limit.
+func SetToolTimeout(name string, d time.Duration) error {
+	if name != "" && !validToolName.MatchString(name) {
+		return fmt.Errorf("invalid tool name %q", name)
+	}
+	if d < 0 {
+		return fmt.Errorf("negative timeout for %q", name)
+	}
+	toolTimeoutsMu.Lock()
+	defer toolTimeoutsMu.Unlock()
+	toolTimeouts[name] = d
+	return nil
+}
+
+func registeredTimeouts() map[string]time.Duration {
+	toolTimeoutsMu.RLock()
+	defer toolTimeoutsMu.RUnlock()
+	out := make(map[string]time.Duration, len(toolTimeouts))
+	for name, d := range toolTimeouts {
+		out[name] = d
+	}
+	return out
+}
+
+func registeredTools() []Tool {
+	extraToolsMu.RLock()
+	defer extraToolsMu.RUnlock()
+	return append([]Tool(nil), extraTools...)
+}
+
+// isBuiltin reports whether name is one of the built-in tools, including
+// those only registered when configured.
+func isBuiltin(name string) bool {
+	defs := append(allTools(), repoTools()...)
+	defs = append(defs, proposePatchTool(nil), planRollbackTool())
+	for _, d := range defs {
+		if d.Name() == name {
+			return true
+		}
+	}
+	return false
+}
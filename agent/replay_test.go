@@ -0,0 +1,280 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emyjamalian/laas-ladybug/redact"
+	"github.com/emyjamalian/laas-ladybug/report"
+	"github.com/emyjamalian/laas-ladybug/tools"
+)
+
+// Each directory under testdata is one analysis: input.txt is the agent
+// input, model/ holds the recorded model exchange (see Recorder) that the
+// tests replay, and report.json is the report it must produce. script.json
+// lists the model's turns; with -update they are played through a Recorder
+// to regenerate model/ and report.json.
+var update = flag.Bool("update", false, "re-record testdata/*/model from script.json and rewrite report.json")
+
+// pinnedTime is the clock time-aware triage sees, so its output, and with it
+// the recorded requests, are the same on every run.
+var pinnedTime = time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC)
+
+// hermetic resets the configuration the agent and its tools read from the
+// environment and package globals to the defaults, and pins the clock.
+func hermetic(t *testing.T) {
+	t.Helper()
+	for _, v := range []string{apiKeyEnvVar, "IONOS_MODEL", "LADYBUG_RECORD", "LADYBUG_REPLAY"} {
+		t.Setenv(v, "")
+	}
+	reset := func() {
+		tools.SetRepoRoot("")
+		tools.SetRepoAllowlist(nil)
+		if err := tools.SetCPDModel(tools.DefaultCPDModel()); err != nil {
+			t.Fatal(err)
+		}
+		tools.SetTriagePolicy(nil)
+		tools.SetDeployConfig(nil)
+		tools.SetFlagCatalog(nil)
+		tools.SetFixHistory(nil)
+		tools.SetWriteReproTests(false)
+		tools.SetClock(nil)
+		redact.SetDefault(redact.New(redact.Builtin()...))
+	}
+	reset()
+	t.Cleanup(reset)
+	tools.SetClock(func() time.Time { return pinnedTime })
+}
+
+// scriptTurn is one model response in script.json: text, tool calls, or
+// both.
+type scriptTurn struct {
+	Content   string `json:"content,omitempty"`
+	ToolCalls []struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"tool_calls,omitempty"`
+}
+
+// script stands in for the model when fixtures are regenerated, answering
+// each request with the next turn.
+type script struct {
+	turns []scriptTurn
+	calls int
+}
+
+func loadScript(t *testing.T, dir string) *script {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, "script.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s script
+	if err := json.Unmarshal(data, &s.turns); err != nil {
+		t.Fatalf("%s/script.json: %v", dir, err)
+	}
+	return &s
+}
+
+func (s *script) RoundTrip(req *http.Request) (*http.Response, error) {
+	if len(s.turns) == 0 {
+		return nil, fmt.Errorf("script.json has no turn left for this request")
+	}
+	turn := s.turns[0]
+	s.turns = s.turns[1:]
+	msg := chatMessage{Role: "assistant"}
+	if turn.Content != "" {
+		msg.Content = &turn.Content
+	}
+	for _, tc := range turn.ToolCalls {
+		s.calls++
+		var args bytes.Buffer
+		if err := json.Compact(&args, tc.Arguments); err != nil {
+			return nil, fmt.Errorf("script.json: %s arguments: %w", tc.Name, err)
+		}
+		msg.ToolCalls = append(msg.ToolCalls, toolCall{
+			ID:       fmt.Sprintf("call_%d", s.calls),
+			Type:     "function",
+			Function: functionCall{Name: tc.Name, Arguments: args.String()},
+		})
+	}
+	reason := "stop"
+	if len(msg.ToolCalls) > 0 {
+		reason = "tool_calls"
+	}
+	var resp chatResponse
+	resp.Choices = append(resp.Choices, struct {
+		Message      chatMessage `json:"message"`
+		FinishReason string      `json:"finish_reason"`
+	}{msg, reason})
+	body, err := json.Marshal(resp)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+// analyze runs the agent on the fixture in dir, replaying its recorded
+// model responses (or, with -update, recording the scripted ones).
+func analyze(t *testing.T, dir string) *Session {
+	t.Helper()
+	input, err := os.ReadFile(filepath.Join(dir, "input.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := New()
+	a.SetLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	model := filepath.Join(dir, "model")
+	if *update {
+		if err := os.RemoveAll(model); err != nil {
+			t.Fatal(err)
+		}
+		a.apiKey = "scripted"
+		a.http = &http.Client{Transport: loadScript(t, dir)}
+		a.Record(model)
+	} else {
+		a.Replay(model)
+	}
+	s, err := a.Start(context.Background(), string(input), io.Discard)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	return s
+}
+
+// checkGolden compares rep, without the fields that change on every run,
+// with dir/report.json.
+func checkGolden(t *testing.T, dir string, rep *report.Report) {
+	t.Helper()
+	r := *rep
+	r.ID, r.CreatedAt = "", time.Time{}
+	got, err := json.MarshalIndent(&r, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = append(got, '\n')
+	path := filepath.Join(dir, "report.json")
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(got, want) {
+		return
+	}
+	g, w := strings.Split(string(got), "\n"), strings.Split(string(want), "\n")
+	for i := 0; i < len(g) || i < len(w); i++ {
+		var gl, wl string
+		if i < len(g) {
+			gl = g[i]
+		}
+		if i < len(w) {
+			wl = w[i]
+		}
+		if gl != wl {
+			t.Fatalf("report differs from %s at line %d (go test ./agent -update accepts it):\n  got:  %s\n  want: %s",
+				path, i+1, strings.TrimSpace(gl), strings.TrimSpace(wl))
+		}
+	}
+}
+
+// calledTools returns the names of the tools the model called, in order.
+func calledTools(s *Session) []string {
+	var names []string
+	for _, m := range s.Messages {
+		for _, tc := range m.ToolCalls {
+			names = append(names, tc.Function.Name)
+		}
+	}
+	return names
+}
+
+func TestReplayAnalysis(t *testing.T) {
+	hermetic(t)
+	// A policy makes triage report when it evaluated the issue, which only
+	// replays because the clock is pinned.
+	tools.SetTriagePolicy(&tools.TriagePolicy{})
+	dir := filepath.Join("testdata", "analysis")
+	s := analyze(t, dir)
+
+	want := []string{"detect_regression", "triage_issue", "attribute_to_owner", "generate_fix_plan"}
+	if got := calledTools(s); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("tool calls = %v, want %v", got, want)
+	}
+	rep := s.Report
+	if rep.Detection == nil || rep.Triage == nil || rep.Attribution == nil || rep.FixPlan == nil {
+		t.Fatalf("report is missing a tool result: %+v", rep)
+	}
+	if tc := rep.Triage.TimeContext; tc == nil || tc.EvaluatedAt != pinnedTime.Format(time.RFC3339) {
+		t.Errorf("triage time context = %+v, want it evaluated at the pinned clock", tc)
+	}
+	if rep.Summary == "" {
+		t.Error("report has no summary")
+	}
+	checkGolden(t, dir, rep)
+}
+
+func TestReplayToolError(t *testing.T) {
+	hermetic(t)
+	dir := filepath.Join("testdata", "tool_error")
+	s := analyze(t, dir)
+
+	// The first triage_issue call has an invalid severity: the model must be
+	// told which field was wrong, and its corrected retry recorded.
+	var results []string
+	for _, m := range s.Messages {
+		if m.Role == "tool" && m.Content != nil {
+			results = append(results, *m.Content)
+		}
+	}
+	if len(results) < 2 {
+		t.Fatalf("got %d tool results, want the failed triage_issue call and its retry", len(results))
+	}
+	var failed struct {
+		Status        ToolOutcome        `json:"status"`
+		InvalidFields []tools.FieldError `json:"invalid_fields"`
+	}
+	if err := json.Unmarshal([]byte(results[1]), &failed); err != nil {
+		t.Fatalf("tool error result %q: %v", results[1], err)
+	}
+	if failed.Status != ToolFailed || len(failed.InvalidFields) != 1 || failed.InvalidFields[0].Field != "severity" {
+		t.Errorf("tool error result = %s, want a failure naming severity", results[1])
+	}
+	if s.Report.Triage == nil || s.Report.TriageInput.Severity != "medium" {
+		t.Errorf("triage = %+v, want the corrected retry recorded", s.Report.TriageInput)
+	}
+	checkGolden(t, dir, s.Report)
+}
+
+func TestReplayDiverged(t *testing.T) {
+	hermetic(t)
+	a := New()
+	a.SetLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	a.Replay(filepath.Join("testdata", "analysis", "model"))
+	_, err := a.Start(context.Background(), "a report that was never recorded", io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "no recorded response") {
+		t.Fatalf("Start = %v, want a missing recording error", err)
+	}
+}
@@ -0,0 +1,108 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/emyjamalian/laas-ladybug/report"
+	"github.com/emyjamalian/laas-ladybug/telemetry"
+)
+
+// Session is an analysis conversation: the full message history, including
+// tool calls and results, plus the structured report they produced. It
+// serialises to JSON so a session can be persisted and resumed later.
+type Session struct {
+	// ID is assigned when the session is persisted.
+	ID        string         `json:"id,omitempty"`
+	Model     string         `json:"model"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	Report    *report.Report `json:"report"`
+	Messages  []chatMessage  `json:"messages"`
+	// Tools are the tools the session started with; follow-ups are limited
+	// to those still registered.
+	Tools []string `json:"tools,omitempty"`
+	// OverridesSent counts the report's reviewer overrides already passed
+	// on to the model, so each correction is mentioned once.
+	OverridesSent int `json:"overrides_sent,omitempty"`
+}
+
+func newSession(model, system, input string) *Session {
+	now := time.Now().UTC()
+	user := input
+	return &Session{
+		Model:     model,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Report:    report.New(input),
+		Messages: []chatMessage{
+			{Role: "system", Content: &system},
+			{Role: "user", Content: &user},
+		},
+	}
+}
+
+// Turns returns the user questions and model answers of the conversation
+// after the initial analysis, oldest first, as role/text pairs.
+func (s *Session) Turns() [][2]string {
+	// The analysis ends with the first assistant message that calls no tools.
+	start := len(s.Messages)
+	for i := 2; i < len(s.Messages); i++ {
+		if m := s.Messages[i]; m.Role == "assistant" && len(m.ToolCalls) == 0 {
+			start = i + 1
+			break
+		}
+	}
+	var out [][2]string
+	for _, m := range s.Messages[start:] {
+		if m.Content == nil || *m.Content == "" || (m.Role != "user" && m.Role != "assistant") {
+			continue
+		}
+		out = append(out, [2]string{m.Role, *m.Content})
+	}
+	return out
+}
+
+// Ask continues the session with a follow-up question. The model may call
+// tools again, in which case the session's report is updated. Progress is
+// streamed to w and the answer is returned.
+func (a *Agent) Ask(ctx context.Context, s *Session, question string, w io.Writer) (answer string, err error) {
+	if a.apiKey == "" {
+		return "", fmt.Errorf("%s environment variable is not set", apiKeyEnvVar)
+	}
+	question = strings.TrimSpace(question)
+	if question == "" {
+		return "", fmt.Errorf("empty question")
+	}
+	ctx, span := telemetry.Start(ctx, "ask",
+		telemetry.String("gen_ai.request.model", a.model), telemetry.String("ladybug.session", s.ID))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+	n, sent := len(s.Messages), s.OverridesSent
+	// Tell the model about corrections made since it last saw the report.
+	if s.Report != nil && len(s.Report.Overrides) > s.OverridesSent {
+		question = "(The reviewer has since corrected: " + strings.Join(s.Report.Overrides[s.OverridesSent:], "; ") +
+			". Treat these as authoritative.)\n\n" + question
+		s.OverridesSent = len(s.Report.Overrides)
+	}
+	question = a.scrub(ctx, s, "question", question)
+	s.Messages = append(s.Messages, chatMessage{Role: "user", Content: &question})
+	s.Model = a.model
+	if len(s.Tools) > 0 {
+		b := *a
+		b.tools = a.tools.only(s.Tools)
+		a = &b
+	}
+	answer, err = a.converse(ctx, s, w)
+	if err != nil {
+		// Drop the unanswered turn so the question can simply be retried.
+		s.Messages, s.OverridesSent = s.Messages[:n], sent
+		return "", err
+	}
+	return answer, nil
+}
@@ -0,0 +1,52 @@
+package agent
+
+import (
+	"context"
+	"errors"
+
+	"github.com/emyjamalian/laas-ladybug/telemetry"
+)
+
+// Metrics of every agent in the process, registered in telemetry.Default.
+var (
+	analysesTotal = telemetry.NewCounter("ladybug_analyses_total",
+		"Completed analyses by priority and regression type.", "priority", "regression_type")
+	analysisDuration = telemetry.NewHistogram("ladybug_analysis_duration_seconds",
+		"Duration of analyses, from the first model request to the final report.",
+		[]float64{5, 10, 20, 30, 60, 120, 300, 600}, "outcome")
+	agentIterations = telemetry.NewHistogram("ladybug_agent_iterations",
+		"Model requests per analysis or follow-up question.", []float64{1, 2, 3, 4, 5, 6, 8, 10, 15, 20})
+	modelDuration = telemetry.NewHistogram("ladybug_model_request_duration_seconds",
+		"Latency of chat completion requests to the model API.",
+		[]float64{.25, .5, 1, 2, 5, 10, 20, 30, 60, 120}, "model", "outcome")
+	modelTokens = telemetry.NewCounter("ladybug_model_tokens_total",
+		"Tokens used by model requests, as reported by the API.", "model", "type")
+	toolCalls = telemetry.NewCounter("ladybug_tool_calls_total",
+		"Tool calls by outcome: ok, failed, timeout or cancelled.", "tool", "outcome")
+	toolDuration = telemetry.NewHistogram("ladybug_tool_duration_seconds",
+		"Duration of tool calls.", telemetry.DefBuckets, "tool")
+)
+
+// outcome labels the result of a request or run for metrics.
+func outcome(err error) string {
+	var terr *ToolError
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.As(err, &terr):
+		return string(terr.Outcome)
+	case errors.Is(err, context.Canceled):
+		return string(ToolCancelled)
+	case errors.Is(err, context.DeadlineExceeded):
+		return string(ToolTimedOut)
+	}
+	return "error"
+}
+
+// orNone labels a metric with "none" instead of an empty value.
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
@@ -1,7 +1,8 @@
 package agent
 
 import (
-	"fmt"
+	"context"
+	"time"
 
 	"github.com/emyjamalian/laas-ladybug/tools"
 )
@@ -18,10 +19,27 @@ type functionDef struct {
 	Parameters  map[string]interface{} `json:"parameters"`
 }
 
-// toolDef pairs an API tool definition with its local Go handler.
+// toolDef pairs an API tool definition with its local Go handler. It is
+// how the built-in tools implement Tool. Parameters are generated from the
+// tool's input struct with tools.Schema, never written by hand.
 type toolDef struct {
 	Tool    ionosTool
-	Handler func(inputJSON string) (string, error)
+	Handler tools.Handler
+}
+
+func (d toolDef) Name() string                   { return d.Tool.Function.Name }
+func (d toolDef) Description() string            { return d.Tool.Function.Description }
+func (d toolDef) Schema() map[string]interface{} { return d.Tool.Function.Parameters }
+func (d toolDef) Call(ctx context.Context, inputJSON string) (string, error) {
+	return d.Handler(ctx, inputJSON)
+}
+
+// builtinTimeouts are the built-in tools that need longer than
+// DefaultToolTimeout: compiling a reproduction test and asking the model
+// for a patch.
+var builtinTimeouts = map[string]time.Duration{
+	"generate_repro_test": 3 * time.Minute,
+	"propose_patch":       2 * time.Minute,
 }
 
 // allTools returns the complete set of Fix Fast tools with their definitions.
@@ -36,24 +54,7 @@ func allTools() []toolDef {
 						"Returns the regression type (null_pointer, performance, crash, memory_leak, logic_error, " +
 						"data_corruption, api_breaking_change, security_flaw), severity, affected components, " +
 						"and detection confidence. Always call this first.",
-					Parameters: buildSchema(
-						map[string]interface{}{
-							"description": prop("string", "Description of the bug, crash, or code change to analyze for regressions"),
-							"files_changed": map[string]interface{}{
-								"type":        "array",
-								"items":       map[string]interface{}{"type": "string"},
-								"description": "List of files modified in the change (optional)",
-							},
-							"environment":   prop("string", "Where the issue was found: ide, local_test, ci, code_review, staging, or production"),
-							"error_message": prop("string", "The actual error or stack trace if available (optional)"),
-							"run_history": map[string]interface{}{
-								"type":        "array",
-								"items":       map[string]interface{}{"type": "string", "enum": []string{"pass", "fail"}},
-								"description": "Recent run results oldest-first, each 'pass' or 'fail'. Enables BrowserLab-style statistical confidence scoring.",
-							},
-						},
-						[]string{"description", "environment"},
-					),
+					Parameters: tools.Schema(tools.DetectRegressionInput{}),
 				},
 			},
 			Handler: tools.DetectRegression,
@@ -66,20 +67,10 @@ func allTools() []toolDef {
 					Description: "Calculates the Cost Per Developer (CPD) score for a regression. " +
 						"CPD is Facebook's metric: bugs cost exponentially more the further downstream they are detected. " +
 						"Production bugs are 100x more expensive than IDE-caught bugs. " +
-						"Returns priority (P0-P3), recommended action, and a 'shift left' target environment. " +
+						"Returns priority (P0-P3), recommended action, the CPD at every stage of the shift-left chain, " +
+						"and the earliest stage that could realistically have caught this regression type. " +
 						"Call this after detect_regression.",
-					Parameters: buildSchema(
-						map[string]interface{}{
-							"regression_type": prop("string", "Type of regression from detect_regression output"),
-							"severity":        prop("string", "Severity from detect_regression: critical, high, medium, or low"),
-							"environment":     prop("string", "Where the issue was found: ide, local_test, ci, code_review, staging, or production"),
-							"affected_users_estimate": map[string]interface{}{
-								"type":        "integer",
-								"description": "Estimated number of users affected (0 if unknown)",
-							},
-						},
-						[]string{"regression_type", "severity", "environment", "affected_users_estimate"},
-					),
+					Parameters: tools.Schema(tools.TriageIssueInput{}),
 				},
 			},
 			Handler: tools.TriageIssue,
@@ -94,18 +85,7 @@ func allTools() []toolDef {
 						"Fix Fast to route issues to the right team 3x faster. " +
 						"Returns suspected owners with confidence scores. " +
 						"Call this after triage_issue.",
-					Parameters: buildSchema(
-						map[string]interface{}{
-							"files_changed": map[string]interface{}{
-								"type":        "array",
-								"items":       map[string]interface{}{"type": "string"},
-								"description": "List of files changed in the suspected commit or diff",
-							},
-							"description":     prop("string", "Description of the regression or bug"),
-							"regression_type": prop("string", "Type of regression from detect_regression"),
-						},
-						[]string{"description", "regression_type"},
-					),
+					Parameters: tools.Schema(tools.AttributeIssueInput{}),
 				},
 			},
 			Handler: tools.AttributeToOwner,
@@ -120,57 +100,116 @@ func allTools() []toolDef {
 						"root cause fix, prevention measures, and 'shift left' recommendations to catch this " +
 						"class of bug earlier in the development pipeline next time. " +
 						"Call this last, after attribution is complete.",
-					Parameters: buildSchema(
-						map[string]interface{}{
-							"regression_type": prop("string", "Type of regression from detect_regression"),
-							"severity":        prop("string", "Severity level: critical, high, medium, or low"),
-							"affected_files": map[string]interface{}{
-								"type":        "array",
-								"items":       map[string]interface{}{"type": "string"},
-								"description": "Files involved in the regression",
-							},
-							"root_cause": prop("string", "Description of the suspected root cause"),
-							"priority":   prop("string", "Priority from triage: P0, P1, P2, or P3"),
-						},
-						[]string{"regression_type", "severity", "root_cause", "priority"},
-					),
+					Parameters: tools.Schema(tools.GenerateFixPlanInput{}),
 				},
 			},
 			Handler: tools.GenerateFixPlan,
 		},
+		{
+			Tool: ionosTool{
+				Type: "function",
+				Function: functionDef{
+					Name: "generate_repro_test",
+					Description: "Generates a failing regression test skeleton (Go table-driven test, JUnit or pytest) for the " +
+						"failing stack frame and checks that it parses. If writing tests is enabled, it is also written next to " +
+						"the code without overwriting existing files and checked to compile. Call this after generate_fix_plan.",
+					Parameters: tools.Schema(tools.GenerateReproTestInput{}),
+				},
+			},
+			Handler: tools.GenerateReproTest,
+		},
 	}
 }
 
-// toolParams extracts the API tool definitions from the tool list.
-func toolParams(defs []toolDef) []ionosTool {
-	out := make([]ionosTool, len(defs))
-	for i, d := range defs {
-		out[i] = d.Tool
-	}
-	return out
-}
-
-// dispatch finds and executes the named tool, returning a JSON string result.
-func dispatch(defs []toolDef, name string, inputJSON string) (string, error) {
-	for _, d := range defs {
-		if d.Tool.Function.Name == name {
-			return d.Handler(inputJSON)
-		}
+// proposePatchTool defines the optional propose_patch tool. It is only
+// registered when a repository root is configured.
+func proposePatchTool(complete tools.Completer) toolDef {
+	return toolDef{
+		Tool: ionosTool{
+			Type: "function",
+			Function: functionDef{
+				Name: "propose_patch",
+				Description: "Reads the affected source file from the local checkout and proposes a minimal code fix " +
+					"as a unified diff. The diff is validated with 'git apply --check' and rejected if it touches files " +
+					"outside the component recorded by attribute_to_owner. Call this after generate_fix_plan.",
+				Parameters: tools.Schema(tools.ProposePatchInput{}),
+			},
+		},
+		Handler: tools.ProposePatch(complete),
 	}
-	return "", fmt.Errorf("unknown tool: %s", name)
 }
 
-func prop(typ, description string) map[string]interface{} {
-	return map[string]interface{}{
-		"type":        typ,
-		"description": description,
+// repoTools defines the read-only repository-browsing tools. They are only
+// registered when a repository root is configured.
+func repoTools() []toolDef {
+	return []toolDef{
+		{
+			Tool: ionosTool{
+				Type: "function",
+				Function: functionDef{
+					Name: "read_file",
+					Description: "Reads numbered lines from a file in the local checkout (at most 300 lines per call). " +
+						"Use it to inspect the code around a failing stack frame before generate_fix_plan, and cite " +
+						"the exact path:line in the report.",
+					Parameters: tools.Schema(tools.ReadFileInput{}),
+				},
+			},
+			Handler: tools.ReadFile,
+		},
+		{
+			Tool: ionosTool{
+				Type: "function",
+				Function: functionDef{
+					Name: "search_code",
+					Description: "Searches the local checkout's source files for a regular expression and returns matching " +
+						"lines with their path and line number (at most 100). Use it to find a function from a stack " +
+						"trace, callers of changed code, or where a column or flag is used.",
+					Parameters: tools.Schema(tools.SearchCodeInput{}),
+				},
+			},
+			Handler: tools.SearchCode,
+		},
+		{
+			Tool: ionosTool{
+				Type: "function",
+				Function: functionDef{
+					Name:        "list_dir",
+					Description: "Lists the files and directories in a directory of the local checkout.",
+					Parameters:  tools.Schema(tools.ListDirInput{}),
+				},
+			},
+			Handler: tools.ListDir,
+		},
+		{
+			Tool: ionosTool{
+				Type: "function",
+				Function: functionDef{
+					Name: "git_log",
+					Description: "Lists recent commits of the local checkout with author, date, subject and changed files, " +
+						"optionally limited to a path. Use it to find the change that introduced a regression.",
+					Parameters: tools.Schema(tools.GitLogInput{}),
+				},
+			},
+			Handler: tools.GitLog,
+		},
 	}
 }
 
-func buildSchema(properties map[string]interface{}, required []string) map[string]interface{} {
-	return map[string]interface{}{
-		"type":       "object",
-		"properties": properties,
-		"required":   required,
+// planRollbackTool defines the optional plan_rollback tool. It is only
+// registered when a repository root or deploy config is configured.
+func planRollbackTool() toolDef {
+	return toolDef{
+		Tool: ionosTool{
+			Type: "function",
+			Function: functionDef{
+				Name: "plan_rollback",
+				Description: "Plans a concrete rollback for the culprit commit or release tag: feature-flag toggles, " +
+					"kubectl rollout undo / helm rollback commands from the deploy config, and a git revert sequence " +
+					"(merge commits reverted with -m 1) plus the previous good tag. Nothing is executed. " +
+					"Call this for P0/P1 regressions when the introducing commit or release is known.",
+				Parameters: tools.Schema(tools.PlanRollbackInput{}),
+			},
+		},
+		Handler: tools.PlanRollback,
 	}
 }
@@ -0,0 +1,564 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/emyjamalian/laas-ladybug/agent"
+	"github.com/emyjamalian/laas-ladybug/tools"
+)
+
+// inputOptions are the flags describing a reported regression, shared by
+// analyze and the single-tool commands.
+type inputOptions struct {
+	env        string
+	files      string
+	errorFile  string
+	runHistory string
+	users      int
+	format     string
+}
+
+func (o *inputOptions) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	o.register(fs)
+	return fs
+}
+
+func (o *inputOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.env, "env", "", "`stage` where the issue was found: "+strings.Join(tools.CurrentCPDModel().EnvironmentNames(), ", "))
+	fs.StringVar(&o.files, "files", "", "comma-separated `paths` changed by the suspected commit")
+	fs.StringVar(&o.errorFile, "error-file", "", "read the error message or stack trace from `file` (- for stdin)")
+	fs.StringVar(&o.runHistory, "run-history", "", "comma-separated recent `results`, oldest first, each pass or fail")
+	fs.IntVar(&o.users, "users", 0, "estimated `number` of affected users")
+	fs.StringVar(&o.format, "format", "text", "output `format`: text or json")
+}
+
+// validate checks flag values that the tools would otherwise silently
+// default.
+func (o *inputOptions) validate() error {
+	if o.format != "text" && o.format != "json" {
+		return usageError("--format must be text or json, not %q", o.format)
+	}
+	if o.env != "" {
+		names := tools.CurrentCPDModel().EnvironmentNames()
+		if !contains(names, o.env) {
+			return usageError("unknown --env %q (want one of %s)", o.env, strings.Join(names, ", "))
+		}
+	}
+	for _, r := range splitList(o.runHistory) {
+		if r != "pass" && r != "fail" {
+			return usageError("--run-history entries must be pass or fail, not %q", r)
+		}
+	}
+	if o.users < 0 {
+		return usageError("--users must not be negative")
+	}
+	return nil
+}
+
+// detectInput builds the regression report from flags and the description
+// words. Without words, a piped stdin is read as the description.
+func (o *inputOptions) detectInput(words []string) (tools.DetectRegressionInput, error) {
+	in := tools.DetectRegressionInput{
+		Description:  strings.Join(words, " "),
+		FilesChanged: splitList(o.files),
+		Environment:  o.env,
+		RunHistory:   splitList(o.runHistory),
+	}
+	if o.errorFile != "" {
+		data, err := readFileOrStdin(o.errorFile)
+		if err != nil {
+			return in, fmt.Errorf("read --error-file: %w", err)
+		}
+		in.ErrorMessage = strings.TrimSpace(string(data))
+	}
+	if in.Description == "" && o.errorFile != "-" && stdinPiped() {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return in, err
+		}
+		in.Description = strings.TrimSpace(string(data))
+	}
+	return in, nil
+}
+
+// prompt renders the input for the agent, including the affected-user
+// estimate the detect input has no field for.
+func (o *inputOptions) prompt(in tools.DetectRegressionInput) string {
+	p := agent.FormatInput(in)
+	if o.users > 0 {
+		p += fmt.Sprintf("\nAffected users: %d\n", o.users)
+	}
+	return p
+}
+
+type analyzeOptions struct {
+	inputOptions
+	tools      toolOptions
+	model      string
+	ticket     string
+	dryRun     bool
+	writePatch string
+	chat       bool
+}
+
+func (o *analyzeOptions) flags() *flag.FlagSet {
+	fs := o.inputOptions.flags("analyze")
+	fs.StringVar(&o.model, "model", "", "IONOS model `id` (default $IONOS_MODEL or "+agent.DefaultModel+")")
+	fs.StringVar(&o.ticket, "ticket", "", "file the result in `sink`: github, jira or webhook")
+	fs.BoolVar(&o.dryRun, "dry-run", false, "print the ticket payload instead of filing it")
+	fs.StringVar(&o.writePatch, "write-patch", "", "save the validated patch from propose_patch to `file`")
+	o.tools.register(fs)
+	fs.BoolVar(&o.chat, "chat", false, "ask follow-up questions about the analysis when it completes")
+	return fs
+}
+
+// runAnalyze runs the full agent. The description comes from the arguments,
+// a pipe, or an interactive prompt.
+func runAnalyze(args []string) error {
+	var o analyzeOptions
+	words, err := parseArgs(o.flags(), args)
+	if err != nil {
+		return err
+	}
+	if err := o.validate(); err != nil {
+		return err
+	}
+	if o.chat && o.format == "json" {
+		return usageError("--chat needs --format text")
+	}
+	in, err := o.detectInput(words)
+	if err != nil {
+		return err
+	}
+	interactive := in.Description == "" && !stdinPiped()
+	if interactive && o.format == "text" {
+		return runTUI(o, in)
+	}
+	if interactive {
+		in.Description = promptDescription()
+		if in.Environment == "" {
+			in.Environment = promptEnvironment()
+		}
+	}
+	if strings.TrimSpace(in.Description) == "" && in.ErrorMessage == "" {
+		return usageError("no input provided")
+	}
+
+	// JSON output keeps stdout clean for the report; progress goes to stderr.
+	out := io.Writer(os.Stdout)
+	if o.format == "json" {
+		out = os.Stderr
+	} else if !interactive {
+		printBanner()
+	}
+
+	integ, err := loadIntegrations()
+	if err != nil {
+		return err
+	}
+	if o.ticket != "" {
+		integ.ticketSink = o.ticket
+	}
+	integ.dryRun = o.dryRun
+
+	a := agent.New()
+	if o.model != "" {
+		a.SetModel(o.model)
+	}
+	if a, err = o.tools.apply(a); err != nil {
+		return err
+	}
+	ctx, stop := interruptible(context.Background())
+	defer stop()
+	sess, err := a.Start(ctx, o.prompt(in), out)
+	if err != nil {
+		return err
+	}
+	rep := sess.Report
+	if o.writePatch != "" {
+		if err := writePatch(rep, o.writePatch); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Wrote patch to %s (apply with: git apply %s)\n", o.writePatch, o.writePatch)
+	}
+	pubErr := integ.publish(ctx, rep, out)
+	integ.saveSession(sess, out)
+	if o.format == "json" {
+		if err := printJSON(os.Stdout, rep); err != nil {
+			return err
+		}
+	}
+	if o.chat {
+		if pubErr != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", pubErr)
+		}
+		stop()
+		return chat(context.Background(), a, sess, integ, bufio.NewReader(os.Stdin), out)
+	}
+	return pubErr
+}
+
+// interruptible returns a copy of parent that Ctrl-C or SIGTERM cancels, so
+// an analysis stops its model request and tool calls instead of the process
+// dying mid-write. stop restores the signals' default behaviour.
+func interruptible(parent context.Context) (ctx context.Context, stop context.CancelFunc) {
+	return signal.NotifyContext(parent, os.Interrupt, syscall.SIGTERM)
+}
+
+func runDetect(args []string) error {
+	var o inputOptions
+	words, err := parseArgs(o.flags("detect"), args)
+	if err != nil {
+		return err
+	}
+	if err := o.validate(); err != nil {
+		return err
+	}
+	in, err := o.detectInput(words)
+	if err != nil {
+		return err
+	}
+	if in.Description == "" && in.ErrorMessage == "" {
+		return usageError("a description or --error-file is required")
+	}
+	if in.Description == "" {
+		// detect_regression requires a description; the trace is one.
+		in.Description = in.ErrorMessage
+	}
+	var out tools.DetectRegressionOutput
+	if err := callTool(tools.DetectRegression, in, &out); err != nil {
+		return err
+	}
+	return printResult(o.format, out)
+}
+
+type triageOptions struct {
+	inputOptions
+	regressionType string
+	severity       string
+	revenue        float64
+	slaTier        string
+	org            string
+	service        string
+	detectedAt     string
+}
+
+func (o *triageOptions) flags() *flag.FlagSet {
+	fs := o.inputOptions.flags("triage")
+	fs.StringVar(&o.regressionType, "type", "", "regression `type` from detect (detected from the description if omitted)")
+	fs.StringVar(&o.severity, "severity", "", "`severity`: critical, high, medium or low (detected if omitted)")
+	fs.Float64Var(&o.revenue, "revenue", 0, "`amount` of revenue at risk per hour")
+	fs.StringVar(&o.slaTier, "sla-tier", "", "SLA `tier` of the affected service")
+	fs.StringVar(&o.org, "org", "", "`org` whose priority thresholds apply")
+	fs.StringVar(&o.service, "service", "", "affected `service`, for SLO error-budget lookups")
+	fs.StringVar(&o.detectedAt, "detected-at", "", "detection `time` (RFC 3339, default now)")
+	return fs
+}
+
+func runTriage(args []string) error {
+	var o triageOptions
+	words, err := parseArgs(o.flags(), args)
+	if err != nil {
+		return err
+	}
+	if err := o.validate(); err != nil {
+		return err
+	}
+	if o.regressionType == "" || o.severity == "" {
+		in, err := o.detectInput(words)
+		if err != nil {
+			return err
+		}
+		if in.Description == "" && in.ErrorMessage == "" {
+			return usageError("give --type and --severity, or a description to detect them from")
+		}
+		if in.Description == "" {
+			in.Description = in.ErrorMessage
+		}
+		var det tools.DetectRegressionOutput
+		if err := callTool(tools.DetectRegression, in, &det); err != nil {
+			return err
+		}
+		if o.regressionType == "" {
+			o.regressionType = string(det.RegressionType)
+		}
+		if o.severity == "" {
+			o.severity = string(det.Severity)
+		}
+	}
+	var out tools.TriageIssueOutput
+	err = callTool(tools.TriageIssue, tools.TriageIssueInput{
+		RegressionType:        o.regressionType,
+		Severity:              o.severity,
+		Environment:           o.env,
+		AffectedUsersEstimate: o.users,
+		RevenueImpactPerHour:  o.revenue,
+		SLATier:               o.slaTier,
+		Org:                   o.org,
+		Service:               o.service,
+		DetectedAt:            o.detectedAt,
+	}, &out)
+	if err != nil {
+		return err
+	}
+	return printResult(o.format, out)
+}
+
+type attributeOptions struct {
+	inputOptions
+	regressionType string
+}
+
+func (o *attributeOptions) flags() *flag.FlagSet {
+	fs := o.inputOptions.flags("attribute")
+	fs.StringVar(&o.regressionType, "type", "", "regression `type` from detect")
+	return fs
+}
+
+func runAttribute(args []string) error {
+	var o attributeOptions
+	words, err := parseArgs(o.flags(), args)
+	if err != nil {
+		return err
+	}
+	if err := o.validate(); err != nil {
+		return err
+	}
+	in, err := o.detectInput(words)
+	if err != nil {
+		return err
+	}
+	if len(in.FilesChanged) == 0 {
+		return usageError("--files is required")
+	}
+	var out tools.AttributeIssueOutput
+	err = callTool(tools.AttributeToOwner, tools.AttributeIssueInput{
+		FilesChanged:   in.FilesChanged,
+		Description:    in.Description,
+		RegressionType: o.regressionType,
+	}, &out)
+	if err != nil {
+		return err
+	}
+	return printResult(o.format, out)
+}
+
+type planOptions struct {
+	inputOptions
+	regressionType string
+	severity       string
+	priority       string
+	component      string
+	diffFile       string
+}
+
+func (o *planOptions) flags() *flag.FlagSet {
+	fs := o.inputOptions.flags("plan")
+	fs.StringVar(&o.regressionType, "type", "", "regression `type` from detect")
+	fs.StringVar(&o.severity, "severity", "medium", "`severity`: critical, high, medium or low")
+	fs.StringVar(&o.priority, "priority", "P2", "`priority` from triage: P0, P1, P2 or P3")
+	fs.StringVar(&o.component, "component", "", "attributed `component`, for effort history")
+	fs.StringVar(&o.diffFile, "diff-file", "", "suspected change as a unified diff `file` (- for stdin)")
+	return fs
+}
+
+func runPlan(args []string) error {
+	var o planOptions
+	words, err := parseArgs(o.flags(), args)
+	if err != nil {
+		return err
+	}
+	if err := o.validate(); err != nil {
+		return err
+	}
+	in := tools.GenerateFixPlanInput{
+		RegressionType: o.regressionType,
+		Severity:       o.severity,
+		AffectedFiles:  splitList(o.files),
+		RootCause:      strings.Join(words, " "),
+		Priority:       o.priority,
+		Component:      o.component,
+	}
+	if o.diffFile != "" {
+		data, err := readFileOrStdin(o.diffFile)
+		if err != nil {
+			return fmt.Errorf("read --diff-file: %w", err)
+		}
+		in.Diff = string(data)
+		in.DiffLines = countChangedLines(in.Diff)
+	}
+	var out tools.GenerateFixPlanOutput
+	if err := callTool(tools.GenerateFixPlan, in, &out); err != nil {
+		return err
+	}
+	return printResult(o.format, out)
+}
+
+// callTool runs a tool handler directly with in as its JSON input. Invalid
+// input is reported against the command's flags and arguments.
+func callTool(handler tools.Handler, in, out interface{}) error {
+	data, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	ctx, stop := interruptible(context.Background())
+	defer stop()
+	result, err := handler(ctx, string(data))
+	var verr *tools.ValidationError
+	if errors.As(err, &verr) {
+		lines := make([]string, len(verr.Fields))
+		for i, f := range verr.Fields {
+			f.Field = cliField(f.Field)
+			lines[i] = "  " + f.String()
+		}
+		return usageError("invalid input:\n%s", strings.Join(lines, "\n"))
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(result), out)
+}
+
+// cliFields maps tool input fields to the flags and arguments that set them.
+var cliFields = map[string]string{
+	"description":             "DESCRIPTION",
+	"root_cause":              "ROOT CAUSE",
+	"regression_type":         "--type",
+	"severity":                "--severity",
+	"priority":                "--priority",
+	"environment":             "--env",
+	"affected_users_estimate": "--users",
+	"revenue_impact_per_hour": "--revenue",
+	"sla_tier":                "--sla-tier",
+	"org":                     "--org",
+	"service":                 "--service",
+	"detected_at":             "--detected-at",
+	"run_history":             "--run-history",
+	"files_changed":           "--files",
+	"affected_files":          "--files",
+	"error_message":           "--error-file",
+	"component":               "--component",
+	"diff":                    "--diff-file",
+	"diff_lines":              "--diff-file",
+}
+
+func cliField(field string) string {
+	base, index, _ := strings.Cut(field, "[")
+	if name, ok := cliFields[base]; ok {
+		if index != "" {
+			return name + " entry " + strings.TrimSuffix(index, "]")
+		}
+		return name
+	}
+	return field
+}
+
+func printResult(format string, v interface{}) error {
+	if format == "json" {
+		return printJSON(os.Stdout, v)
+	}
+	renderText(os.Stdout, v)
+	return nil
+}
+
+func printJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(v)
+}
+
+func promptDescription() string {
+	fmt.Print("Describe the bug or paste a diff (press Enter twice when done):\n> ")
+	scanner := bufio.NewScanner(os.Stdin)
+	var lines []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" && len(lines) > 0 {
+			break
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// promptEnvironment asks until it gets a valid stage. An empty answer leaves
+// the environment unspecified rather than guessing.
+func promptEnvironment() string {
+	envs := tools.CurrentCPDModel().EnvironmentNames()
+	fmt.Println("\nWhere was this issue detected?")
+	for i, e := range envs {
+		fmt.Printf("  %d) %s\n", i+1, e)
+	}
+	scanner := bufio.NewScanner(os.Stdin)
+	for {
+		fmt.Print("> ")
+		if !scanner.Scan() {
+			return ""
+		}
+		choice := strings.TrimSpace(scanner.Text())
+		if choice == "" {
+			fmt.Println("Environment left unspecified.")
+			return ""
+		}
+		for i, e := range envs {
+			if choice == fmt.Sprint(i+1) || strings.EqualFold(choice, e) {
+				return e
+			}
+		}
+		fmt.Printf("Please pick 1-%d or a stage name (Enter to skip).\n", len(envs))
+	}
+}
+
+func stdinPiped() bool {
+	stat, err := os.Stdin.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice == 0
+}
+
+func readFileOrStdin(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func countChangedLines(diff string) int {
+	n := 0
+	for _, line := range strings.Split(diff, "\n") {
+		if (strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-")) &&
+			!strings.HasPrefix(line, "+++") && !strings.HasPrefix(line, "---") {
+			n++
+		}
+	}
+	return n
+}
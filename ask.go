@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/emyjamalian/laas-ladybug/agent"
+	"github.com/emyjamalian/laas-ladybug/store"
+)
+
+type askOptions struct {
+	model string
+}
+
+func (o *askOptions) flags() *flag.FlagSet {
+	fs := flag.NewFlagSet("ask", flag.ContinueOnError)
+	fs.StringVar(&o.model, "model", "", "IONOS model `id` (default $IONOS_MODEL or "+agent.DefaultModel+")")
+	return fs
+}
+
+// runAsk resumes a saved session. With a question it answers once;
+// without one it starts a follow-up prompt. With no session ID it lists
+// saved sessions.
+func runAsk(args []string) error {
+	var o askOptions
+	words, err := parseArgs(o.flags(), args)
+	if err != nil {
+		return err
+	}
+	integ, err := loadIntegrations()
+	if err != nil {
+		return err
+	}
+	if integ.sessions == nil {
+		return fmt.Errorf("session persistence is off (LADYBUG_SESSIONS=off)")
+	}
+	if len(words) == 0 {
+		return listSessions(integ.sessions, os.Stdout)
+	}
+
+	sess, err := integ.sessions.Load(words[0])
+	if errors.Is(err, store.ErrSessionNotFound) {
+		return fmt.Errorf("no session %q in %s", words[0], integ.sessions.Path)
+	}
+	if err != nil {
+		return err
+	}
+	a := agent.New()
+	if o.model != "" {
+		a.SetModel(o.model)
+	}
+	if question := strings.Join(words[1:], " "); question != "" {
+		ctx, stop := interruptible(context.Background())
+		defer stop()
+		_, err := a.Ask(ctx, sess, question, os.Stdout)
+		fmt.Println()
+		integ.saveSession(sess, os.Stdout)
+		return err
+	}
+	rep := sess.Report
+	fmt.Printf("Session %s: %s\n%s\n", sess.ID, strings.Join(strings.Fields(strings.Join([]string{
+		rep.Priority(), rep.Severity(), rep.RegressionType(), "in", rep.Component()}, " ")), " "), rep.Headline())
+	for _, turn := range sess.Turns() {
+		fmt.Printf("\n%s> %s\n", turn[0], strings.TrimSpace(turn[1]))
+	}
+	return chat(context.Background(), a, sess, integ, bufio.NewReader(os.Stdin), os.Stdout)
+}
+
+// chat is the follow-up prompt: each line is a question about the session's
+// analysis. An empty line is ignored; "exit", "quit" or EOF ends it. The
+// session is saved after every answer.
+func chat(ctx context.Context, a *agent.Agent, sess *agent.Session, integ *integrations, in *bufio.Reader, w io.Writer) error {
+	fmt.Fprintln(w, "\nAsk a follow-up question, e.g. \"why this component?\" or \"re-plan assuming this is a flake\" (exit to quit).")
+	for {
+		fmt.Fprint(w, "\nask> ")
+		line, err := in.ReadString('\n')
+		question := strings.TrimSpace(line)
+		switch {
+		case question == "exit" || question == "quit":
+			return nil
+		case question != "":
+			qctx, stop := interruptible(ctx)
+			_, askErr := a.Ask(qctx, sess, question, w)
+			stop()
+			if askErr != nil {
+				fmt.Fprintf(w, "\nerror: %v\n", askErr)
+			} else {
+				fmt.Fprintln(w)
+				integ.saveSession(sess, w)
+			}
+		}
+		if err != nil {
+			fmt.Fprintln(w)
+			return nil
+		}
+	}
+}
+
+func listSessions(sessions *store.Sessions, w io.Writer) error {
+	list, err := sessions.List()
+	if err != nil {
+		return err
+	}
+	if len(list) == 0 {
+		fmt.Fprintf(w, "No saved sessions in %s\n", sessions.Path)
+		return nil
+	}
+	for _, s := range list {
+		fmt.Fprintf(w, "%s  %-2s  %-19s  %s\n", s.ID, s.Report.Priority(), s.Report.RegressionType(), s.Report.Headline())
+	}
+	return nil
+}
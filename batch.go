@@ -0,0 +1,465 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/emyjamalian/laas-ladybug/agent"
+	"github.com/emyjamalian/laas-ladybug/report"
+	"github.com/emyjamalian/laas-ladybug/tools"
+)
+
+const defaultBatchConcurrency = 4
+
+// batchItem is one report from a batch file.
+type batchItem struct {
+	ID string `json:"id,omitempty"`
+	tools.DetectRegressionInput
+}
+
+// batchResult is one line of the results JSONL.
+type batchResult struct {
+	Index  int            `json:"index"`
+	ID     string         `json:"id,omitempty"`
+	Report *report.Report `json:"report,omitempty"`
+	Error  string         `json:"error,omitempty"`
+	// DuplicateOf is the lowest index of the items with the same signature.
+	DuplicateOf *int `json:"duplicate_of,omitempty"`
+}
+
+// batchSummary aggregates a batch run.
+type batchSummary struct {
+	Total      int                   `json:"total"`
+	Succeeded  int                   `json:"succeeded"`
+	Failed     int                   `json:"failed"`
+	Unique     int                   `json:"unique_regressions"`
+	ByPriority map[string]int        `json:"by_priority"`
+	ByType     map[string]int        `json:"by_regression_type"`
+	ByOwner    map[string]int        `json:"by_owner"`
+	TopCPD     []batchTopItem        `json:"top_cpd"`
+	Duplicates []batchDuplicateGroup `json:"duplicates"`
+}
+
+type batchTopItem struct {
+	Index    int     `json:"index"`
+	ID       string  `json:"id,omitempty"`
+	CPDScore float64 `json:"cpd_score"`
+	Priority string  `json:"priority"`
+	Headline string  `json:"headline"`
+}
+
+// batchDuplicateGroup is a set of items that are the same regression.
+type batchDuplicateGroup struct {
+	Signature string   `json:"signature"`
+	Headline  string   `json:"headline"`
+	Count     int      `json:"count"`
+	Indexes   []int    `json:"indexes"`
+	IDs       []string `json:"ids,omitempty"`
+}
+
+type batchOptions struct {
+	concurrency int
+	top         int
+	out         string
+	summary     string
+	model       string
+	tools       toolOptions
+}
+
+func (o *batchOptions) flags() *flag.FlagSet {
+	fs := flag.NewFlagSet("batch", flag.ContinueOnError)
+	fs.IntVar(&o.concurrency, "concurrency", 0, "parallel analyses against the model API (default $LADYBUG_BATCH_CONCURRENCY or 4)")
+	fs.IntVar(&o.top, "top", 10, "how many highest-CPD items the summary lists")
+	fs.StringVar(&o.out, "out", "", "results JSONL `file` (default FILE.results.jsonl)")
+	fs.StringVar(&o.summary, "summary", "", "summary JSON `file` (default FILE.summary.json)")
+	fs.StringVar(&o.model, "model", "", "IONOS model `id` (default $IONOS_MODEL or "+agent.DefaultModel+")")
+	o.tools.register(fs)
+	return fs
+}
+
+// runBatch analyzes every report in a JSONL or CSV file with a bounded
+// worker pool. A failing item is recorded and does not stop the others.
+// Integrations run once per distinct regression signature.
+func runBatch(args []string) error {
+	var o batchOptions
+	rest, err := parseArgs(o.flags(), args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 1 {
+		return usageError("name one JSONL or CSV file")
+	}
+	path, outPath, summaryPath, top := rest[0], o.out, o.summary, o.top
+	concurrency := o.concurrency
+	if concurrency == 0 {
+		concurrency = defaultBatchConcurrency
+		if v := os.Getenv("LADYBUG_BATCH_CONCURRENCY"); v != "" {
+			if concurrency, err = strconv.Atoi(v); err != nil {
+				return fmt.Errorf("LADYBUG_BATCH_CONCURRENCY must be a positive integer")
+			}
+		}
+	}
+	if concurrency < 1 || top < 1 {
+		return usageError("--concurrency and --top must be positive")
+	}
+	w := io.Writer(os.Stdout)
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+	if outPath == "" {
+		outPath = base + ".results.jsonl"
+	}
+	if summaryPath == "" {
+		summaryPath = base + ".summary.json"
+	}
+
+	items, err := readBatch(path)
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return fmt.Errorf("%s contains no reports", path)
+	}
+	integ, err := loadIntegrations()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.Create(outPath)
+	if err != nil {
+		return fmt.Errorf("create results: %w", err)
+	}
+	defer out.Close()
+
+	fmt.Fprintf(w, "Analyzing %d reports with %d workers...\n", len(items), concurrency)
+	a := agent.New()
+	if o.model != "" {
+		a.SetModel(o.model)
+	}
+	if a, err = o.tools.apply(a); err != nil {
+		return err
+	}
+	ctx, stop := interruptible(context.Background())
+	defer stop()
+	results := make([]batchResult, len(items))
+
+	var (
+		mu   sync.Mutex // guards w and done
+		done int
+		jobs = make(chan int)
+		wg   sync.WaitGroup
+	)
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				res := analyzeBatchItem(ctx, a, i, items[i])
+				results[i] = res
+				mu.Lock()
+				done++
+				fmt.Fprintf(w, "[%d/%d] %s\n", done, len(items), describeBatchResult(res))
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := range items {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	// Duplicates are marked once every item is in, so the lowest index
+	// represents each regression whatever order the workers finished in.
+	markDuplicates(results)
+	enc := json.NewEncoder(out)
+	for _, res := range results {
+		if err := enc.Encode(res); err != nil {
+			return fmt.Errorf("write results: %w", err)
+		}
+	}
+	// Tickets, notifications and pages once per regression.
+	for _, res := range results {
+		if res.Report == nil || res.DuplicateOf != nil {
+			continue
+		}
+		var buf strings.Builder
+		if err := integ.publish(ctx, res.Report, &buf); err != nil {
+			buf.WriteString("integrations: " + err.Error() + "\n")
+		}
+		if buf.Len() > 0 {
+			fmt.Fprintf(w, "%s\n%s", describeBatchResult(res), indent(buf.String(), "      "))
+		}
+	}
+
+	summary := summarizeBatch(results, top)
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(summaryPath, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write summary: %w", err)
+	}
+	printBatchSummary(w, summary)
+	fmt.Fprintf(w, "\nResults: %s\nSummary: %s\n", outPath, summaryPath)
+	if summary.Failed == summary.Total {
+		return fmt.Errorf("all %d analyses failed", summary.Total)
+	}
+	return nil
+}
+
+// analyzeBatchItem runs one analysis, turning errors and panics into a
+// failed result so one bad item cannot take down the batch.
+func analyzeBatchItem(ctx context.Context, a *agent.Agent, i int, item batchItem) (res batchResult) {
+	res = batchResult{Index: i, ID: item.ID}
+	defer func() {
+		if r := recover(); r != nil {
+			res.Report, res.Error = nil, fmt.Sprintf("panic: %v", r)
+		}
+	}()
+	if strings.TrimSpace(item.Description) == "" && strings.TrimSpace(item.ErrorMessage) == "" {
+		res.Error = "no description or error message"
+		return res
+	}
+	rep, err := a.Analyze(ctx, agent.FormatInput(item.DetectRegressionInput), io.Discard)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	res.Report = rep
+	return res
+}
+
+// readBatch reads reports from JSONL (one object, or one description string,
+// per line) or CSV with a header row. CSV list columns (files_changed,
+// run_history) are separated by ";".
+func readBatch(path string) ([]batchItem, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return readBatchCSV(f)
+	}
+	var items []batchItem
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		var item batchItem
+		if strings.HasPrefix(line, `"`) {
+			err = json.Unmarshal([]byte(line), &item.Description)
+		} else {
+			err = json.Unmarshal([]byte(line), &item)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		items = append(items, item)
+	}
+	return items, sc.Err()
+}
+
+func readBatchCSV(r io.Reader) ([]batchItem, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	cols := map[string]int{}
+	for i, name := range records[0] {
+		cols[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := cols["description"]; !ok {
+		return nil, fmt.Errorf("CSV header must include a description column")
+	}
+	get := func(rec []string, name string) string {
+		if i, ok := cols[name]; ok && i < len(rec) {
+			return strings.TrimSpace(rec[i])
+		}
+		return ""
+	}
+	list := func(s string) []string {
+		var out []string
+		for _, v := range strings.Split(s, ";") {
+			if v = strings.TrimSpace(v); v != "" {
+				out = append(out, v)
+			}
+		}
+		return out
+	}
+	items := make([]batchItem, 0, len(records)-1)
+	for _, rec := range records[1:] {
+		items = append(items, batchItem{
+			ID: get(rec, "id"),
+			DetectRegressionInput: tools.DetectRegressionInput{
+				Description:  get(rec, "description"),
+				Environment:  get(rec, "environment"),
+				ErrorMessage: get(rec, "error_message"),
+				FilesChanged: list(get(rec, "files_changed")),
+				RunHistory:   list(get(rec, "run_history")),
+			},
+		})
+	}
+	return items, nil
+}
+
+// markDuplicates points each analyzed result at the lowest-indexed result
+// with the same signature.
+func markDuplicates(results []batchResult) {
+	first := map[string]int{}
+	for i := range results {
+		rep := results[i].Report
+		if rep == nil {
+			continue
+		}
+		sig := rep.Signature()
+		if j, ok := first[sig]; ok {
+			results[i].DuplicateOf = &j
+		} else {
+			first[sig] = results[i].Index
+		}
+	}
+}
+
+func summarizeBatch(results []batchResult, top int) batchSummary {
+	s := batchSummary{
+		Total:      len(results),
+		ByPriority: map[string]int{},
+		ByType:     map[string]int{},
+		ByOwner:    map[string]int{},
+		TopCPD:     []batchTopItem{},
+	}
+	groups := map[string]*batchDuplicateGroup{}
+	var order []string
+	for _, res := range results {
+		rep := res.Report
+		if rep == nil {
+			s.Failed++
+			continue
+		}
+		s.Succeeded++
+		s.ByPriority[firstNonEmptyString(rep.Priority(), "untriaged")]++
+		s.ByType[rep.RegressionType()]++
+		s.ByOwner[rep.Component()]++
+		if rep.Triage != nil {
+			s.TopCPD = append(s.TopCPD, batchTopItem{
+				Index:    res.Index,
+				ID:       res.ID,
+				CPDScore: rep.Triage.CPDScore,
+				Priority: rep.Priority(),
+				Headline: rep.Headline(),
+			})
+		}
+		sig := rep.Signature()
+		g, ok := groups[sig]
+		if !ok {
+			g = &batchDuplicateGroup{Signature: sig, Headline: rep.Headline()}
+			groups[sig] = g
+			order = append(order, sig)
+		}
+		g.Count++
+		g.Indexes = append(g.Indexes, res.Index)
+		if res.ID != "" {
+			g.IDs = append(g.IDs, res.ID)
+		}
+	}
+	s.Unique = len(groups)
+	sort.SliceStable(s.TopCPD, func(i, j int) bool { return s.TopCPD[i].CPDScore > s.TopCPD[j].CPDScore })
+	if len(s.TopCPD) > top {
+		s.TopCPD = s.TopCPD[:top]
+	}
+	s.Duplicates = []batchDuplicateGroup{}
+	for _, sig := range order {
+		if g := groups[sig]; g.Count > 1 {
+			s.Duplicates = append(s.Duplicates, *g)
+		}
+	}
+	sort.SliceStable(s.Duplicates, func(i, j int) bool { return s.Duplicates[i].Count > s.Duplicates[j].Count })
+	return s
+}
+
+func printBatchSummary(w io.Writer, s batchSummary) {
+	fmt.Fprintf(w, "\n=== Batch Summary ===\n")
+	fmt.Fprintf(w, "%d reports: %d analyzed, %d failed, %d distinct regressions\n", s.Total, s.Succeeded, s.Failed, s.Unique)
+	printCounts(w, "By priority", s.ByPriority)
+	printCounts(w, "By regression type", s.ByType)
+	printCounts(w, "By owner", s.ByOwner)
+	if len(s.TopCPD) > 0 {
+		fmt.Fprintln(w, "\nTop CPD:")
+		for _, t := range s.TopCPD {
+			fmt.Fprintf(w, "  %8.1f  %-3s #%d %s\n", t.CPDScore, t.Priority, t.Index, t.Headline)
+		}
+	}
+	if len(s.Duplicates) > 0 {
+		fmt.Fprintln(w, "\nDuplicates (merged by signature):")
+		for _, d := range s.Duplicates {
+			fmt.Fprintf(w, "  %dx %s  items %v\n", d.Count, d.Headline, d.Indexes)
+		}
+	}
+}
+
+func printCounts(w io.Writer, title string, counts map[string]int) {
+	if len(counts) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s=%d", k, counts[k])
+	}
+	fmt.Fprintf(w, "%s: %s\n", title, strings.Join(parts, ", "))
+}
+
+func describeBatchResult(res batchResult) string {
+	label := "#" + strconv.Itoa(res.Index)
+	if res.ID != "" {
+		label += " (" + res.ID + ")"
+	}
+	if res.Report == nil {
+		return label + " failed: " + res.Error
+	}
+	rep := res.Report
+	line := fmt.Sprintf("%s %s %s %s — %s", label, firstNonEmptyString(rep.Priority(), "--"), rep.RegressionType(), rep.Component(), rep.Headline())
+	if res.DuplicateOf != nil {
+		line += fmt.Sprintf(" [duplicate of #%d]", *res.DuplicateOf)
+	}
+	return line
+}
+
+func firstNonEmptyString(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+func indent(s, prefix string) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	return prefix + strings.Join(lines, "\n"+prefix) + "\n"
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/emyjamalian/laas-ladybug/report"
+)
+
+func TestMarkDuplicatesPointsAtLowestIndex(t *testing.T) {
+	rep := func(input string) *report.Report { return &report.Report{Input: input} }
+	results := []batchResult{
+		{Index: 0, Report: rep("timeout in checkout")},
+		{Index: 1, Error: "no description or error message"},
+		{Index: 2, Report: rep("NPE in auth/login.go")},
+		{Index: 3, Report: rep("NPE in auth/login.go")},
+		{Index: 4, Report: rep("timeout in checkout")},
+		{Index: 5, Report: rep("NPE in auth/login.go")},
+	}
+	markDuplicates(results)
+	want := map[int]int{3: 2, 4: 0, 5: 2}
+	for _, res := range results {
+		d, dup := want[res.Index]
+		switch {
+		case dup && (res.DuplicateOf == nil || *res.DuplicateOf != d):
+			t.Errorf("#%d: duplicate_of = %v, want %d", res.Index, res.DuplicateOf, d)
+		case !dup && res.DuplicateOf != nil:
+			t.Errorf("#%d: duplicate_of = %d, want none", res.Index, *res.DuplicateOf)
+		}
+	}
+	if s := summarizeBatch(results, 5); s.Unique != 2 || s.Duplicates[0].Indexes[0] != 2 {
+		t.Errorf("summary = %+v, want the same groups", s)
+	}
+}
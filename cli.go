@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// command is one ladybug subcommand. Flags returns a fresh FlagSet with the
+// command's flags; it drives help output and shell completion, and Run
+// builds the same set to parse its arguments. Setup, if set, applies the
+// configuration the command needs before Run, so commands that do not use
+// it keep working when it is broken.
+type command struct {
+	Name    string
+	Args    string
+	Summary string
+	Flags   func() *flag.FlagSet
+	Run     func(args []string) error
+	Setup   func() error
+}
+
+// commands lists every subcommand in help order.
+func commands() []command {
+	return []command{
+		{"analyze", "[DESCRIPTION...]", "Run the full agent analysis (default without a command)", func() *flag.FlagSet { return new(analyzeOptions).flags() }, runAnalyze, configureAgent},
+		{"ask", "[SESSION_ID [QUESTION...]]", "Ask follow-up questions about a saved analysis (lists sessions without an ID)", func() *flag.FlagSet { return new(askOptions).flags() }, runAsk, configureAgent},
+		{"detect", "[DESCRIPTION...]", "Run detect_regression only (needs a description or --error-file)", func() *flag.FlagSet { return new(inputOptions).flags("detect") }, runDetect, configureTools},
+		{"triage", "[DESCRIPTION...]", "Run triage_issue only (detects type/severity from DESCRIPTION if not given)", func() *flag.FlagSet { return new(triageOptions).flags() }, runTriage, configureTools},
+		{"attribute", "DESCRIPTION...", "Run attribute_to_owner only", func() *flag.FlagSet { return new(attributeOptions).flags() }, runAttribute, configureTools},
+		{"plan", "ROOT CAUSE...", "Run generate_fix_plan only", func() *flag.FlagSet { return new(planOptions).flags() }, runPlan, configureTools},
+		{"serve", "[ADDR]", "Receive Alertmanager, Sentry and GitHub Actions webhooks", func() *flag.FlagSet { return new(serveOptions).flags() }, runServe, configureAgent},
+		{"batch", "FILE.jsonl|FILE.csv", "Analyze many reports with a worker pool", func() *flag.FlagSet { return new(batchOptions).flags() }, runBatch, configureAgent},
+		{"rollback", "COMMIT|TAG|ANALYSIS_ID", "Print, and with --execute run, a rollback plan", func() *flag.FlagSet { return new(rollbackOptions).flags() }, runRollback, configureTools},
+		{"mitigate", "", "Turn a feature flag off or on after confirmation", func() *flag.FlagSet { return new(mitigateOptions).flags() }, runMitigate, nil},
+		{"resolve", "ANALYSIS_ID", "Record that a stored regression is fixed", noFlags("resolve"), runResolve, nil},
+		{"tools", "[TOOL...]", "List the tools the model can call with the current configuration", func() *flag.FlagSet { return new(toolsOptions).flags() }, runTools, configureAgent},
+		{"completion", "bash|zsh|fish", "Print a shell completion script", noFlags("completion"), runCompletion, nil},
+		{"help", "[COMMAND]", "Show help for ladybug or a command", noFlags("help"), runHelp, nil},
+	}
+}
+
+func noFlags(name string) func() *flag.FlagSet {
+	return func() *flag.FlagSet { return flag.NewFlagSet(name, flag.ContinueOnError) }
+}
+
+func findCommand(name string) (command, bool) {
+	for _, c := range commands() {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return command{}, false
+}
+
+// usageErr is a bad invocation; the command's help is printed after it.
+type usageErr string
+
+func (e usageErr) Error() string { return string(e) }
+
+// usageError reports a bad invocation with an explanation.
+func usageError(format string, args ...interface{}) error {
+	return usageErr(fmt.Sprintf(format, args...))
+}
+
+// run dispatches to a subcommand. With no arguments, or only flags, it runs
+// "analyze"; a description must name the command explicitly, since its first
+// word could itself be a command ("batch import crashes ...").
+func run(args []string) int {
+	cmd, _ := findCommand("analyze")
+	if len(args) > 0 {
+		switch args[0] {
+		case "-h", "--help":
+			printUsage()
+			return 0
+		}
+		if c, ok := findCommand(args[0]); ok {
+			cmd, args = c, args[1:]
+		} else if !strings.HasPrefix(args[0], "-") {
+			fmt.Fprintf(os.Stderr, "error: unknown command %q; to analyze a description run: ladybug analyze DESCRIPTION...\n", args[0])
+			return 2
+		}
+	}
+	err := setup(cmd, args)
+	if err == nil {
+		err = cmd.Run(args)
+	}
+	var ue usageErr
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, flag.ErrHelp):
+		printCommandHelp(os.Stdout, cmd)
+		return 0
+	case errors.As(err, &ue):
+		fmt.Fprintf(os.Stderr, "error: %s\n\n", ue)
+		printCommandHelp(os.Stderr, cmd)
+		return 2
+	default:
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+}
+
+// setup configures what cmd needs, unless args only ask for its help.
+func setup(cmd command, args []string) error {
+	if cmd.Setup == nil {
+		return nil
+	}
+	if _, err := parseArgs(cmd.Flags(), args); errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	return cmd.Setup()
+}
+
+// parseArgs parses flags that may appear before, between or after
+// positional arguments, and returns the positional arguments. "--" ends
+// flag parsing.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	fs.SetOutput(io.Discard)
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, err
+			}
+			return nil, usageError("%v", err)
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		if args[0] == "--" {
+			return append(positional, args[1:]...), nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func printCommandHelp(w io.Writer, c command) {
+	fmt.Fprintf(w, "Usage: %s\n\n%s.\n", strings.TrimSpace("ladybug "+c.Name+" [flags] "+c.Args), c.Summary)
+	fs := c.Flags()
+	var names []string
+	fs.VisitAll(func(f *flag.Flag) { names = append(names, f.Name) })
+	if len(names) == 0 {
+		return
+	}
+	sort.Strings(names)
+	lefts, usages := make([]string, len(names)), make([]string, len(names))
+	width := 0
+	for i, name := range names {
+		f := fs.Lookup(name)
+		arg, usage := flag.UnquoteUsage(f)
+		lefts[i] = "--" + name
+		if arg != "" {
+			lefts[i] += " " + arg
+		}
+		if f.DefValue != "" && f.DefValue != "false" && f.DefValue != "0" {
+			usage += " (default " + f.DefValue + ")"
+		}
+		usages[i] = usage
+		width = max(width, len(lefts[i]))
+	}
+	fmt.Fprintln(w, "\nFlags:")
+	for i := range names {
+		fmt.Fprintf(w, "  %-*s  %s\n", width, lefts[i], usages[i])
+	}
+}
+
+func runHelp(args []string) error {
+	if len(args) == 0 {
+		printUsage()
+		return nil
+	}
+	c, ok := findCommand(args[0])
+	if !ok {
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+	printCommandHelp(os.Stdout, c)
+	return nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/emyjamalian/laas-ladybug/tools"
+)
+
+// flagValues are the completions offered for flags with a fixed set of
+// values. Flags taking a path complete file names.
+func flagValues() map[string][]string {
+	return map[string][]string{
+		"env":      environmentNames(),
+		"format":   {"text", "json"},
+		"ticket":   {"github", "jira", "webhook"},
+		"type":     regressionTypes(),
+		"severity": {"critical", "high", "medium", "low"},
+		"priority": {"P0", "P1", "P2", "P3"},
+	}
+}
+
+// environmentNames lists the stages of LADYBUG_CPD_MODEL, or of the default
+// model when it is unset or does not load: completion must not fail on a
+// broken configuration.
+func environmentNames() []string {
+	if path := os.Getenv("LADYBUG_CPD_MODEL"); path != "" {
+		if m, err := tools.LoadCPDModel(path); err == nil {
+			return m.EnvironmentNames()
+		}
+	}
+	return tools.CurrentCPDModel().EnvironmentNames()
+}
+
+var fileFlags = map[string]bool{
+	"error-file": true, "diff-file": true, "write-patch": true, "out": true, "summary": true,
+}
+
+func regressionTypes() []string {
+	return []string{
+		string(tools.RegressionTypeNullPointer), string(tools.RegressionTypePerformance),
+		string(tools.RegressionTypeCrash), string(tools.RegressionTypeMemoryLeak),
+		string(tools.RegressionTypeLogicError), string(tools.RegressionTypeDataCorrupt),
+		string(tools.RegressionTypeAPIBreaking), string(tools.RegressionTypeSecurityFlaw),
+		string(tools.RegressionTypeUnknown),
+	}
+}
+
+func commandFlags(c command) []*flag.Flag {
+	var out []*flag.Flag
+	c.Flags().VisitAll(func(f *flag.Flag) { out = append(out, f) })
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// runCompletion prints a completion script for the named shell.
+func runCompletion(args []string) error {
+	if len(args) != 1 {
+		return usageError("name a shell: bash, zsh or fish")
+	}
+	switch args[0] {
+	case "bash":
+		writeBashCompletion(os.Stdout)
+	case "zsh":
+		fmt.Println("autoload -U +X bashcompinit && bashcompinit")
+		writeBashCompletion(os.Stdout)
+	case "fish":
+		writeFishCompletion(os.Stdout)
+	default:
+		return usageError("unsupported shell %q (want bash, zsh or fish)", args[0])
+	}
+	return nil
+}
+
+func writeBashCompletion(w io.Writer) {
+	var names []string
+	for _, c := range commands() {
+		names = append(names, c.Name)
+	}
+	fmt.Fprintln(w, "# ladybug bash completion; load with: source <(ladybug completion bash)")
+	fmt.Fprintln(w, "_ladybug() {")
+	fmt.Fprintln(w, `  local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}"`)
+	fmt.Fprintln(w, `  case "$prev" in`)
+	values := flagValues()
+	for _, name := range sortedKeys(values) {
+		fmt.Fprintf(w, "    --%s) COMPREPLY=($(compgen -W %q -- \"$cur\")); return ;;\n", name, strings.Join(values[name], " "))
+	}
+	var files []string
+	for name := range fileFlags {
+		files = append(files, "--"+name)
+	}
+	sort.Strings(files)
+	fmt.Fprintf(w, "    %s) COMPREPLY=($(compgen -f -- \"$cur\")); return ;;\n", strings.Join(files, "|"))
+	fmt.Fprintln(w, "  esac")
+	fmt.Fprintln(w, `  if [[ $COMP_CWORD -eq 1 ]]; then`)
+	fmt.Fprintf(w, "    COMPREPLY=($(compgen -W %q -- \"$cur\")); return\n", strings.Join(names, " "))
+	fmt.Fprintln(w, "  fi")
+	fmt.Fprintln(w, `  case "${COMP_WORDS[1]}" in`)
+	for _, c := range commands() {
+		var flags []string
+		for _, f := range commandFlags(c) {
+			flags = append(flags, "--"+f.Name)
+		}
+		switch {
+		case c.Name == "help":
+			flags = names
+		case c.Name == "completion":
+			flags = []string{"bash", "zsh", "fish"}
+		}
+		if len(flags) > 0 {
+			fmt.Fprintf(w, "    %s) COMPREPLY=($(compgen -W %q -- \"$cur\")) ;;\n", c.Name, strings.Join(flags, " "))
+		}
+	}
+	fmt.Fprintln(w, "  esac")
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w, "complete -o default -F _ladybug ladybug")
+}
+
+func writeFishCompletion(w io.Writer) {
+	fmt.Fprintln(w, "# ladybug fish completion; load with: ladybug completion fish | source")
+	fmt.Fprintln(w, "complete -c ladybug -f")
+	values := flagValues()
+	for _, c := range commands() {
+		fmt.Fprintf(w, "complete -c ladybug -n __fish_use_subcommand -a %s -d %s\n", c.Name, fishQuote(c.Summary))
+		for _, f := range commandFlags(c) {
+			_, usage := flag.UnquoteUsage(f)
+			line := fmt.Sprintf("complete -c ladybug -n '__fish_seen_subcommand_from %s' -l %s -d %s", c.Name, f.Name, fishQuote(usage))
+			if v, ok := values[f.Name]; ok {
+				line += " -x -a " + fishQuote(strings.Join(v, " "))
+			} else if fileFlags[f.Name] {
+				line += " -r -F"
+			} else if !isBoolFlag(f) {
+				line += " -x"
+			}
+			fmt.Fprintln(w, line)
+		}
+	}
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+func fishQuote(s string) string {
+	return "'" + strings.ReplaceAll(strings.ReplaceAll(s, `\`, `\\`), "'", `\'`) + "'"
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
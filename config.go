@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emyjamalian/laas-ladybug/redact"
+	"github.com/emyjamalian/laas-ladybug/telemetry"
+	"github.com/emyjamalian/laas-ladybug/tools"
+)
+
+// logLevel is the minimum level logged. It defaults to warn so command
+// output is not interleaved with log lines; serve lowers it to info.
+var logLevel = new(slog.LevelVar)
+
+// configureLogging sends structured logs to stderr as LADYBUG_LOG_FORMAT
+// (text or json) at LADYBUG_LOG_LEVEL, scrubbed by the redactor that
+// LADYBUG_REDACT configures: "off", or a JSON file of rules to disable and
+// patterns to add.
+func configureLogging() error {
+	switch v := os.Getenv("LADYBUG_REDACT"); v {
+	case "":
+	case "off":
+		redact.SetDefault(nil)
+	default:
+		r, err := redact.Load(v)
+		if err != nil {
+			return err
+		}
+		redact.SetDefault(r)
+	}
+
+	logLevel.Set(slog.LevelWarn)
+	if v := os.Getenv("LADYBUG_LOG_LEVEL"); v != "" {
+		if err := logLevel.UnmarshalText([]byte(v)); err != nil {
+			return fmt.Errorf("LADYBUG_LOG_LEVEL: %q is not debug, info, warn or error", v)
+		}
+	}
+	opts := &slog.HandlerOptions{Level: logLevel}
+	var h slog.Handler
+	switch f := os.Getenv("LADYBUG_LOG_FORMAT"); f {
+	case "", "text":
+		h = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		h = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("LADYBUG_LOG_FORMAT: %q is not text or json", f)
+	}
+	slog.SetDefault(slog.New(redact.Handler(h)))
+	return nil
+}
+
+// configureTools applies tool configuration from the environment.
+func configureTools() error {
+	if path := os.Getenv("LADYBUG_CPD_MODEL"); path != "" {
+		model, err := tools.LoadCPDModel(path)
+		if err != nil {
+			return err
+		}
+		if err := tools.SetCPDModel(model); err != nil {
+			return err
+		}
+	}
+	if path := os.Getenv("LADYBUG_TRIAGE_POLICY"); path != "" {
+		policy, err := tools.LoadTriagePolicy(path)
+		if err != nil {
+			return err
+		}
+		tools.SetTriagePolicy(policy)
+	}
+	if path := os.Getenv("LADYBUG_DEPLOY_CONFIG"); path != "" {
+		c, err := tools.LoadDeployConfig(path)
+		if err != nil {
+			return err
+		}
+		tools.SetDeployConfig(c)
+	}
+	if p, err := flagProvider(); err != nil {
+		return err
+	} else if p != nil {
+		tools.SetFlagCatalog(p)
+	}
+	tools.SetRepoRoot(os.Getenv("LADYBUG_REPO"))
+	tools.SetRepoAllowlist(splitList(os.Getenv("LADYBUG_REPO_ALLOW")))
+	if v := os.Getenv("LADYBUG_WRITE_TESTS"); v != "" {
+		on, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("LADYBUG_WRITE_TESTS: want true or false, got %q", v)
+		}
+		tools.SetWriteReproTests(on)
+	}
+	return loadToolTimeouts()
+}
+
+// configureAgent applies tool configuration and registers the plugins in
+// LADYBUG_PLUGINS, which runs each of them; only commands that build an
+// agent need it.
+func configureAgent() error {
+	if err := configureTools(); err != nil {
+		return err
+	}
+	return loadPlugins()
+}
+
+// configureTelemetry exports traces and metrics to the OpenTelemetry
+// collector named by OTEL_EXPORTER_OTLP_ENDPOINT, if set, using the standard
+// OTEL_EXPORTER_OTLP_HEADERS, OTEL_SERVICE_NAME and
+// OTEL_METRIC_EXPORT_INTERVAL (milliseconds) variables. The returned
+// function flushes what is left before the process exits.
+func configureTelemetry() (shutdown func(), err error) {
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	if endpoint == "" {
+		return func() {}, nil
+	}
+	if u, err := url.Parse(endpoint); err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("OTEL_EXPORTER_OTLP_ENDPOINT: %q is not a URL", endpoint)
+	}
+	headers := map[string]string{}
+	for _, kv := range splitList(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS")) {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			return nil, fmt.Errorf("OTEL_EXPORTER_OTLP_HEADERS: %q is not key=value", kv)
+		}
+		if unescaped, err := url.QueryUnescape(v); err == nil {
+			v = unescaped
+		}
+		headers[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	interval := 10 * time.Second
+	if ms := os.Getenv("OTEL_METRIC_EXPORT_INTERVAL"); ms != "" {
+		n, err := strconv.Atoi(ms)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("OTEL_METRIC_EXPORT_INTERVAL: %q is not a number of milliseconds", ms)
+		}
+		interval = time.Duration(n) * time.Millisecond
+	}
+	service := firstNonEmptyString(os.Getenv("OTEL_SERVICE_NAME"), "laas-ladybug")
+
+	exporter := telemetry.NewOTLP(endpoint, service, headers, telemetry.Default, interval)
+	telemetry.SetExporter(exporter)
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := exporter.Shutdown(ctx); err != nil {
+			slog.Warn("telemetry export failed", "err", err)
+		}
+	}, nil
+}
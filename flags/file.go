@@ -0,0 +1,126 @@
+package flags
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// File is a local flag file in flagd's format, the file source used by
+// OpenFeature's flagd provider:
+//
+//	{"flags": {"new-login": {"state": "ENABLED", "defaultVariant": "on",
+//	  "variants": {"on": true, "off": false}}}}
+//
+// Turning a boolean flag off serves its false variant; other flags are
+// switched to state DISABLED. Fields Ladybug does not use are preserved.
+type File struct {
+	Path string
+}
+
+func (File) Name() string { return "file" }
+
+type flagdFlag struct {
+	State          string                     `json:"state"`
+	DefaultVariant string                     `json:"defaultVariant"`
+	Variants       map[string]json.RawMessage `json:"variants"`
+}
+
+func (f File) read() (map[string]json.RawMessage, map[string]json.RawMessage, error) {
+	data, err := os.ReadFile(f.Path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read flag file: %w", err)
+	}
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, nil, fmt.Errorf("parse flag file %s: %w", f.Path, err)
+	}
+	var flags map[string]json.RawMessage
+	if raw, ok := doc["flags"]; ok {
+		if err := json.Unmarshal(raw, &flags); err != nil {
+			return nil, nil, fmt.Errorf("parse flag file %s: %w", f.Path, err)
+		}
+	}
+	return doc, flags, nil
+}
+
+func (f File) Keys(ctx context.Context) ([]string, error) {
+	_, flags, err := f.read()
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(flags))
+	for k := range flags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (f File) Get(ctx context.Context, key string) (Flag, error) {
+	_, flags, err := f.read()
+	if err != nil {
+		return Flag{}, err
+	}
+	raw, ok := flags[key]
+	if !ok {
+		return Flag{}, ErrNotFound
+	}
+	var fl flagdFlag
+	if err := json.Unmarshal(raw, &fl); err != nil {
+		return Flag{}, fmt.Errorf("parse flag %s: %w", key, err)
+	}
+	enabled := fl.State != "DISABLED"
+	if v, ok := fl.Variants[fl.DefaultVariant]; ok && string(v) == "false" {
+		enabled = false
+	}
+	return Flag{Key: key, Enabled: enabled, Variant: fl.DefaultVariant}, nil
+}
+
+func (f File) SetEnabled(ctx context.Context, key string, enabled bool) error {
+	doc, flags, err := f.read()
+	if err != nil {
+		return err
+	}
+	raw, ok := flags[key]
+	if !ok {
+		return ErrNotFound
+	}
+	var fields map[string]json.RawMessage
+	var fl flagdFlag
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return fmt.Errorf("parse flag %s: %w", key, err)
+	}
+	json.Unmarshal(raw, &fl)
+
+	// Boolean flags switch variant; anything else is enabled or disabled.
+	variant := ""
+	for name, v := range fl.Variants {
+		if string(v) == fmt.Sprint(enabled) {
+			variant = name
+		}
+	}
+	if variant != "" {
+		fields["defaultVariant"], _ = json.Marshal(variant)
+		fields["state"], _ = json.Marshal("ENABLED")
+	} else if enabled {
+		fields["state"], _ = json.Marshal("ENABLED")
+	} else {
+		fields["state"], _ = json.Marshal("DISABLED")
+	}
+
+	flags[key], _ = json.Marshal(fields)
+	doc["flags"], _ = json.Marshal(flags)
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write-then-rename so flagd never reloads a partial file.
+	tmp := f.Path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write flag file: %w", err)
+	}
+	return os.Rename(tmp, f.Path)
+}
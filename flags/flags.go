@@ -0,0 +1,90 @@
+// Package flags reads and toggles feature flags so a regression behind a
+// flag can be mitigated without a deploy. Providers cover OpenFeature's
+// remote evaluation protocol (OFREP), the LaunchDarkly REST API and a local
+// flagd-format flag file.
+package flags
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ErrNotFound is returned for flag keys the provider does not know.
+var ErrNotFound = errors.New("flag not found")
+
+// ErrReadOnly is returned by providers that can evaluate but not change flags.
+var ErrReadOnly = errors.New("provider is read-only")
+
+// Flag is the current state of one flag.
+type Flag struct {
+	Key     string `json:"key"`
+	Enabled bool   `json:"enabled"`
+	// Variant is the served variant, when the provider reports one.
+	Variant string `json:"variant,omitempty"`
+}
+
+// Provider is a feature-flag backend.
+type Provider interface {
+	Name() string
+	// Keys lists every flag the provider knows.
+	Keys(ctx context.Context) ([]string, error)
+	Get(ctx context.Context, key string) (Flag, error)
+	SetEnabled(ctx context.Context, key string, enabled bool) error
+}
+
+// doJSON sends body (if non-nil) as JSON and decodes a JSON response into out
+// (if non-nil). 404 responses return ErrNotFound; other non-2xx responses are
+// returned as errors.
+func doJSON(ctx context.Context, client *http.Client, method, url string, header http.Header, body, out interface{}) error {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("marshal request: %w", err)
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, r)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s %s returned status %d: %s", method, url, resp.StatusCode, respBody)
+	}
+	if out == nil || len(respBody) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
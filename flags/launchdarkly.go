@@ -0,0 +1,121 @@
+package flags
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const launchDarklyAPIURL = "https://app.launchdarkly.com"
+
+// LaunchDarkly reads and toggles flags in one project environment through
+// the LaunchDarkly REST API (v2). Services exposing a compatible API can be
+// used by overriding APIURL.
+type LaunchDarkly struct {
+	Token       string
+	Project     string
+	Environment string
+	APIURL      string
+	HTTP        *http.Client
+}
+
+func (LaunchDarkly) Name() string { return "launchdarkly" }
+
+func (l LaunchDarkly) url(path string) string {
+	base := l.APIURL
+	if base == "" {
+		base = launchDarklyAPIURL
+	}
+	return strings.TrimRight(base, "/") + "/api/v2/flags/" + url.PathEscape(l.Project) + path +
+		"?env=" + url.QueryEscape(l.Environment)
+}
+
+func (l LaunchDarkly) header() http.Header {
+	return http.Header{"Authorization": {l.Token}}
+}
+
+// Keys lists every flag in the project, following the API's next links
+// until the last page.
+func (l LaunchDarkly) Keys(ctx context.Context) ([]string, error) {
+	base, err := url.Parse(l.url(""))
+	if err != nil {
+		return nil, fmt.Errorf("launchdarkly: %w", err)
+	}
+	next := base.String() + "&limit=100"
+	seen := map[string]bool{}
+	var keys []string
+	for next != "" && !seen[next] {
+		seen[next] = true
+		var resp struct {
+			Items []struct {
+				Key string `json:"key"`
+			} `json:"items"`
+			Links struct {
+				Next struct {
+					Href string `json:"href"`
+				} `json:"next"`
+			} `json:"_links"`
+		}
+		if err := doJSON(ctx, l.HTTP, http.MethodGet, next, l.header(), nil, &resp); err != nil {
+			return nil, fmt.Errorf("launchdarkly: %w", err)
+		}
+		for _, it := range resp.Items {
+			keys = append(keys, it.Key)
+		}
+		next = ""
+		if href := resp.Links.Next.Href; href != "" && len(resp.Items) > 0 {
+			ref, err := url.Parse(href)
+			if err != nil {
+				return nil, fmt.Errorf("launchdarkly: next page %q: %w", href, err)
+			}
+			next = base.ResolveReference(ref).String()
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (l LaunchDarkly) Get(ctx context.Context, key string) (Flag, error) {
+	var resp struct {
+		Environments map[string]struct {
+			On bool `json:"on"`
+		} `json:"environments"`
+	}
+	if err := doJSON(ctx, l.HTTP, http.MethodGet, l.url("/"+url.PathEscape(key)), l.header(), nil, &resp); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return Flag{}, err
+		}
+		return Flag{}, fmt.Errorf("launchdarkly: %w", err)
+	}
+	env, ok := resp.Environments[l.Environment]
+	if !ok {
+		return Flag{}, fmt.Errorf("launchdarkly: flag %s has no environment %q", key, l.Environment)
+	}
+	return Flag{Key: key, Enabled: env.On}, nil
+}
+
+// SetEnabled uses a semantic patch so only the targeting switch changes.
+func (l LaunchDarkly) SetEnabled(ctx context.Context, key string, enabled bool) error {
+	kind := "turnFlagOff"
+	if enabled {
+		kind = "turnFlagOn"
+	}
+	h := l.header()
+	h.Set("Content-Type", "application/json; domain-model=launchdarkly.semanticpatch")
+	body := map[string]interface{}{
+		"environmentKey": l.Environment,
+		"comment":        "LaaS Ladybug regression mitigation",
+		"instructions":   []map[string]string{{"kind": kind}},
+	}
+	if err := doJSON(ctx, l.HTTP, http.MethodPatch, l.url("/"+url.PathEscape(key)), h, body, nil); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return err
+		}
+		return fmt.Errorf("launchdarkly: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package flags
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestLaunchDarklyKeysFollowsNextLinks(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2/flags/web" || r.URL.Query().Get("env") != "production" || r.Header.Get("Authorization") != "api-key" {
+			http.Error(w, "unexpected request "+r.URL.String(), http.StatusBadRequest)
+			return
+		}
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		var items []string
+		for i := offset; i < offset+2 && i < 5; i++ {
+			items = append(items, fmt.Sprintf(`{"key": "flag-%d"}`, i))
+		}
+		next := ""
+		if offset+2 < 5 {
+			next = fmt.Sprintf(`"next": {"href": "/api/v2/flags/web?env=production&limit=2&offset=%d"}`, offset+2)
+		}
+		fmt.Fprintf(w, `{"items": [%s], "_links": {%s}}`, strings.Join(items, ","), next)
+	}))
+	defer srv.Close()
+
+	ld := LaunchDarkly{Token: "api-key", Project: "web", Environment: "production", APIURL: srv.URL}
+	keys, err := ld.Keys(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(keys, ","); got != "flag-0,flag-1,flag-2,flag-3,flag-4" {
+		t.Errorf("Keys = %s, want all five flags across three pages", got)
+	}
+}
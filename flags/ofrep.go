@@ -0,0 +1,92 @@
+package flags
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// OFREP evaluates flags through the OpenFeature Remote Evaluation Protocol,
+// which any OpenFeature-compatible flag service (flagd, GO Feature Flag,
+// Flipt, ...) can serve. The protocol has no write operations, so SetEnabled
+// changes flags in the backend the service serves them from, Writer (e.g.
+// the flagd File behind a flagd endpoint), and returns ErrReadOnly without one.
+type OFREP struct {
+	// URL is the service base URL; /ofrep/v1/... is appended.
+	URL   string
+	Token string
+	// Context is the evaluation context sent with every request, e.g.
+	// {"targetingKey": "ladybug"}.
+	Context map[string]interface{}
+	HTTP    *http.Client
+	// Writer changes flags for SetEnabled; nil makes the provider read-only.
+	Writer Provider
+}
+
+func (OFREP) Name() string { return "ofrep" }
+
+type ofrepEvaluation struct {
+	Key       string      `json:"key"`
+	Value     interface{} `json:"value"`
+	Variant   string      `json:"variant"`
+	ErrorCode string      `json:"errorCode"`
+}
+
+func (o OFREP) header() http.Header {
+	h := http.Header{}
+	if o.Token != "" {
+		h.Set("Authorization", "Bearer "+o.Token)
+	}
+	return h
+}
+
+func (o OFREP) body() map[string]interface{} {
+	ctx := o.Context
+	if ctx == nil {
+		ctx = map[string]interface{}{"targetingKey": "ladybug"}
+	}
+	return map[string]interface{}{"context": ctx}
+}
+
+func (o OFREP) Keys(ctx context.Context) ([]string, error) {
+	var resp struct {
+		Flags []ofrepEvaluation `json:"flags"`
+	}
+	u := strings.TrimRight(o.URL, "/") + "/ofrep/v1/evaluate/flags"
+	if err := doJSON(ctx, o.HTTP, http.MethodPost, u, o.header(), o.body(), &resp); err != nil {
+		return nil, fmt.Errorf("ofrep: %w", err)
+	}
+	keys := make([]string, 0, len(resp.Flags))
+	for _, f := range resp.Flags {
+		keys = append(keys, f.Key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (o OFREP) Get(ctx context.Context, key string) (Flag, error) {
+	var ev ofrepEvaluation
+	u := strings.TrimRight(o.URL, "/") + "/ofrep/v1/evaluate/flags/" + url.PathEscape(key)
+	if err := doJSON(ctx, o.HTTP, http.MethodPost, u, o.header(), o.body(), &ev); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return Flag{}, err
+		}
+		return Flag{}, fmt.Errorf("ofrep: %w", err)
+	}
+	if ev.ErrorCode == "FLAG_NOT_FOUND" {
+		return Flag{}, ErrNotFound
+	}
+	enabled := ev.Value != false && ev.Value != nil
+	return Flag{Key: key, Enabled: enabled, Variant: ev.Variant}, nil
+}
+
+func (o OFREP) SetEnabled(ctx context.Context, key string, enabled bool) error {
+	if o.Writer == nil {
+		return fmt.Errorf("ofrep: %w: change %s in the flag service behind this endpoint", ErrReadOnly, key)
+	}
+	return o.Writer.SetEnabled(ctx, key, enabled)
+}
@@ -5,119 +5,53 @@
 //
 // Usage:
 //
-//	export ANTHROPIC_API_KEY=your_key
-//	echo "NPE crash in auth service after deploying v2.3.1" | go run . [environment]
-//	go run . "null pointer in db/user.go after migration" staging
-//	go run .   # reads from stdin interactively
+//	export IONOS_API_KEY=your_key
+//	echo "NPE crash in auth service after deploying v2.3.1" | go run . analyze --env production
+//	go run . analyze --env staging --files db/user.go "null pointer after migration"
+//	go run .   # interactive: paste a report, watch the tools run, then review, export or file
+//	go run . detect --error-file trace.txt "crash on login"   # run a single tool
+//	go run . serve :8080   # receive Alertmanager, Sentry and GitHub Actions webhooks
+//	go run . analyze --ticket github "NPE in auth/login.go"   # file the result as an issue
+//	go run . analyze --chat "NPE in auth/login.go"   # ask follow-up questions after the report
+//	go run . ask 20261018T100000Z-1a2b3c4d "re-plan assuming this is a flake"   # resume a saved session
+//	go run . batch reports.jsonl --concurrency 8   # analyze many reports after a bad release
+//	go run . resolve 20240101T120000Z-1a2b3c4d   # record the fix time of a stored analysis
+//	go run . rollback v2.3.1 --component auth-service   # print (and with --execute, run) a rollback
+//	go run . mitigate --flag new-login --off   # turn off a feature flag after confirmation
+//	go build -o ladybug . && source <(./ladybug completion bash)   # shell completion
 package main
 
 import (
-	"bufio"
-	"context"
 	"fmt"
 	"os"
-	"strings"
 
-	"github.com/emyjamalian/laas-ladybug/agent"
+	"github.com/emyjamalian/laas-ladybug/report"
 )
 
 func main() {
-	// Determine input: from args, pipe, or interactive prompt.
-	var input string
-	var environment string
-
-	args := os.Args[1:]
-
-	// Check for --help
-	for _, a := range args {
-		if a == "--help" || a == "-h" {
-			printUsage()
-			os.Exit(0)
-		}
+	shutdown := func() {}
+	err := configureLogging()
+	if err == nil {
+		shutdown, err = configureTelemetry()
 	}
-
-	switch len(args) {
-	case 0:
-		// No args — read from stdin (pipe or interactive).
-		stat, _ := os.Stdin.Stat()
-		if (stat.Mode() & os.ModeCharDevice) == 0 {
-			// Piped input.
-			scanner := bufio.NewScanner(os.Stdin)
-			var lines []string
-			for scanner.Scan() {
-				lines = append(lines, scanner.Text())
-			}
-			input = strings.Join(lines, "\n")
-		} else {
-			// Interactive prompt.
-			printBanner()
-			fmt.Print("Describe the bug or paste a diff (press Enter twice when done):\n> ")
-			scanner := bufio.NewScanner(os.Stdin)
-			var lines []string
-			for scanner.Scan() {
-				line := scanner.Text()
-				if line == "" && len(lines) > 0 {
-					break
-				}
-				lines = append(lines, line)
-			}
-			input = strings.Join(lines, "\n")
-			environment = promptEnvironment()
-		}
-	case 1:
-		input = args[0]
-	default:
-		// Last arg can be an environment name.
-		last := strings.ToLower(args[len(args)-1])
-		validEnvs := map[string]bool{
-			"ide": true, "local_test": true, "ci": true,
-			"code_review": true, "staging": true, "production": true,
-		}
-		if validEnvs[last] {
-			input = strings.Join(args[:len(args)-1], " ")
-			environment = last
-		} else {
-			input = strings.Join(args, " ")
-		}
-	}
-
-	if strings.TrimSpace(input) == "" {
-		fmt.Fprintln(os.Stderr, "error: no input provided. Run with --help for usage.")
-		os.Exit(1)
-	}
-
-	// Inject the environment into the input if we detected one.
-	if environment != "" {
-		input = fmt.Sprintf("[Detected in: %s]\n\n%s", environment, input)
-	}
-
-	printBanner()
-
-	a := agent.New()
-	_, err := a.Run(context.Background(), input, os.Stdout)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "\nerror: %v\n", err)
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
 		os.Exit(1)
 	}
+	code := run(os.Args[1:])
+	shutdown()
+	os.Exit(code)
 }
 
-func promptEnvironment() string {
-	envs := []string{"ide", "local_test", "ci", "code_review", "staging", "production"}
-	fmt.Println("\nWhere was this issue detected?")
-	for i, e := range envs {
-		fmt.Printf("  %d) %s\n", i+1, e)
+// writePatch saves the analysis' validated patch to path.
+func writePatch(rep *report.Report, path string) error {
+	switch {
+	case rep.Patch == nil:
+		return fmt.Errorf("no patch was proposed (is LADYBUG_REPO set?)")
+	case !rep.Patch.Valid:
+		return fmt.Errorf("proposed patch was rejected: %s", rep.Patch.Rejection)
 	}
-	fmt.Print("> ")
-	scanner := bufio.NewScanner(os.Stdin)
-	if scanner.Scan() {
-		choice := strings.TrimSpace(scanner.Text())
-		for i, e := range envs {
-			if choice == fmt.Sprintf("%d", i+1) || strings.EqualFold(choice, e) {
-				return e
-			}
-		}
-	}
-	return "production" // conservative default
+	return os.WriteFile(path, []byte(rep.Patch.Patch), 0o644)
 }
 
 func printBanner() {
@@ -136,20 +70,169 @@ func printUsage() {
 	fmt.Println(`LaaS Ladybug — Fix Fast Agent
 
 USAGE:
-  go run . "bug description" [environment]
-  echo "bug description" | go run .
-  go run .   # interactive mode
-
-ENVIRONMENTS:
-  ide, local_test, ci, code_review, staging, production
+  ladybug [COMMAND] [flags] [args]    (go run . works the same way)
+
+COMMANDS:
+  analyze [DESCRIPTION...]   Run the full agent analysis (the default when no command is
+                             given; a description must follow "analyze"). Without a
+                             description, reads a pipe or opens the interactive review:
+                             paste a report (end with "." or Ctrl-D), pick the stage, watch
+                             each tool, correct severity/priority/stage, export or file
+  ask [SESSION_ID [QUESTION...]]
+                             Ask follow-up questions about a saved analysis; lists
+                             saved sessions without an ID
+  detect [DESCRIPTION...]    Run detect_regression only (a description or --error-file)
+  triage [DESCRIPTION...]    Run triage_issue only (--type/--severity, or detected)
+  attribute DESCRIPTION...   Run attribute_to_owner only (--files required)
+  plan ROOT CAUSE...         Run generate_fix_plan only (--type required)
+  serve [ADDR]               Webhook receiver (default :8080)
+  batch FILE                 Analyze a JSONL or CSV file of reports
+  rollback COMMIT|TAG|ID     Print, and with --execute run, a rollback plan
+  mitigate --flag KEY --off  Turn off a feature flag after confirmation
+  resolve ANALYSIS_ID        Record that a stored regression is fixed
+  tools [TOOL...]            List the tools the model can call and their timeouts
+                             (--schema: their JSON schemas, generated from the input
+                             structs; --prompt: the system prompt)
+  completion bash|zsh|fish   Print a shell completion script
+  help [COMMAND]             Show a command's flags
+
+COMMON FLAGS (analyze, detect, triage, attribute, plan):
+  --env STAGE          ide, local_test, ci, code_review, staging, production
+                       (or a custom stage from LADYBUG_CPD_MODEL)
+  --files A,B          files changed by the suspected commit
+  --error-file FILE    error message or stack trace (- for stdin)
+  --run-history LIST   recent results oldest first, e.g. pass,fail,fail
+  --users N            estimated affected users
+  --format text|json   output format
+  --model ID           model override (analyze, ask, batch, serve)
+  --chat               ask follow-up questions once the analysis completes (analyze)
+  --allow-tools LIST   only offer these tools to the model; names or globs (analyze, batch,
+  --deny-tools LIST    serve, tools), e.g. --deny-tools propose_patch,git_*
 
 EXAMPLES:
-  go run . "NPE in auth/login.go after v2.3 deploy" production
-  go run . "slow query after adding user_preferences column" staging
-  go run . "security: SQL injection in search handler" production
-  echo "panic: runtime error: index out of range" | go run .
+  ladybug analyze --env production "NPE in auth/login.go after v2.3 deploy"
+  ladybug analyze --env staging "slow query after adding user_preferences column"
+  ladybug triage --type security_flaw --severity critical --env production --users 5000
+  echo "panic: runtime error: index out of range" | ladybug analyze --format json
 
 ENVIRONMENT VARIABLES:
   IONOS_API_KEY   Your IONOS AI Model Hub bearer token (required)
-  IONOS_MODEL     Model ID to use (default: meta-llama/Llama-3.3-70B-Instruct)`)
+  IONOS_MODEL     Model ID to use (default: meta-llama/Llama-3.3-70B-Instruct)
+  NO_COLOR        Disable colours in the interactive review
+
+TOOL PLUGINS (external executables; "ladybug tools" lists what is loaded):
+  LADYBUG_PLUGINS   PATH-style list of plugin executables or directories of them.
+                    "PLUGIN --describe" prints [{"name", "description", "parameters"}];
+                    a call gets {"tool", "input"} on stdin and replies {"output"} or {"error"}
+  LADYBUG_TOOL_TIMEOUTS   Per-call tool limits: a default and/or name=duration pairs, e.g.
+                          "45s,generate_repro_test=5m" (default 30s; generate_repro_test 3m,
+                          propose_patch 2m; 0 = no limit). A call that runs over is stopped
+                          and the model told it timed out. Ctrl-C cancels a running analysis
+
+SESSIONS (every analysis is saved with its conversation so it can be resumed with "ask"):
+  LADYBUG_SESSIONS    Session directory (default $LADYBUG_STORE/sessions, else the user
+                      cache directory; "off" disables persistence)
+  LADYBUG_API_TOKEN   Enables the session API in serve mode (Authorization: Bearer <token>):
+                      POST /sessions {"input"}, GET /sessions/{id},
+                      POST /sessions/{id}/messages {"question"} → {"answer", "report"}
+
+WEBHOOKS (serve mode; a source is only enabled with its secret, or unauthenticated with
+serve --insecure; payloads over 1 MiB get 413, a full queue 503):
+  POST /webhooks/alertmanager   Prometheus Alertmanager (LADYBUG_ALERTMANAGER_TOKEN: bearer token)
+  POST /webhooks/sentry         Sentry issue / event_alert (LADYBUG_SENTRY_SECRET: client secret)
+  POST /webhooks/github         GitHub workflow_run (LADYBUG_GITHUB_WEBHOOK_SECRET: webhook secret,
+                                GITHUB_TOKEN: optional, fetches changed files and run history)
+
+TICKETS (analyze --ticket, or LADYBUG_TICKET_SINK in serve mode):
+  github    LADYBUG_GITHUB_REPO (owner/name), GITHUB_TOKEN
+  jira      JIRA_BASE_URL, JIRA_USER, JIRA_API_TOKEN, JIRA_PROJECT, JIRA_ISSUE_TYPE (default Bug)
+  webhook   LADYBUG_TICKET_WEBHOOK_URL, LADYBUG_TICKET_TEMPLATE (text/template file),
+            LADYBUG_TICKET_DEDUPE_URL (GET, {signature} placeholder), LADYBUG_TICKET_WEBHOOK_TOKEN
+  LADYBUG_ASSIGNEES   JSON file mapping component to assignee: a GitHub login or a Jira
+                      account ID
+  analyze --dry-run   print the ticket payload instead of filing it
+
+NOTIFICATIONS:
+  LADYBUG_SLACK_WEBHOOK_URL     Slack incoming webhook (Block Kit message)
+  LADYBUG_TEAMS_WEBHOOK_URL     Microsoft Teams incoming webhook (Adaptive Card)
+  LADYBUG_NOTIFY_WEBHOOK_URL    Generic webhook (JSON summary)
+  LADYBUG_NOTIFY_MIN_PRIORITY   Lowest priority that notifies (default P1)
+
+TRIAGE:
+  LADYBUG_CPD_MODEL   JSON file customising the CPD model: environments (multiplier, shift_left),
+                      severity_scores, user_impact (step|log), revenue_weight, sla_weights,
+                      thresholds and org_thresholds
+  LADYBUG_TRIAGE_POLICY   JSON file enabling time-aware triage: slo_file or prometheus
+                          {url, target, budget_query, burn_rate_query}, release_freezes,
+                          business_hours {timezone, days, start, end}, default_service
+
+REPOSITORY:
+  LADYBUG_REPO   Path to a local checkout; fix plans are tailored to its languages,
+                 test/bench/lint commands and CI configuration, and the propose_patch,
+                 read_file, search_code, list_dir and git_log tools are enabled
+  LADYBUG_REPO_ALLOW   Comma-separated globs of files the browsing tools may read
+                       (default: common source, build and config files). .git, .env,
+                       keys and other secrets are never readable
+  LADYBUG_WRITE_TESTS   true to let generate_repro_test write its test into LADYBUG_REPO and
+                        compile it (default false: the test is only returned in the report)
+  analyze --write-patch FILE   save the validated patch from propose_patch to FILE
+
+BATCH (one report per JSONL line: a description string or an object with id, description,
+environment, error_message, files_changed, run_history; or CSV with those columns and
+";"-separated lists). Integrations run once per distinct regression signature:
+  LADYBUG_BATCH_CONCURRENCY   Parallel analyses against the model API (default 4)
+
+ROLLBACK (plan_rollback tool and the rollback command; plans are never run without --execute
+and typing "rollback" to confirm):
+  LADYBUG_DEPLOY_CONFIG   JSON file mapping components to deploy targets:
+                          {"default": {...}, "components": {"auth-service": {
+                            "kubernetes": {"deployment": "auth", "namespace": "prod", "context": "prod-eu"},
+                            "helm": {"release": "auth", "namespace": "prod"},
+                            "feature_flags": ["new-login"], "flag_command": "ldcli flags toggle-off --flag {flag}"}}}
+
+FEATURE FLAGS (flags referenced by the change become mitigation candidates; mitigate
+asks you to retype the flag key unless --confirm KEY is given):
+  LADYBUG_FLAGS_PROVIDER   file, ofrep or launchdarkly
+  file           LADYBUG_FLAGS_FILE (flagd-format JSON, as used by OpenFeature's flagd)
+  ofrep          OFREP_URL, OFREP_TOKEN, OFREP_CONTEXT (JSON evaluation context); mitigate
+                 also needs LADYBUG_FLAGS_FILE, the flagd file the OFREP service serves
+  launchdarkly   LAUNCHDARKLY_API_TOKEN, LAUNCHDARKLY_PROJECT, LAUNCHDARKLY_ENVIRONMENT,
+                 LAUNCHDARKLY_API_URL (for compatible services)
+
+ON-CALL PAGING (P0 only; webhook incidents are keyed by alert fingerprint, Sentry issue or
+workflow and branch, and resolved by a resolved alert or issue or a passing run; only paged
+incidents are resolved, remembered in $LADYBUG_STORE/incidents):
+  LADYBUG_ONCALL_ROUTING   JSON file mapping components to PagerDuty routing keys /
+                           Opsgenie responders: {"default": {...}, "components": {"auth-service": {...}}}
+  OPSGENIE_API_KEY         Opsgenie API integration key (OPSGENIE_API_URL for the EU instance)
+
+TELEMETRY (spans around each analysis, model request and tool call; Prometheus metrics on
+/metrics in serve mode, e.g. ladybug_analyses_total, ladybug_tool_calls_total,
+ladybug_model_request_duration_seconds):
+  OTEL_EXPORTER_OTLP_ENDPOINT   OpenTelemetry collector, OTLP/HTTP JSON (e.g. http://localhost:4318);
+                                traces and metrics are exported when set
+  OTEL_EXPORTER_OTLP_HEADERS    Extra headers, e.g. "authorization=Bearer%20token"
+  OTEL_SERVICE_NAME             Service name (default laas-ladybug)
+  OTEL_METRIC_EXPORT_INTERVAL   Export interval in milliseconds (default 10000)
+
+RECORD/REPLAY (deterministic runs without the API; a directory of <request hash>.json
+files, one per model request):
+  LADYBUG_RECORD   Save every model request and response in this directory
+  LADYBUG_REPLAY   Answer model requests from this directory instead of the API (no
+                   IONOS_API_KEY needed); an unrecorded request fails
+
+LOGGING (structured logs on stderr; secrets and personal data are redacted from logs and
+from everything sent to the model, and each analysis audits what was redacted):
+  LADYBUG_LOG_LEVEL    debug, info, warn or error (default warn; info in serve mode)
+  LADYBUG_LOG_FORMAT   text or json (default text)
+  LADYBUG_REDACT       "off", or a JSON file adjusting the built-in rules (api_key,
+                       bearer_token, jwt, url_password, email, ipv4, ipv6), e.g.
+                       {"disable": ["ipv4"], "patterns": [{"name": "customer_id", "regex": "CUST-[0-9]{6}"}]}
+
+STORAGE:
+  LADYBUG_STORE      Directory to persist analyses in (enables links in notifications).
+                     Resolved analyses (via "resolve" or a passing run history) give
+                     effort estimates real time-to-fix data
+  LADYBUG_BASE_URL   Public URL of serve mode; links become <base>/analyses/<id>, served
+                     with LADYBUG_API_TOKEN (Authorization: Bearer <token>)`)
 }
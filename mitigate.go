@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/emyjamalian/laas-ladybug/flags"
+)
+
+// flagProvider builds the feature-flag provider named by LADYBUG_FLAGS_PROVIDER,
+// or returns nil if none is configured.
+func flagProvider() (flags.Provider, error) {
+	switch name := os.Getenv("LADYBUG_FLAGS_PROVIDER"); name {
+	case "":
+		return nil, nil
+	case "file":
+		path := os.Getenv("LADYBUG_FLAGS_FILE")
+		if path == "" {
+			return nil, fmt.Errorf("flag provider file: LADYBUG_FLAGS_FILE is not set")
+		}
+		return flags.File{Path: path}, nil
+	case "ofrep":
+		p := flags.OFREP{URL: os.Getenv("OFREP_URL"), Token: os.Getenv("OFREP_TOKEN")}
+		if p.URL == "" {
+			return nil, fmt.Errorf("flag provider ofrep: OFREP_URL is not set")
+		}
+		if c := os.Getenv("OFREP_CONTEXT"); c != "" {
+			if err := json.Unmarshal([]byte(c), &p.Context); err != nil {
+				return nil, fmt.Errorf("flag provider ofrep: OFREP_CONTEXT: %w", err)
+			}
+		}
+		// OFREP cannot change flags; writes go to the flag file it serves.
+		if path := os.Getenv("LADYBUG_FLAGS_FILE"); path != "" {
+			p.Writer = flags.File{Path: path}
+		}
+		return p, nil
+	case "launchdarkly":
+		p := flags.LaunchDarkly{
+			Token:       os.Getenv("LAUNCHDARKLY_API_TOKEN"),
+			Project:     os.Getenv("LAUNCHDARKLY_PROJECT"),
+			Environment: os.Getenv("LAUNCHDARKLY_ENVIRONMENT"),
+			APIURL:      os.Getenv("LAUNCHDARKLY_API_URL"),
+		}
+		if p.Token == "" || p.Project == "" || p.Environment == "" {
+			return nil, fmt.Errorf("flag provider launchdarkly: LAUNCHDARKLY_API_TOKEN, LAUNCHDARKLY_PROJECT and LAUNCHDARKLY_ENVIRONMENT are required")
+		}
+		return p, nil
+	default:
+		return nil, fmt.Errorf("unknown flag provider %q (want file, ofrep or launchdarkly)", name)
+	}
+}
+
+type mitigateOptions struct {
+	key, confirm    string
+	on, off, dryRun bool
+}
+
+func (o *mitigateOptions) flags() *flag.FlagSet {
+	fs := flag.NewFlagSet("mitigate", flag.ContinueOnError)
+	fs.StringVar(&o.key, "flag", "", "feature flag `key`")
+	fs.BoolVar(&o.off, "off", false, "turn the flag off")
+	fs.BoolVar(&o.on, "on", false, "turn the flag back on")
+	fs.StringVar(&o.confirm, "confirm", "", "confirm non-interactively by repeating the flag `key`")
+	fs.BoolVar(&o.dryRun, "dry-run", false, "show the change without making it")
+	return fs
+}
+
+// runMitigate turns a feature flag off (or back on). It shows the flag's
+// current state and only changes it after the operator retypes the flag key,
+// or passes it with --confirm for scripted use.
+func runMitigate(args []string) error {
+	var o mitigateOptions
+	rest, err := parseArgs(o.flags(), args)
+	if err != nil {
+		return err
+	}
+	switch {
+	case len(rest) > 0:
+		return usageError("unexpected argument %q", rest[0])
+	case o.key == "":
+		return usageError("--flag is required")
+	case o.on == o.off:
+		return usageError("give exactly one of --off or --on")
+	}
+	key, confirm, on, dryRun := o.key, o.confirm, o.on, o.dryRun
+	stdin, w := io.Reader(os.Stdin), io.Writer(os.Stdout)
+
+	p, err := flagProvider()
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return fmt.Errorf("no flag provider configured (set LADYBUG_FLAGS_PROVIDER)")
+	}
+	if o, ok := p.(flags.OFREP); ok && o.Writer == nil {
+		return fmt.Errorf("flag provider ofrep is read-only: set LADYBUG_FLAGS_FILE to the flagd file served at OFREP_URL, or use a writable provider")
+	}
+
+	ctx := context.Background()
+	current, err := p.Get(ctx, key)
+	if err != nil {
+		return fmt.Errorf("flag %s: %w", key, err)
+	}
+	state := map[bool]string{true: "on", false: "off"}
+	fmt.Fprintf(w, "Flag %s (%s) is currently %s\n", key, p.Name(), state[current.Enabled])
+	if current.Enabled == on {
+		fmt.Fprintln(w, "Nothing to do.")
+		return nil
+	}
+	if dryRun {
+		fmt.Fprintf(w, "Dry run: would turn %s %s.\n", key, state[on])
+		return nil
+	}
+
+	if confirm == "" {
+		fmt.Fprintf(w, "Type the flag key to turn it %s: ", state[on])
+		line, _ := bufio.NewReader(stdin).ReadString('\n')
+		confirm = strings.TrimSpace(line)
+	}
+	if confirm != key {
+		return fmt.Errorf("confirmation did not match %q; flag unchanged", key)
+	}
+	if err := p.SetEnabled(ctx, key, on); err != nil {
+		return fmt.Errorf("flag %s: %w", key, err)
+	}
+	fmt.Fprintf(w, "Turned %s %s.\n", key, state[on])
+	return nil
+}
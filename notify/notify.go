@@ -0,0 +1,125 @@
+// Package notify posts incident notifications for high-priority analyses to
+// chat tools (Slack, Microsoft Teams) or a generic webhook.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/emyjamalian/laas-ladybug/report"
+	"github.com/emyjamalian/laas-ladybug/tools"
+)
+
+// maxActions is how many immediate actions a notification carries.
+const maxActions = 3
+
+// Summary is the compact content of a notification.
+type Summary struct {
+	Title          string   `json:"title"`
+	Priority       string   `json:"priority"`
+	CPDScore       float64  `json:"cpd_score"`
+	RegressionType string   `json:"regression_type"`
+	Severity       string   `json:"severity"`
+	Owner          string   `json:"owner"`
+	Actions        []string `json:"actions"`
+	Link           string   `json:"link,omitempty"`
+	Signature      string   `json:"signature"`
+}
+
+// Summarize condenses a report. link points back at the stored analysis and
+// may be empty.
+func Summarize(r *report.Report, link string) Summary {
+	s := Summary{
+		Title:          r.Headline(),
+		Priority:       r.Priority(),
+		RegressionType: r.RegressionType(),
+		Severity:       r.Severity(),
+		Owner:          r.Component(),
+		Link:           link,
+		Signature:      r.Signature(),
+	}
+	if r.Triage != nil {
+		s.CPDScore = r.Triage.CPDScore
+	}
+	if r.FixPlan != nil {
+		s.Actions = r.FixPlan.ImmediateActions
+	}
+	if len(s.Actions) == 0 && r.Triage != nil {
+		s.Actions = []string{r.Triage.RecommendedAction}
+	}
+	if len(s.Actions) > maxActions {
+		s.Actions = s.Actions[:maxActions]
+	}
+	return s
+}
+
+// Notifier delivers a Summary to one destination.
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, s Summary) error
+}
+
+// Dispatcher sends notifications for reports at or above MinPriority.
+type Dispatcher struct {
+	Notifiers   []Notifier
+	MinPriority tools.Priority
+}
+
+// Dispatch notifies every configured destination if r's priority meets the
+// threshold. It reports whether a notification was attempted; failures of
+// individual notifiers are joined into the returned error.
+func (d Dispatcher) Dispatch(ctx context.Context, r *report.Report, link string) (bool, error) {
+	min := d.MinPriority
+	if min == "" {
+		min = tools.PriorityP1
+	}
+	if len(d.Notifiers) == 0 || !tools.Priority(r.Priority()).AtLeast(min) {
+		return false, nil
+	}
+	s := Summarize(r, link)
+	var errs []error
+	for _, n := range d.Notifiers {
+		if err := n.Notify(ctx, s); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", n.Name(), err))
+		}
+	}
+	return true, errors.Join(errs...)
+}
+
+// postJSON posts payload to url and treats any non-2xx status as an error.
+func postJSON(ctx context.Context, client *http.Client, url string, header http.Header, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshal payload: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("webhook returned status %d: %s", resp.StatusCode, respBody)
+	}
+	return nil
+}
+
+func headline(s Summary) string {
+	return fmt.Sprintf("%s %s regression: %s", s.Priority, s.RegressionType, s.Title)
+}
@@ -0,0 +1,159 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/emyjamalian/laas-ladybug/report"
+	"github.com/emyjamalian/laas-ladybug/tools"
+)
+
+// standIn is a local HTTP server that records the requests a notifier
+// sends and answers with status.
+type standIn struct {
+	*httptest.Server
+	status   int
+	requests []*http.Request
+	bodies   []map[string]interface{}
+}
+
+func newStandIn(t *testing.T, status int) *standIn {
+	s := &standIn{status: status}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var body map[string]interface{}
+		if err := json.Unmarshal(data, &body); err != nil {
+			t.Errorf("request body is not a JSON object: %v\n%s", err, data)
+		}
+		s.requests = append(s.requests, r)
+		s.bodies = append(s.bodies, body)
+		w.WriteHeader(s.status)
+		io.WriteString(w, "stand-in says hi")
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func testReport(priority tools.Priority) *report.Report {
+	r := report.New("NPE in auth/login.go after deploy")
+	r.DetectInput = &tools.DetectRegressionInput{Description: "NPE in auth/login.go after deploy", ErrorMessage: "panic: nil pointer"}
+	r.Detection = &tools.DetectRegressionOutput{RegressionType: "null_pointer", Severity: "critical"}
+	r.Triage = &tools.TriageIssueOutput{Priority: priority, CPDScore: 10000, RecommendedAction: "Roll back now"}
+	r.Attribution = &tools.AttributeIssueOutput{HighestConfidence: "auth-service"}
+	r.FixPlan = &tools.GenerateFixPlanOutput{ImmediateActions: []string{"Roll back v2.3.1", "Page auth on-call", "Add nil check", "Write a test"}}
+	return r
+}
+
+func TestSummarize(t *testing.T) {
+	s := Summarize(testReport(tools.PriorityP0), "https://ladybug.example.com/analyses/1")
+	if s.Title != "NPE in auth/login.go after deploy" || s.Priority != "P0" || s.Owner != "auth-service" || s.CPDScore != 10000 {
+		t.Errorf("Summarize = %+v", s)
+	}
+	if len(s.Actions) != maxActions {
+		t.Errorf("%d actions, want the first %d", len(s.Actions), maxActions)
+	}
+}
+
+func TestSlack(t *testing.T) {
+	srv := newStandIn(t, http.StatusOK)
+	d := Dispatcher{Notifiers: []Notifier{Slack{WebhookURL: srv.URL}}}
+	sent, err := d.Dispatch(context.Background(), testReport(tools.PriorityP0), "https://ladybug.example.com/analyses/1")
+	if !sent || err != nil {
+		t.Fatalf("Dispatch = %v, %v", sent, err)
+	}
+	if len(srv.bodies) != 1 {
+		t.Fatalf("stand-in got %d requests, want 1", len(srv.bodies))
+	}
+	if ct := srv.requests[0].Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	body := srv.bodies[0]
+	if text, _ := body["text"].(string); !strings.Contains(text, "P0 null_pointer regression") {
+		t.Errorf("fallback text = %q", text)
+	}
+	blocks, _ := body["blocks"].([]interface{})
+	if len(blocks) != 4 {
+		t.Fatalf("%d blocks, want header, fields, actions and button", len(blocks))
+	}
+	button := blocks[3].(map[string]interface{})["elements"].([]interface{})[0].(map[string]interface{})
+	if button["url"] != "https://ladybug.example.com/analyses/1" {
+		t.Errorf("button url = %v", button["url"])
+	}
+}
+
+func TestTeams(t *testing.T) {
+	srv := newStandIn(t, http.StatusAccepted)
+	if err := (Teams{WebhookURL: srv.URL}).Notify(context.Background(), Summarize(testReport(tools.PriorityP1), "")); err != nil {
+		t.Fatal(err)
+	}
+	body := srv.bodies[0]
+	if body["type"] != "message" {
+		t.Errorf("type = %v, want message", body["type"])
+	}
+	attachment := body["attachments"].([]interface{})[0].(map[string]interface{})
+	if attachment["contentType"] != "application/vnd.microsoft.card.adaptive" {
+		t.Errorf("contentType = %v", attachment["contentType"])
+	}
+	card := attachment["content"].(map[string]interface{})
+	if card["type"] != "AdaptiveCard" {
+		t.Errorf("card type = %v", card["type"])
+	}
+	if _, ok := card["actions"]; ok {
+		t.Error("card has a View analysis action without a link")
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	srv := newStandIn(t, http.StatusNoContent)
+	w := Webhook{URL: srv.URL, Header: http.Header{"Authorization": {"Bearer tok"}}}
+	if err := w.Notify(context.Background(), Summarize(testReport(tools.PriorityP0), "")); err != nil {
+		t.Fatal(err)
+	}
+	if got := srv.requests[0].Header.Get("Authorization"); got != "Bearer tok" {
+		t.Errorf("Authorization = %q", got)
+	}
+	body := srv.bodies[0]
+	if body["priority"] != "P0" || body["owner"] != "auth-service" || body["signature"] == "" {
+		t.Errorf("body = %v", body)
+	}
+}
+
+func TestNotifyErrorStatus(t *testing.T) {
+	srv := newStandIn(t, http.StatusInternalServerError)
+	err := (Webhook{URL: srv.URL}).Notify(context.Background(), Summary{})
+	if err == nil || !strings.Contains(err.Error(), "status 500") || !strings.Contains(err.Error(), "stand-in says hi") {
+		t.Errorf("Notify = %v, want the status and body", err)
+	}
+}
+
+func TestDispatchThreshold(t *testing.T) {
+	srv := newStandIn(t, http.StatusOK)
+	d := Dispatcher{Notifiers: []Notifier{Webhook{URL: srv.URL}}}
+	for _, p := range []tools.Priority{tools.PriorityP2, tools.PriorityP3, ""} {
+		if sent, err := d.Dispatch(context.Background(), testReport(p), ""); sent || err != nil {
+			t.Errorf("%q: Dispatch = %v, %v, want nothing sent below the default P1", p, sent, err)
+		}
+	}
+	d.MinPriority = tools.PriorityP2
+	if sent, _ := d.Dispatch(context.Background(), testReport(tools.PriorityP2), ""); !sent {
+		t.Error("P2 report not sent with MinPriority P2")
+	}
+	if len(srv.bodies) != 1 {
+		t.Errorf("stand-in got %d requests, want 1", len(srv.bodies))
+	}
+
+	// One failing destination does not stop the others.
+	bad := newStandIn(t, http.StatusBadGateway)
+	d.Notifiers = []Notifier{Webhook{URL: bad.URL}, Webhook{URL: srv.URL}}
+	if sent, err := d.Dispatch(context.Background(), testReport(tools.PriorityP0), ""); !sent || err == nil {
+		t.Errorf("Dispatch = %v, %v, want sent with an error", sent, err)
+	}
+	if len(srv.bodies) != 2 {
+		t.Errorf("healthy stand-in got %d requests, want 2", len(srv.bodies))
+	}
+}
@@ -0,0 +1,66 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Slack posts Block Kit messages to a Slack incoming webhook.
+type Slack struct {
+	WebhookURL string
+	HTTP       *http.Client
+}
+
+func (Slack) Name() string { return "slack" }
+
+func (s Slack) Notify(ctx context.Context, sum Summary) error {
+	return postJSON(ctx, s.HTTP, s.WebhookURL, nil, slackPayload(sum))
+}
+
+func slackPayload(s Summary) map[string]interface{} {
+	text := func(t string) map[string]string { return map[string]string{"type": "mrkdwn", "text": t} }
+
+	var actions strings.Builder
+	for _, a := range s.Actions {
+		fmt.Fprintf(&actions, "• %s\n", a)
+	}
+
+	blocks := []interface{}{
+		map[string]interface{}{
+			"type": "header",
+			"text": map[string]string{"type": "plain_text", "text": ":rotating_light: " + headline(s)},
+		},
+		map[string]interface{}{
+			"type": "section",
+			"fields": []interface{}{
+				text("*Priority*\n" + s.Priority),
+				text(fmt.Sprintf("*CPD*\n%.0f", s.CPDScore)),
+				text("*Suspected owner*\n" + s.Owner),
+				text("*Severity*\n" + s.Severity),
+			},
+		},
+	}
+	if actions.Len() > 0 {
+		blocks = append(blocks, map[string]interface{}{
+			"type": "section",
+			"text": text("*Immediate actions*\n" + actions.String()),
+		})
+	}
+	if s.Link != "" {
+		blocks = append(blocks, map[string]interface{}{
+			"type": "actions",
+			"elements": []interface{}{map[string]interface{}{
+				"type": "button",
+				"text": map[string]string{"type": "plain_text", "text": "View analysis"},
+				"url":  s.Link,
+			}},
+		})
+	}
+	return map[string]interface{}{
+		// text is the fallback shown in notifications and by older clients.
+		"text":   headline(s),
+		"blocks": blocks,
+	}
+}
@@ -0,0 +1,65 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Teams posts Adaptive Cards to a Microsoft Teams incoming webhook
+// (or a Power Automate "post to a channel when a webhook request is received" flow).
+type Teams struct {
+	WebhookURL string
+	HTTP       *http.Client
+}
+
+func (Teams) Name() string { return "teams" }
+
+func (t Teams) Notify(ctx context.Context, s Summary) error {
+	return postJSON(ctx, t.HTTP, t.WebhookURL, nil, teamsPayload(s))
+}
+
+func teamsPayload(s Summary) map[string]interface{} {
+	body := []interface{}{
+		map[string]interface{}{
+			"type":   "TextBlock",
+			"text":   headline(s),
+			"weight": "Bolder",
+			"size":   "Medium",
+			"color":  "Attention",
+			"wrap":   true,
+		},
+		map[string]interface{}{
+			"type": "FactSet",
+			"facts": []interface{}{
+				map[string]string{"title": "Priority", "value": s.Priority},
+				map[string]string{"title": "CPD", "value": fmt.Sprintf("%.0f", s.CPDScore)},
+				map[string]string{"title": "Suspected owner", "value": s.Owner},
+				map[string]string{"title": "Severity", "value": s.Severity},
+			},
+		},
+	}
+	for _, a := range s.Actions {
+		body = append(body, map[string]interface{}{"type": "TextBlock", "text": "- " + a, "wrap": true})
+	}
+	card := map[string]interface{}{
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":    "AdaptiveCard",
+		"version": "1.4",
+		"body":    body,
+	}
+	if s.Link != "" {
+		card["actions"] = []interface{}{map[string]string{
+			"type":  "Action.OpenUrl",
+			"title": "View analysis",
+			"url":   s.Link,
+		}}
+	}
+	return map[string]interface{}{
+		"type": "message",
+		"attachments": []interface{}{map[string]interface{}{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content":     card,
+		}},
+	}
+}
@@ -0,0 +1,19 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+)
+
+// Webhook posts the Summary as plain JSON to an arbitrary endpoint.
+type Webhook struct {
+	URL    string
+	Header http.Header
+	HTTP   *http.Client
+}
+
+func (Webhook) Name() string { return "webhook" }
+
+func (w Webhook) Notify(ctx context.Context, s Summary) error {
+	return postJSON(ctx, w.HTTP, w.URL, w.Header, s)
+}
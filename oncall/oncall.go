@@ -0,0 +1,241 @@
+// Package oncall pages the on-call rotation for P0 regressions through
+// PagerDuty or Opsgenie, and resolves the incident once a later analysis of
+// the same regression shows it has cleared.
+//
+// Incidents are keyed by the report's source incident (an alert fingerprint,
+// a Sentry issue, a workflow on a branch) or, for reports without one, its
+// regression signature, so repeated analyses of one regression update a
+// single incident instead of paging again.
+package oncall
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/emyjamalian/laas-ladybug/report"
+	"github.com/emyjamalian/laas-ladybug/tools"
+)
+
+// Responder is an Opsgenie responder (team, user, escalation or schedule).
+type Responder struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+}
+
+// Route says where pages for one component go.
+type Route struct {
+	// Service is a human-readable name used as the alert source.
+	Service             string      `json:"service,omitempty"`
+	PagerDutyRoutingKey string      `json:"pagerduty_routing_key,omitempty"`
+	OpsgenieResponders  []Responder `json:"opsgenie_responders,omitempty"`
+}
+
+// Routing maps attributed components to on-call routes.
+type Routing struct {
+	Default    Route            `json:"default"`
+	Components map[string]Route `json:"components"`
+}
+
+// LoadRouting reads a Routing config from a JSON file.
+func LoadRouting(path string) (Routing, error) {
+	var r Routing
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return r, fmt.Errorf("read on-call routing: %w", err)
+	}
+	if err := json.Unmarshal(data, &r); err != nil {
+		return r, fmt.Errorf("parse on-call routing %s: %w", path, err)
+	}
+	return r, nil
+}
+
+// For returns the route for component, falling back to the default route.
+func (r Routing) For(component string) Route {
+	if route, ok := r.Components[component]; ok {
+		if route.Service == "" {
+			route.Service = component
+		}
+		return route
+	}
+	route := r.Default
+	if route.Service == "" {
+		route.Service = component
+	}
+	return route
+}
+
+// Alert is the content of a page.
+type Alert struct {
+	DedupKey  string
+	Summary   string
+	Component string
+	Priority  string
+	Link      string
+	Details   map[string]interface{}
+}
+
+// Pager is an on-call paging provider.
+type Pager interface {
+	Name() string
+	Trigger(ctx context.Context, a Alert, route Route) error
+	Resolve(ctx context.Context, dedupKey string, route Route) error
+}
+
+// Action is what Manager.Handle did for a report.
+type Action string
+
+const (
+	ActionNone      Action = "none"
+	ActionTriggered Action = "triggered"
+	ActionResolved  Action = "resolved"
+)
+
+// State remembers which incidents were paged and on which route, so a
+// cleared report only resolves an incident that was triggered, where it was
+// triggered.
+type State interface {
+	// Paged returns the route key was paged on, if it is still open.
+	Paged(key string) (route Route, ok bool, err error)
+	RecordPaged(key string, route Route) error
+	RecordResolved(key string) error
+}
+
+// MemoryState is a State that lasts as long as the process.
+type MemoryState struct {
+	mu    sync.Mutex
+	paged map[string]Route
+}
+
+// NewMemoryState returns an empty MemoryState.
+func NewMemoryState() *MemoryState {
+	return &MemoryState{paged: map[string]Route{}}
+}
+
+func (s *MemoryState) Paged(key string) (Route, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	route, ok := s.paged[key]
+	return route, ok, nil
+}
+
+func (s *MemoryState) RecordPaged(key string, route Route) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.paged[key] = route
+	return nil
+}
+
+func (s *MemoryState) RecordResolved(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.paged, key)
+	return nil
+}
+
+// Manager decides whether a report pages, resolves or does nothing.
+// Without a State nothing is ever resolved.
+type Manager struct {
+	Pagers  []Pager
+	Routing Routing
+	State   State
+}
+
+// DedupKey derives the incident key for a report: its source incident if it
+// has one, else its signature.
+func DedupKey(r *report.Report) string {
+	if r.Incident != "" {
+		return "ladybug-" + r.Incident
+	}
+	return "ladybug-" + r.Signature()
+}
+
+// Cleared reports whether the analysed run history shows the regression has
+// stopped reproducing: the most recent run passed after at least one failure.
+func Cleared(r *report.Report) bool {
+	if r.DetectInput == nil || len(r.DetectInput.RunHistory) < 2 {
+		return false
+	}
+	h := r.DetectInput.RunHistory
+	last := strings.ToLower(strings.TrimSpace(h[len(h)-1]))
+	if last != "pass" {
+		return false
+	}
+	for _, run := range h[:len(h)-1] {
+		if strings.ToLower(strings.TrimSpace(run)) == "fail" {
+			return true
+		}
+	}
+	return false
+}
+
+// Handle pages for P0 reports and, for cleared ones, resolves the incident
+// if State says it was paged. link points at the stored analysis and may be
+// empty.
+func (m Manager) Handle(ctx context.Context, r *report.Report, link string) (Action, error) {
+	if len(m.Pagers) == 0 {
+		return ActionNone, nil
+	}
+	key := DedupKey(r)
+
+	var errs []error
+	switch {
+	case Cleared(r):
+		if m.State == nil {
+			return ActionNone, nil
+		}
+		route, ok, err := m.State.Paged(key)
+		if err != nil || !ok {
+			return ActionNone, err
+		}
+		for _, p := range m.Pagers {
+			if err := p.Resolve(ctx, key, route); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+			}
+		}
+		if len(errs) < len(m.Pagers) {
+			if err := m.State.RecordResolved(key); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return ActionResolved, errors.Join(errs...)
+
+	case r.Priority() == string(tools.PriorityP0):
+		route := m.Routing.For(r.Component())
+		a := Alert{
+			DedupKey:  key,
+			Summary:   fmt.Sprintf("[P0] %s %s regression in %s: %s", r.Severity(), r.RegressionType(), r.Component(), r.Headline()),
+			Component: r.Component(),
+			Priority:  r.Priority(),
+			Link:      link,
+			Details: map[string]interface{}{
+				"regression_type": r.RegressionType(),
+				"severity":        r.Severity(),
+				"signature":       r.Signature(),
+			},
+		}
+		if r.Triage != nil {
+			a.Details["cpd_score"] = r.Triage.CPDScore
+			a.Details["recommended_action"] = r.Triage.RecommendedAction
+		}
+		if r.FixPlan != nil {
+			a.Details["immediate_actions"] = r.FixPlan.ImmediateActions
+		}
+		for _, p := range m.Pagers {
+			if err := p.Trigger(ctx, a, route); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+			}
+		}
+		if m.State != nil && len(errs) < len(m.Pagers) {
+			if err := m.State.RecordPaged(key, route); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return ActionTriggered, errors.Join(errs...)
+	}
+	return ActionNone, nil
+}
@@ -0,0 +1,120 @@
+package oncall
+
+import (
+	"context"
+	"testing"
+
+	"github.com/emyjamalian/laas-ladybug/report"
+	"github.com/emyjamalian/laas-ladybug/tools"
+)
+
+// fakePager records the keys it triggered and resolved.
+type fakePager struct {
+	triggered []string
+	resolved  []string
+	routes    []Route
+}
+
+func (*fakePager) Name() string { return "fake" }
+
+func (p *fakePager) Trigger(_ context.Context, a Alert, route Route) error {
+	p.triggered = append(p.triggered, a.DedupKey)
+	return nil
+}
+
+func (p *fakePager) Resolve(_ context.Context, key string, route Route) error {
+	p.resolved = append(p.resolved, key)
+	p.routes = append(p.routes, route)
+	return nil
+}
+
+func analysis(incident, errMsg, component string, priority tools.Priority, history ...string) *report.Report {
+	r := report.New(errMsg)
+	r.Incident = incident
+	r.DetectInput = &tools.DetectRegressionInput{ErrorMessage: errMsg, RunHistory: history}
+	r.Triage = &tools.TriageIssueOutput{Priority: priority}
+	r.Attribution = &tools.AttributeIssueOutput{HighestConfidence: component}
+	return r
+}
+
+func TestHandleResolvesPagedIncident(t *testing.T) {
+	pager := &fakePager{}
+	m := Manager{
+		Pagers: []Pager{pager},
+		Routing: Routing{Components: map[string]Route{
+			"auth": {PagerDutyRoutingKey: "auth-key"},
+		}},
+		State: NewMemoryState(),
+	}
+	ctx := context.Background()
+
+	firing := analysis("alertmanager:c0ffee", "panic: nil pointer at 0xc000123", "auth", tools.PriorityP0, "fail")
+	if action, err := m.Handle(ctx, firing, ""); action != ActionTriggered || err != nil {
+		t.Fatalf("firing: Handle = %v, %v", action, err)
+	}
+
+	// The resolved event has a different first line and no attribution, so
+	// its signature differs; the incident still matches.
+	cleared := analysis("alertmanager:c0ffee", "Alert HighErrorRate resolved", "unknown", "", "fail", "pass")
+	if firing.Signature() == cleared.Signature() {
+		t.Fatal("test reports should have different signatures")
+	}
+	if action, err := m.Handle(ctx, cleared, ""); action != ActionResolved || err != nil {
+		t.Fatalf("cleared: Handle = %v, %v", action, err)
+	}
+	if len(pager.resolved) != 1 || pager.resolved[0] != pager.triggered[0] {
+		t.Errorf("resolved %v, want the triggered key %v", pager.resolved, pager.triggered)
+	}
+	if pager.routes[0].PagerDutyRoutingKey != "auth-key" {
+		t.Errorf("resolved on route %+v, want the route that was paged", pager.routes[0])
+	}
+
+	// Once resolved, a repeat is a no-op.
+	if action, _ := m.Handle(ctx, cleared, ""); action != ActionNone {
+		t.Errorf("second clear: Handle = %v, want none", action)
+	}
+}
+
+func TestHandleDoesNotResolveUnpaged(t *testing.T) {
+	pager := &fakePager{}
+	m := Manager{Pagers: []Pager{pager}, State: NewMemoryState()}
+	cleared := analysis("github:acme/shop/workflows/42@main", "workflow succeeded", "ci", "", "fail", "pass")
+	if action, err := m.Handle(context.Background(), cleared, ""); action != ActionNone || err != nil {
+		t.Errorf("Handle = %v, %v, want nothing for an incident that was never paged", action, err)
+	}
+
+	m.State = nil
+	if action, _ := m.Handle(context.Background(), cleared, ""); action != ActionNone {
+		t.Errorf("Handle without State = %v, want none", action)
+	}
+	if len(pager.resolved) != 0 {
+		t.Errorf("resolved %v", pager.resolved)
+	}
+}
+
+func TestDedupKey(t *testing.T) {
+	if got := DedupKey(analysis("sentry:4711", "TypeError", "checkout", "")); got != "ladybug-sentry:4711" {
+		t.Errorf("DedupKey = %q, want the incident", got)
+	}
+	r := analysis("", "TypeError", "checkout", "")
+	if got := DedupKey(r); got != "ladybug-"+r.Signature() {
+		t.Errorf("DedupKey = %q, want the signature without an incident", got)
+	}
+}
+
+func TestCleared(t *testing.T) {
+	for _, tt := range []struct {
+		history []string
+		want    bool
+	}{
+		{[]string{"fail", "pass"}, true},
+		{[]string{"pass", "fail", "Pass "}, true},
+		{[]string{"fail"}, false},
+		{[]string{"pass", "pass"}, false},
+		{[]string{"pass", "fail"}, false},
+	} {
+		if got := Cleared(analysis("", "x", "c", "", tt.history...)); got != tt.want {
+			t.Errorf("Cleared(%v) = %v, want %v", tt.history, got, tt.want)
+		}
+	}
+}
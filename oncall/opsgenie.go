@@ -0,0 +1,94 @@
+package oncall
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const opsgenieAPIURL = "https://api.opsgenie.com"
+
+// Opsgenie creates and closes Opsgenie alerts. The alert alias is the dedup
+// key, so Opsgenie itself deduplicates repeated triggers.
+type Opsgenie struct {
+	APIKey string
+	// APIURL overrides the API base (e.g. https://api.eu.opsgenie.com).
+	APIURL string
+	HTTP   *http.Client
+}
+
+func (Opsgenie) Name() string { return "opsgenie" }
+
+func (o Opsgenie) Trigger(ctx context.Context, a Alert, route Route) error {
+	message := a.Summary
+	if len(message) > 130 {
+		message = message[:127] + "..."
+	}
+	description := a.Summary
+	if a.Link != "" {
+		description += "\n\nAnalysis: " + a.Link
+	}
+	details := make(map[string]string, len(a.Details))
+	for k, v := range a.Details {
+		details[k] = fmt.Sprint(v)
+	}
+	alert := map[string]interface{}{
+		"message":     message,
+		"alias":       a.DedupKey,
+		"description": description,
+		"priority":    "P1", // Opsgenie's highest; only P0 regressions page.
+		"source":      "LaaS Ladybug",
+		"entity":      route.Service,
+		"tags":        []string{"ladybug", a.Component},
+		"details":     details,
+	}
+	if len(route.OpsgenieResponders) > 0 {
+		alert["responders"] = route.OpsgenieResponders
+	}
+	return o.post(ctx, "/v2/alerts", alert)
+}
+
+func (o Opsgenie) Resolve(ctx context.Context, dedupKey string, route Route) error {
+	path := "/v2/alerts/" + url.PathEscape(dedupKey) + "/close?identifierType=alias"
+	return o.post(ctx, path, map[string]string{
+		"source": "LaaS Ladybug",
+		"note":   "Regression cleared: latest run history is passing.",
+	})
+}
+
+func (o Opsgenie) post(ctx context.Context, path string, payload interface{}) error {
+	base := o.APIURL
+	if base == "" {
+		base = opsgenieAPIURL
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(base, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Authorization", "GenieKey "+o.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+	client := o.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// Opsgenie processes alert requests asynchronously and answers 202.
+	if resp.StatusCode/100 != 2 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("Opsgenie returned status %d: %s", resp.StatusCode, respBody)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package oncall
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+const pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// PagerDuty sends PagerDuty Events API v2 events. The routing key (service
+// integration key) comes from the Route, so each component pages its own service.
+type PagerDuty struct {
+	// EventsURL overrides the Events API endpoint (tests, proxies).
+	EventsURL string
+	HTTP      *http.Client
+}
+
+func (PagerDuty) Name() string { return "pagerduty" }
+
+func (p PagerDuty) Trigger(ctx context.Context, a Alert, route Route) error {
+	if route.PagerDutyRoutingKey == "" {
+		return fmt.Errorf("no PagerDuty routing key for %s", route.Service)
+	}
+	summary := a.Summary
+	if len(summary) > 1024 {
+		summary = summary[:1021] + "..."
+	}
+	event := map[string]interface{}{
+		"routing_key":  route.PagerDutyRoutingKey,
+		"event_action": "trigger",
+		"dedup_key":    a.DedupKey,
+		"client":       "LaaS Ladybug",
+		"payload": map[string]interface{}{
+			"summary":        summary,
+			"source":         route.Service,
+			"severity":       "critical",
+			"component":      a.Component,
+			"class":          a.Details["regression_type"],
+			"custom_details": a.Details,
+		},
+	}
+	if a.Link != "" {
+		event["links"] = []map[string]string{{"href": a.Link, "text": "Fix Fast analysis"}}
+	}
+	return p.send(ctx, event)
+}
+
+func (p PagerDuty) Resolve(ctx context.Context, dedupKey string, route Route) error {
+	if route.PagerDutyRoutingKey == "" {
+		return fmt.Errorf("no PagerDuty routing key for %s", route.Service)
+	}
+	return p.send(ctx, map[string]interface{}{
+		"routing_key":  route.PagerDutyRoutingKey,
+		"event_action": "resolve",
+		"dedup_key":    dedupKey,
+	})
+}
+
+func (p PagerDuty) send(ctx context.Context, event map[string]interface{}) error {
+	url := p.EventsURL
+	if url == "" {
+		url = pagerDutyEventsURL
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := p.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// The Events API answers 202 Accepted on success.
+	if resp.StatusCode/100 != 2 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("PagerDuty returned status %d: %s", resp.StatusCode, respBody)
+	}
+	return nil
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/emyjamalian/laas-ladybug/agent"
+	"github.com/emyjamalian/laas-ladybug/notify"
+	"github.com/emyjamalian/laas-ladybug/oncall"
+	"github.com/emyjamalian/laas-ladybug/report"
+	"github.com/emyjamalian/laas-ladybug/store"
+	"github.com/emyjamalian/laas-ladybug/tools"
+)
+
+// integrations are the post-analysis outputs configured for this process:
+// persisting the report, filing a ticket, notifying chat and paging on-call.
+type integrations struct {
+	store      *store.Dir
+	sessions   *store.Sessions
+	baseURL    string
+	ticketSink string
+	dryRun     bool
+	notifier   notify.Dispatcher
+	oncall     oncall.Manager
+}
+
+// loadIntegrations configures integrations from the environment.
+func loadIntegrations() (*integrations, error) {
+	in := &integrations{
+		baseURL:    strings.TrimRight(os.Getenv("LADYBUG_BASE_URL"), "/"),
+		ticketSink: os.Getenv("LADYBUG_TICKET_SINK"),
+	}
+	if path := os.Getenv("LADYBUG_STORE"); path != "" {
+		s, err := store.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		in.store = s
+		tools.SetFixHistory(s)
+	}
+	if path := sessionDir(); path != "" {
+		s, err := store.OpenSessions(path)
+		if err != nil {
+			return nil, err
+		}
+		in.sessions = s
+	}
+
+	in.notifier.MinPriority = tools.Priority(os.Getenv("LADYBUG_NOTIFY_MIN_PRIORITY"))
+	if u := os.Getenv("LADYBUG_SLACK_WEBHOOK_URL"); u != "" {
+		in.notifier.Notifiers = append(in.notifier.Notifiers, notify.Slack{WebhookURL: u})
+	}
+	if u := os.Getenv("LADYBUG_TEAMS_WEBHOOK_URL"); u != "" {
+		in.notifier.Notifiers = append(in.notifier.Notifiers, notify.Teams{WebhookURL: u})
+	}
+	if u := os.Getenv("LADYBUG_NOTIFY_WEBHOOK_URL"); u != "" {
+		in.notifier.Notifiers = append(in.notifier.Notifiers, notify.Webhook{URL: u})
+	}
+
+	if path := os.Getenv("LADYBUG_ONCALL_ROUTING"); path != "" {
+		routing, err := oncall.LoadRouting(path)
+		if err != nil {
+			return nil, err
+		}
+		in.oncall.Routing = routing
+		if usesPagerDuty(routing) {
+			in.oncall.Pagers = append(in.oncall.Pagers, oncall.PagerDuty{})
+		}
+	}
+	if key := os.Getenv("OPSGENIE_API_KEY"); key != "" {
+		in.oncall.Pagers = append(in.oncall.Pagers, oncall.Opsgenie{APIKey: key, APIURL: os.Getenv("OPSGENIE_API_URL")})
+	}
+	// Remember what was paged so only those incidents are resolved.
+	in.oncall.State = oncall.NewMemoryState()
+	if in.store != nil {
+		s, err := store.OpenIncidents(filepath.Join(in.store.Path, "incidents"))
+		if err != nil {
+			return nil, err
+		}
+		in.oncall.State = s
+	}
+	return in, nil
+}
+
+// sessionDir is where conversations are kept for follow-up questions:
+// LADYBUG_SESSIONS, else a sessions directory in LADYBUG_STORE, else the
+// user cache directory. LADYBUG_SESSIONS=off disables persistence.
+func sessionDir() string {
+	switch path := os.Getenv("LADYBUG_SESSIONS"); {
+	case path == "off":
+		return ""
+	case path != "":
+		return path
+	}
+	if path := os.Getenv("LADYBUG_STORE"); path != "" {
+		return filepath.Join(path, "sessions")
+	}
+	if cache, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(cache, "laas-ladybug", "sessions")
+	}
+	return ""
+}
+
+// saveSession persists s so it can be resumed with "ladybug ask".
+func (in *integrations) saveSession(s *agent.Session, w io.Writer) {
+	if in.sessions == nil {
+		return
+	}
+	first := s.ID == ""
+	id, err := in.sessions.Save(s)
+	if err != nil {
+		fmt.Fprintf(w, "session: %v\n", err)
+		return
+	}
+	if first {
+		fmt.Fprintf(w, "Session %s saved; ask follow-up questions with: ladybug ask %s\n", id, id)
+	}
+}
+
+func usesPagerDuty(r oncall.Routing) bool {
+	if r.Default.PagerDutyRoutingKey != "" {
+		return true
+	}
+	for _, route := range r.Components {
+		if route.PagerDutyRoutingKey != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// link returns where a stored analysis can be viewed, or "" if it was not stored.
+func (in *integrations) link(rep *report.Report) string {
+	if in.store == nil || rep.ID == "" {
+		return ""
+	}
+	if in.baseURL != "" {
+		return in.baseURL + "/analyses/" + url.PathEscape(rep.ID)
+	}
+	abs, err := filepath.Abs(filepath.Join(in.store.Path, rep.ID+".json"))
+	if err != nil {
+		return ""
+	}
+	return "file://" + abs
+}
+
+// publish runs every configured integration for rep, reporting progress to w.
+// Integrations are independent: one failing does not stop the others.
+func (in *integrations) publish(ctx context.Context, rep *report.Report, w io.Writer) error {
+	var errs []error
+	if in.store != nil {
+		if err := in.markResolved(rep, w); err != nil {
+			errs = append(errs, err)
+		}
+		if id, err := in.store.Save(rep); err != nil {
+			errs = append(errs, err)
+		} else {
+			fmt.Fprintf(w, "Stored analysis %s\n", id)
+		}
+	}
+	if in.ticketSink != "" {
+		if err := fileTicket(ctx, in.ticketSink, rep, in.dryRun, w); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if sent, err := in.notifier.Dispatch(ctx, rep, in.link(rep)); err != nil {
+		errs = append(errs, fmt.Errorf("notify: %w", err))
+	} else if sent {
+		fmt.Fprintf(w, "Sent %s incident notification\n", rep.Priority())
+	}
+	if err := in.page(ctx, rep, w); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// clear handles a source reporting that a failure has cleared (a resolved
+// alert, a successful run) without analysing it: it resolves the on-call
+// incident, if one was paged, and marks stored analyses of it as fixed.
+func (in *integrations) clear(ctx context.Context, rep *report.Report, w io.Writer) error {
+	var errs []error
+	if in.store != nil {
+		if err := in.markResolved(rep, w); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := in.page(ctx, rep, w); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// markResolved records in the store that a passing run after failures has
+// fixed earlier reports of rep's regression, for time-to-fix history.
+func (in *integrations) markResolved(rep *report.Report, w io.Writer) error {
+	if !oncall.Cleared(rep) {
+		return nil
+	}
+	n, err := in.store.Resolve(rep.Signature(), rep.CreatedAt)
+	if err == nil && rep.Incident != "" {
+		var m int
+		m, err = in.store.ResolveIncident(rep.Incident, rep.CreatedAt)
+		n += m
+	}
+	if n > 0 {
+		fmt.Fprintf(w, "Marked %d earlier analyses of this regression as resolved\n", n)
+	}
+	return err
+}
+
+// page lets the on-call manager trigger or resolve rep's incident.
+func (in *integrations) page(ctx context.Context, rep *report.Report, w io.Writer) error {
+	switch action, err := in.oncall.Handle(ctx, rep, in.link(rep)); {
+	case err != nil:
+		return fmt.Errorf("on-call: %w", err)
+	case action == oncall.ActionTriggered:
+		fmt.Fprintf(w, "Paged on-call for %s (dedup key %s)\n", rep.Component(), oncall.DedupKey(rep))
+	case action == oncall.ActionResolved:
+		fmt.Fprintf(w, "Resolved on-call incident %s: regression cleared\n", oncall.DedupKey(rep))
+	}
+	return nil
+}
+
+// runResolve marks a stored analysis, and any earlier ones with the same
+// signature, as fixed now.
+func runResolve(args []string) error {
+	if len(args) != 1 {
+		return usageError("name one analysis ID")
+	}
+	path := os.Getenv("LADYBUG_STORE")
+	if path == "" {
+		return fmt.Errorf("LADYBUG_STORE is not set")
+	}
+	s, err := store.Open(path)
+	if err != nil {
+		return err
+	}
+	rep, err := s.Load(args[0])
+	if err != nil {
+		return err
+	}
+	n, err := s.Resolve(rep.Signature(), time.Now())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Marked %d analyses of %q as resolved\n", n, rep.Headline())
+	return nil
+}
+
+// serveAnalyses exposes stored analyses as JSON under /analyses/{id} to
+// clients presenting token. Reports carry stack traces and code, so without
+// a token they are not served at all.
+func (in *integrations) serveAnalyses(mux *http.ServeMux, token string) {
+	if in.store == nil || token == "" {
+		return
+	}
+	mux.HandleFunc("GET /analyses/{id}", requireToken(token, func(w http.ResponseWriter, r *http.Request) {
+		rep, err := in.store.Load(r.PathValue("id"))
+		if errors.Is(err, store.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, rep)
+	}))
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/emyjamalian/laas-ladybug/report"
+	"github.com/emyjamalian/laas-ladybug/store"
+)
+
+func TestServeAnalysesRequiresToken(t *testing.T) {
+	s, err := store.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := s.Save(report.New("panic: nil pointer in auth/login.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := &integrations{store: s}
+
+	get := func(mux *http.ServeMux, auth string) int {
+		r := httptest.NewRequest(http.MethodGet, "/analyses/"+id, nil)
+		if auth != "" {
+			r.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	mux := http.NewServeMux()
+	in.serveAnalyses(mux, "")
+	if code := get(mux, ""); code != http.StatusNotFound {
+		t.Errorf("without a token configured: status %d, want 404 (not served)", code)
+	}
+
+	mux = http.NewServeMux()
+	in.serveAnalyses(mux, "s3cret")
+	for auth, want := range map[string]int{
+		"":              http.StatusUnauthorized,
+		"Bearer wrong":  http.StatusUnauthorized,
+		"s3cret":        http.StatusUnauthorized,
+		"Bearer s3cret": http.StatusOK,
+	} {
+		if code := get(mux, auth); code != want {
+			t.Errorf("Authorization %q: status %d, want %d", auth, code, want)
+		}
+	}
+}
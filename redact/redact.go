@@ -0,0 +1,250 @@
+// Package redact scrubs secrets and personal data from text before it
+// leaves the process: API keys, bearer tokens, JWTs, passwords in URLs,
+// email addresses, IP addresses and any configured patterns. Every
+// redaction is reported as a Finding so it can be audited without keeping
+// the values themselves.
+package redact
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Rule replaces the matches of Pattern with "[REDACTED:<name>]". If the
+// pattern has a capture group, only the first group is replaced, so
+// "Authorization: Bearer abc" keeps its prefix.
+type Rule struct {
+	Name    string
+	Pattern *regexp.Regexp
+	// Valid, if set, filters candidate matches (e.g. real IP addresses).
+	Valid func(match string) bool
+}
+
+// Finding records what one rule redacted from one field. Fingerprints are
+// short SHA-256 prefixes of the distinct values, enough to correlate
+// repeats without revealing them.
+type Finding struct {
+	Field        string   `json:"field"`
+	Rule         string   `json:"rule"`
+	Count        int      `json:"count"`
+	Fingerprints []string `json:"fingerprints"`
+}
+
+// Builtin returns the default rules, most specific first.
+func Builtin() []Rule {
+	return []Rule{
+		{Name: "jwt", Pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{5,}\.eyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]*`)},
+		{Name: "bearer_token", Pattern: regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9._~+/-]{8,}=*)`)},
+		{Name: "url_password", Pattern: regexp.MustCompile(`(?i)\b[a-z][a-z0-9+.-]*://[^/\s:@]+:([^/\s@]+)@`)},
+		{Name: "api_key", Pattern: regexp.MustCompile(`\b(?:` +
+			`(?:sk|pk|rk)[-_](?:live[-_]|test[-_]|proj[-_])?[A-Za-z0-9_-]{16,}` + // OpenAI, Stripe
+			`|AKIA[0-9A-Z]{16}` + // AWS access key ID
+			`|gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,}` + // GitHub
+			`|xox[abprs]-[A-Za-z0-9-]{10,}` + // Slack
+			`|AIza[0-9A-Za-z_-]{35}` + // Google
+			`)`)},
+		{Name: "api_key", Pattern: regexp.MustCompile(`(?i)\b(?:api[_-]?key|apikey|secret|client[_-]?secret|access[_-]?key|token|passw(?:or)?d|authorization)` +
+			`["']?\s*[:=]\s*["']?([^\s"',;&\[][^\s"',;&]{5,})`),
+			// "Authorization: Bearer <token>" is left to bearer_token.
+			Valid: func(s string) bool { return !strings.EqualFold(s, "bearer") && !strings.EqualFold(s, "basic") }},
+		{Name: "email", Pattern: regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`)},
+		{Name: "ipv4", Pattern: regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`), Valid: validIP},
+		{Name: "ipv6", Pattern: regexp.MustCompile(`(?i)\b(?:[0-9a-f]{1,4}:){7}[0-9a-f]{1,4}\b|\b(?:[0-9a-f]{1,4}:){1,6}(?::[0-9a-f]{1,4}){1,6}\b`),
+			Valid: func(s string) bool { return strings.ContainsAny(s, "0123456789") && validIP(s) }},
+	}
+}
+
+func validIP(s string) bool { return net.ParseIP(s) != nil }
+
+// Redactor applies a set of rules. A nil *Redactor redacts nothing.
+type Redactor struct {
+	rules []Rule
+}
+
+// New returns a redactor applying rules in order.
+func New(rules ...Rule) *Redactor {
+	return &Redactor{rules: rules}
+}
+
+// Redact scrubs text and reports what it removed, attributed to field.
+func (r *Redactor) Redact(field, text string) (string, []Finding) {
+	if r == nil || text == "" {
+		return text, nil
+	}
+	var findings []Finding
+	for _, rule := range r.rules {
+		seen := map[string]bool{}
+		count := 0
+		text = replaceMatches(rule, text, func(secret string) string {
+			count++
+			seen[fingerprint(secret)] = true
+			return "[REDACTED:" + rule.Name + "]"
+		})
+		if count == 0 {
+			continue
+		}
+		prints := make([]string, 0, len(seen))
+		for p := range seen {
+			prints = append(prints, p)
+		}
+		sort.Strings(prints)
+		findings = Merge(findings, []Finding{{Field: field, Rule: rule.Name, Count: count, Fingerprints: prints}})
+	}
+	return text, findings
+}
+
+// String is Redact without the audit, for log output.
+func (r *Redactor) String(text string) string {
+	text, _ = r.Redact("", text)
+	return text
+}
+
+func replaceMatches(rule Rule, text string, replace func(secret string) string) string {
+	matches := rule.Pattern.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return text
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if len(m) >= 4 && m[2] >= 0 {
+			start, end = m[2], m[3]
+		}
+		secret := text[start:end]
+		if rule.Valid != nil && !rule.Valid(secret) {
+			continue
+		}
+		b.WriteString(text[last:start])
+		b.WriteString(replace(secret))
+		last = end
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+func fingerprint(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:4])
+}
+
+// Merge adds findings to an audit, combining entries for the same field
+// and rule.
+func Merge(audit, findings []Finding) []Finding {
+	for _, f := range findings {
+		merged := false
+		for i := range audit {
+			if audit[i].Field == f.Field && audit[i].Rule == f.Rule {
+				audit[i].Count += f.Count
+				audit[i].Fingerprints = union(audit[i].Fingerprints, f.Fingerprints)
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			f.Fingerprints = append([]string(nil), f.Fingerprints...)
+			audit = append(audit, f)
+		}
+	}
+	return audit
+}
+
+func union(a, b []string) []string {
+	set := map[string]bool{}
+	for _, s := range append(append([]string(nil), a...), b...) {
+		set[s] = true
+	}
+	out := make([]string, 0, len(set))
+	for s := range set {
+		out = append(out, s)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Config customises the built-in rules. It is read from JSON:
+//
+//	{"disable": ["ipv4", "ipv6"], "patterns": [{"name": "customer_id", "regex": "CUST-[0-9]{6}"}]}
+type Config struct {
+	// Disable names built-in rules to skip.
+	Disable []string `json:"disable"`
+	// Patterns are extra rules, applied after the built-in ones.
+	Patterns []struct {
+		Name  string `json:"name"`
+		Regex string `json:"regex"`
+	} `json:"patterns"`
+}
+
+// Load reads a Config file and returns the redactor it describes.
+func Load(path string) (*Redactor, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read redaction config: %w", err)
+	}
+	var c Config
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("parse redaction config %s: %w", path, err)
+	}
+	return c.Redactor()
+}
+
+// Redactor builds the redactor the config describes.
+func (c Config) Redactor() (*Redactor, error) {
+	disabled := map[string]bool{}
+	for _, name := range c.Disable {
+		disabled[name] = true
+	}
+	var rules []Rule
+	known := map[string]bool{}
+	for _, rule := range Builtin() {
+		known[rule.Name] = true
+		if !disabled[rule.Name] {
+			rules = append(rules, rule)
+		}
+	}
+	for _, name := range c.Disable {
+		if !known[name] {
+			return nil, fmt.Errorf("redaction config: unknown built-in rule %q", name)
+		}
+	}
+	for _, p := range c.Patterns {
+		if p.Name == "" || p.Regex == "" {
+			return nil, fmt.Errorf("redaction config: patterns need a name and a regex")
+		}
+		re, err := regexp.Compile(p.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("redaction config: pattern %s: %w", p.Name, err)
+		}
+		rules = append(rules, Rule{Name: p.Name, Pattern: re})
+	}
+	return New(rules...), nil
+}
+
+var (
+	defaultMu sync.RWMutex
+	current   = New(Builtin()...)
+)
+
+// SetDefault replaces the redactor used by Default; nil disables
+// redaction.
+func SetDefault(r *Redactor) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	current = r
+}
+
+// Default returns the process-wide redactor, the built-in rules unless
+// changed with SetDefault.
+func Default() *Redactor {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return current
+}
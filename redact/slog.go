@@ -0,0 +1,66 @@
+package redact
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Handler wraps h so log messages and string attributes are scrubbed with
+// the current Default redactor before they are written.
+func Handler(h slog.Handler) slog.Handler {
+	return handler{h}
+}
+
+type handler struct {
+	next slog.Handler
+}
+
+func (h handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h handler) Handle(ctx context.Context, rec slog.Record) error {
+	r := Default()
+	if r == nil {
+		return h.next.Handle(ctx, rec)
+	}
+	out := slog.NewRecord(rec.Time, rec.Level, r.String(rec.Message), rec.PC)
+	rec.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(scrub(r, a))
+		return true
+	})
+	return h.next.Handle(ctx, out)
+}
+
+func (h handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	r := Default()
+	scrubbed := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		scrubbed[i] = scrub(r, a)
+	}
+	return handler{h.next.WithAttrs(scrubbed)}
+}
+
+func (h handler) WithGroup(name string) slog.Handler {
+	return handler{h.next.WithGroup(name)}
+}
+
+func scrub(r *Redactor, a slog.Attr) slog.Attr {
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, r.String(v.String()))
+	case slog.KindGroup:
+		group := v.Group()
+		out := make([]slog.Attr, len(group))
+		for i, g := range group {
+			out[i] = scrub(r, g)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(out...)}
+	case slog.KindAny:
+		if err, ok := v.Any().(error); ok {
+			return slog.String(a.Key, r.String(err.Error()))
+		}
+	}
+	return slog.Attr{Key: a.Key, Value: v}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// renderText prints a tool output as an indented outline keyed by the JSON
+// field names, skipping empty fields.
+func renderText(w io.Writer, v interface{}) {
+	renderValue(w, reflect.ValueOf(v), 0)
+}
+
+func renderValue(w io.Writer, v reflect.Value, depth int) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	pad := strings.Repeat("  ", depth)
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if !f.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			renderField(w, pad, name, v.Field(i), depth)
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+		for _, k := range keys {
+			renderField(w, pad, fmt.Sprint(k), v.MapIndex(k), depth)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			e := v.Index(i)
+			if isScalar(e) {
+				fmt.Fprintf(w, "%s- %v\n", pad, e)
+				continue
+			}
+			// Hang the first field off the dash: "- stage: ci".
+			var b strings.Builder
+			renderValue(&b, e, depth+1)
+			item := strings.Replace(b.String(), pad+"  ", pad+"- ", 1)
+			fmt.Fprint(w, item)
+		}
+	default:
+		fmt.Fprintf(w, "%s%v\n", pad, v)
+	}
+}
+
+func renderField(w io.Writer, pad, name string, f reflect.Value, depth int) {
+	if f.IsZero() {
+		return
+	}
+	if k := f.Kind(); (k == reflect.Slice || k == reflect.Map) && f.Len() == 0 {
+		return
+	}
+	if isScalar(f) {
+		s := fmt.Sprint(indirect(f))
+		if strings.Contains(s, "\n") {
+			s = "|\n" + pad + "  " + strings.ReplaceAll(strings.TrimRight(s, "\n"), "\n", "\n"+pad+"  ")
+		}
+		fmt.Fprintf(w, "%s%s: %s\n", pad, name, s)
+		return
+	}
+	fmt.Fprintf(w, "%s%s:\n", pad, name)
+	renderValue(w, f, depth+1)
+}
+
+func indirect(v reflect.Value) reflect.Value {
+	for (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v
+}
+
+func isScalar(v reflect.Value) bool {
+	switch indirect(v).Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,180 @@
+// Package report holds the structured result of a Fix Fast analysis: the
+// inputs and outputs of every tool the agent ran plus the final synthesis.
+// Downstream integrations (tickets, notifications, paging) consume a Report
+// rather than re-parsing the agent's prose.
+package report
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/emyjamalian/laas-ladybug/redact"
+	"github.com/emyjamalian/laas-ladybug/tools"
+)
+
+// Report is the structured outcome of one analysis. Tool fields are nil when
+// the agent did not (successfully) call that tool.
+type Report struct {
+	// ID is assigned when the report is persisted.
+	ID string `json:"id,omitempty"`
+	// Incident identifies the failure at its source (e.g. an alert
+	// fingerprint) for reports started by a webhook. On-call incidents are
+	// keyed by it, since the signature can change between analyses.
+	Incident  string    `json:"incident,omitempty"`
+	Input     string    `json:"input"`
+	CreatedAt time.Time `json:"created_at"`
+	// ResolvedAt is when the regression was fixed, if known. It feeds the
+	// time-to-fix history used by effort estimates.
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+
+	DetectInput      *tools.DetectRegressionInput   `json:"detect_input,omitempty"`
+	Detection        *tools.DetectRegressionOutput  `json:"detection,omitempty"`
+	TriageInput      *tools.TriageIssueInput        `json:"triage_input,omitempty"`
+	Triage           *tools.TriageIssueOutput       `json:"triage,omitempty"`
+	AttributionInput *tools.AttributeIssueInput     `json:"attribution_input,omitempty"`
+	Attribution      *tools.AttributeIssueOutput    `json:"attribution,omitempty"`
+	FixPlanInput     *tools.GenerateFixPlanInput    `json:"fix_plan_input,omitempty"`
+	FixPlan          *tools.GenerateFixPlanOutput   `json:"fix_plan,omitempty"`
+	ReproTest        *tools.GenerateReproTestOutput `json:"repro_test,omitempty"`
+	Rollback         *tools.RollbackPlan            `json:"rollback,omitempty"`
+	Patch            *tools.ProposePatchOutput      `json:"patch,omitempty"`
+
+	// Summary is the agent's final synthesized report text.
+	Summary string `json:"summary"`
+
+	// Overrides records tool results a reviewer corrected after the run,
+	// e.g. "severity: high → critical". Summary predates them.
+	Overrides []string `json:"overrides,omitempty"`
+
+	// Redactions audits the secrets and personal data scrubbed from the
+	// input and tool results before they reached the model. Input and the
+	// tool inputs hold the redacted text; tool outputs are stored as the
+	// tools returned them, so e.g. a proposed patch still applies.
+	Redactions []redact.Finding `json:"redactions,omitempty"`
+}
+
+// New returns an empty report for the given agent input.
+func New(input string) *Report {
+	return &Report{Input: input, CreatedAt: time.Now().UTC()}
+}
+
+// Record stores a successful tool call. Unknown tools are ignored.
+func (r *Report) Record(tool, inputJSON, outputJSON string) error {
+	var in, out interface{}
+	switch tool {
+	case "detect_regression":
+		r.DetectInput, r.Detection = &tools.DetectRegressionInput{}, &tools.DetectRegressionOutput{}
+		in, out = r.DetectInput, r.Detection
+	case "triage_issue":
+		r.TriageInput, r.Triage = &tools.TriageIssueInput{}, &tools.TriageIssueOutput{}
+		in, out = r.TriageInput, r.Triage
+	case "attribute_to_owner":
+		r.AttributionInput, r.Attribution = &tools.AttributeIssueInput{}, &tools.AttributeIssueOutput{}
+		in, out = r.AttributionInput, r.Attribution
+	case "generate_fix_plan":
+		r.FixPlanInput, r.FixPlan = &tools.GenerateFixPlanInput{}, &tools.GenerateFixPlanOutput{}
+		in, out = r.FixPlanInput, r.FixPlan
+	case "generate_repro_test":
+		r.ReproTest = &tools.GenerateReproTestOutput{}
+		in, out = &tools.GenerateReproTestInput{}, r.ReproTest
+	case "plan_rollback":
+		r.Rollback = &tools.RollbackPlan{}
+		in, out = &tools.PlanRollbackInput{}, r.Rollback
+	case "propose_patch":
+		// Only the latest proposal is kept; the input is not interesting.
+		r.Patch = &tools.ProposePatchOutput{}
+		in, out = &tools.ProposePatchInput{}, r.Patch
+	default:
+		return nil
+	}
+	if err := json.Unmarshal([]byte(inputJSON), in); err != nil {
+		return fmt.Errorf("record %s input: %w", tool, err)
+	}
+	if err := json.Unmarshal([]byte(outputJSON), out); err != nil {
+		return fmt.Errorf("record %s output: %w", tool, err)
+	}
+	return nil
+}
+
+// RegressionType returns the detected regression type, or "unknown".
+func (r *Report) RegressionType() string {
+	if r.Detection != nil {
+		return string(r.Detection.RegressionType)
+	}
+	return string(tools.RegressionTypeUnknown)
+}
+
+// Severity returns the detected severity, or "" if detection did not run.
+func (r *Report) Severity() string {
+	if r.Detection != nil {
+		return string(r.Detection.Severity)
+	}
+	return ""
+}
+
+// Priority returns the triage priority, or "" if triage did not run.
+func (r *Report) Priority() string {
+	if r.Triage != nil {
+		return string(r.Triage.Priority)
+	}
+	return ""
+}
+
+// Component returns the highest-confidence attributed component, or "unknown".
+func (r *Report) Component() string {
+	if r.Attribution != nil && r.Attribution.HighestConfidence != "" {
+		return r.Attribution.HighestConfidence
+	}
+	return "unknown"
+}
+
+// Headline is a one-line description of the reported problem.
+func (r *Report) Headline() string {
+	text := r.Input
+	if r.DetectInput != nil && r.DetectInput.Description != "" {
+		text = r.DetectInput.Description
+	}
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "[Detected in:") {
+			if r := []rune(line); len(r) > 100 {
+				line = string(r[:97]) + "..."
+			}
+			return line
+		}
+	}
+	return "unspecified regression"
+}
+
+var (
+	volatileTokens = regexp.MustCompile(`0x[0-9a-f]+|[0-9]+`)
+	whitespace     = regexp.MustCompile(`\s+`)
+)
+
+// Signature identifies "the same regression" across analyses. It hashes the
+// regression type, the attributed component and the first line of the error
+// message (or description) with numbers, addresses and whitespace normalised,
+// so repeated reports of one failure collapse onto one signature.
+func (r *Report) Signature() string {
+	text := ""
+	if r.DetectInput != nil {
+		text = r.DetectInput.ErrorMessage
+		if strings.TrimSpace(text) == "" {
+			text = r.DetectInput.Description
+		}
+	}
+	if strings.TrimSpace(text) == "" {
+		text = r.Input
+	}
+	first := strings.TrimSpace(strings.SplitN(strings.TrimSpace(text), "\n", 2)[0])
+	first = volatileTokens.ReplaceAllString(strings.ToLower(first), "#")
+	first = whitespace.ReplaceAllString(first, " ")
+
+	sum := sha256.Sum256([]byte(r.RegressionType() + "|" + r.Component() + "|" + first))
+	return hex.EncodeToString(sum[:])[:16]
+}
@@ -0,0 +1,21 @@
+package report
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestHeadlineTruncatesByRune(t *testing.T) {
+	r := &Report{Input: strings.Repeat("ü", 120)}
+	got := r.Headline()
+	if !utf8.ValidString(got) {
+		t.Fatalf("Headline = %q, not valid UTF-8", got)
+	}
+	if want := strings.Repeat("ü", 97) + "..."; got != want {
+		t.Errorf("Headline = %q, want 97 runes and an ellipsis", got)
+	}
+	if r := (&Report{Input: strings.Repeat("ü", 100)}); r.Headline() != r.Input {
+		t.Errorf("Headline truncated a 100-rune line")
+	}
+}
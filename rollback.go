@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/emyjamalian/laas-ladybug/store"
+	"github.com/emyjamalian/laas-ladybug/tools"
+)
+
+type rollbackOptions struct {
+	component string
+	execute   bool
+}
+
+func (o *rollbackOptions) flags() *flag.FlagSet {
+	fs := flag.NewFlagSet("rollback", flag.ContinueOnError)
+	fs.StringVar(&o.component, "component", "", "attributed `component`; selects deploy targets")
+	fs.BoolVar(&o.execute, "execute", false, "run the plan after typing \"rollback\" to confirm")
+	return fs
+}
+
+// runRollback prints the rollback plan for a culprit commit or tag, or for
+// the culprit recorded in a stored analysis. With --execute it runs the plan,
+// but only after the operator types "rollback" to confirm. The plan is always
+// rebuilt from the deploy config and the repository; commands stored in an
+// analysis (or proposed by the model) are never executed as such.
+func runRollback(args []string) error {
+	var o rollbackOptions
+	rest, err := parseArgs(o.flags(), args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 1 {
+		return usageError("name one commit, tag or analysis ID")
+	}
+	input := tools.PlanRollbackInput{Culprit: rest[0], Component: o.component}
+	execute := o.execute
+	stdin, w := io.Reader(os.Stdin), io.Writer(os.Stdout)
+
+	stored, err := storedRollback(input.Culprit)
+	if err != nil {
+		return err
+	}
+	if stored != nil {
+		input.Culprit = stored.Culprit
+		if input.Component == "" {
+			input.Component = stored.Component
+		}
+	}
+	plan, err := tools.BuildRollbackPlan(context.Background(), tools.RepoRoot(), input)
+	if err != nil {
+		return err
+	}
+
+	printRollback(w, plan)
+	if !execute {
+		if len(plan.Steps) > 0 {
+			fmt.Fprintln(w, "\nNothing was run. Re-run with --execute to carry out these steps.")
+		}
+		return nil
+	}
+	if len(plan.Steps) == 0 {
+		return fmt.Errorf("rollback plan has no steps to execute")
+	}
+	for _, step := range plan.Steps {
+		if len(step.Args) == 0 {
+			return fmt.Errorf("step %d (%s) has no command; nothing was run", step.Order, step.Description)
+		}
+	}
+
+	fmt.Fprintf(w, "\nType \"rollback\" to run these %d commands: ", len(plan.Steps))
+	answer, _ := bufio.NewReader(stdin).ReadString('\n')
+	if strings.TrimSpace(answer) != "rollback" {
+		return fmt.Errorf("rollback not confirmed; nothing was run")
+	}
+	for _, step := range plan.Steps {
+		fmt.Fprintf(w, "\n[%d] $ %s\n", step.Order, step.Command)
+		cmd := exec.Command(step.Args[0], step.Args[1:]...)
+		if step.Kind == "git" {
+			cmd.Dir = tools.RepoRoot()
+		}
+		cmd.Stdout, cmd.Stderr = w, w
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("step %d (%s) failed: %w; later steps were not run", step.Order, step.Command, err)
+		}
+	}
+	fmt.Fprintln(w, "\nRollback complete.")
+	return nil
+}
+
+// storedRollback returns the culprit and component recorded by a stored
+// analysis with a rollback plan, or nil if id is not such an analysis.
+func storedRollback(id string) (*tools.PlanRollbackInput, error) {
+	path := os.Getenv("LADYBUG_STORE")
+	if path == "" {
+		return nil, nil
+	}
+	rep, err := (&store.Dir{Path: path}).Load(id)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if rep.Rollback == nil || rep.Rollback.Culprit == "" {
+		return nil, nil
+	}
+	in := &tools.PlanRollbackInput{Culprit: rep.Rollback.Culprit}
+	if c := rep.Component(); c != "unknown" {
+		in.Component = c
+	}
+	return in, nil
+}
+
+func printRollback(w io.Writer, p *tools.RollbackPlan) {
+	fmt.Fprintf(w, "Rollback plan for %s\n", p.Culprit)
+	if p.CulpritCommit != "" {
+		kind := "commit"
+		if p.Release {
+			kind = "release"
+		}
+		fmt.Fprintf(w, "  Culprit %s: %s\n", kind, p.CulpritCommit)
+	}
+	if p.PreviousGoodTag != "" {
+		fmt.Fprintf(w, "  Previous good tag: %s\n", p.PreviousGoodTag)
+	}
+	fmt.Fprintln(w)
+	for _, s := range p.Steps {
+		fmt.Fprintf(w, "  %d. [%s] %s\n       $ %s\n", s.Order, s.Kind, s.Description, s.Command)
+	}
+	for _, n := range p.Notes {
+		fmt.Fprintf(w, "  Note: %s\n", n)
+	}
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emyjamalian/laas-ladybug/agent"
+	"github.com/emyjamalian/laas-ladybug/report"
+	"github.com/emyjamalian/laas-ladybug/store"
+	"github.com/emyjamalian/laas-ladybug/telemetry"
+	"github.com/emyjamalian/laas-ladybug/webhook"
+)
+
+var webhookEvents = telemetry.NewCounter("ladybug_webhook_events_total",
+	"Webhook events that triggered an analysis or resolved an incident, by source.", "source")
+
+type serveOptions struct {
+	addr        string
+	model       string
+	tools       toolOptions
+	concurrency int
+	insecure    bool
+}
+
+func (o *serveOptions) flags() *flag.FlagSet {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	fs.StringVar(&o.addr, "addr", "", "listen `address` (default $LADYBUG_ADDR or :8080)")
+	fs.StringVar(&o.model, "model", "", "IONOS model `id` (default $IONOS_MODEL or "+agent.DefaultModel+")")
+	o.tools.register(fs)
+	fs.IntVar(&o.concurrency, "concurrency", 4, "parallel webhook analyses")
+	fs.BoolVar(&o.insecure, "insecure", false, "accept webhooks from sources without a configured secret or token, unauthenticated")
+	return fs
+}
+
+// runServe starts the webhook receiver. Each accepted event triggers a full
+// analysis whose output is printed once the run completes. Ctrl-C or SIGTERM
+// stops accepting requests and cancels the running analyses.
+func runServe(args []string) error {
+	var o serveOptions
+	rest, err := parseArgs(o.flags(), args)
+	if err != nil {
+		return err
+	}
+	switch {
+	case len(rest) > 1:
+		return usageError("at most one address")
+	case len(rest) == 1 && o.addr == "":
+		o.addr = rest[0]
+	}
+	addr := firstNonEmptyString(o.addr, firstNonEmptyString(os.Getenv("LADYBUG_ADDR"), ":8080"))
+
+	integ, err := loadIntegrations()
+	if err != nil {
+		return err
+	}
+
+	if os.Getenv("LADYBUG_LOG_LEVEL") == "" {
+		logLevel.Set(slog.LevelInfo)
+	}
+	a := agent.New()
+	if o.model != "" {
+		a.SetModel(o.model)
+	}
+	if a, err = o.tools.apply(a); err != nil {
+		return err
+	}
+	var outMu sync.Mutex
+	trigger := func(ctx context.Context, ev webhook.Event) {
+		webhookEvents.Inc(ev.Source)
+		var buf bytes.Buffer
+		var err error
+		if ev.Resolved {
+			// Nothing to analyse: resolve what the failure paged.
+			rep := report.New(ev.Prompt())
+			rep.Incident = ev.Incident
+			in := ev.Input
+			rep.DetectInput = &in
+			err = integ.clear(ctx, rep, &buf)
+		} else {
+			var sess *agent.Session
+			sess, err = a.Start(ctx, ev.Prompt(), &buf)
+			if err == nil {
+				sess.Report.Incident = ev.Incident
+				err = integ.publish(ctx, sess.Report, &buf)
+				integ.saveSession(sess, &buf)
+			}
+		}
+
+		outMu.Lock()
+		defer outMu.Unlock()
+		fmt.Printf("\n=== %s event %s ===\n%s", ev.Source, ev.ID, buf.String())
+		if err != nil {
+			slog.Error("analysis failed", "source", ev.Source, "event", ev.ID, "err", err)
+		}
+	}
+
+	hooks := webhook.NewHandler(trigger, webhookAdapters(o.insecure)...)
+
+	mux := http.NewServeMux()
+	hooks.Register(mux)
+	integ.serveAnalyses(mux, os.Getenv("LADYBUG_API_TOKEN"))
+	mux.Handle("GET /metrics", telemetry.Default.Handler())
+	if token := os.Getenv("LADYBUG_API_TOKEN"); token != "" && integ.sessions != nil {
+		newSessionAPI(a, integ, token).register(mux)
+		slog.Info("session API enabled", "path", "/sessions")
+	}
+
+	ctx, stop := interruptible(context.Background())
+	defer stop()
+	workers := make(chan struct{})
+	go func() {
+		hooks.Run(ctx, o.concurrency)
+		close(workers)
+	}()
+	srv := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdown)
+	}()
+
+	slog.Info("listening", "addr", addr, "metrics", "/metrics")
+	err = srv.ListenAndServe()
+	stop()
+	<-workers
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// webhookAdapters returns the webhook sources configured with a secret or
+// token. The others are only mounted, unauthenticated, with --insecure.
+func webhookAdapters(insecure bool) []webhook.Adapter {
+	sources := []struct {
+		adapter webhook.Adapter
+		env     string
+	}{
+		{webhook.Alertmanager{Token: os.Getenv("LADYBUG_ALERTMANAGER_TOKEN")}, "LADYBUG_ALERTMANAGER_TOKEN"},
+		{webhook.Sentry{ClientSecret: os.Getenv("LADYBUG_SENTRY_SECRET")}, "LADYBUG_SENTRY_SECRET"},
+		{webhook.GitHubActions{
+			Secret: os.Getenv("LADYBUG_GITHUB_WEBHOOK_SECRET"),
+			Token:  os.Getenv("GITHUB_TOKEN"),
+		}, "LADYBUG_GITHUB_WEBHOOK_SECRET"},
+	}
+	var adapters []webhook.Adapter
+	for _, s := range sources {
+		path := "/webhooks/" + s.adapter.Name()
+		switch {
+		case os.Getenv(s.env) != "":
+			adapters = append(adapters, s.adapter)
+			slog.Info("webhook enabled", "path", path)
+		case insecure:
+			adapters = append(adapters, webhook.Insecure(s.adapter))
+			slog.Warn("webhook enabled without authentication", "path", path, "secret", s.env)
+		default:
+			slog.Info("webhook disabled: no secret", "path", path, "secret", s.env)
+		}
+	}
+	return adapters
+}
+
+// sessionAPI lets clients start analyses and ask follow-up questions over
+// HTTP:
+//
+//	POST /sessions                {"input": "...", "allow_tools": [...], "deny_tools": [...]}
+//	                                                   → the new session
+//	GET  /sessions/{id}                                → the session
+//	POST /sessions/{id}/messages  {"question": "..."}  → {"answer", "report"}
+//
+// Requests need "Authorization: Bearer <LADYBUG_API_TOKEN>". Follow-ups on
+// one session are serialised.
+type sessionAPI struct {
+	agent *agent.Agent
+	integ *integrations
+	token string
+
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+func newSessionAPI(a *agent.Agent, integ *integrations, token string) *sessionAPI {
+	return &sessionAPI{agent: a, integ: integ, token: token, locks: map[string]*sync.Mutex{}}
+}
+
+func (api *sessionAPI) register(mux *http.ServeMux) {
+	mux.HandleFunc("POST /sessions", requireToken(api.token, api.create))
+	mux.HandleFunc("GET /sessions/{id}", requireToken(api.token, api.get))
+	mux.HandleFunc("POST /sessions/{id}/messages", requireToken(api.token, api.ask))
+}
+
+// requireToken rejects requests without "Authorization: Bearer <token>".
+func requireToken(token string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// lock returns the mutex serialising turns on one session.
+func (api *sessionAPI) lock(id string) *sync.Mutex {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	l, ok := api.locks[id]
+	if !ok {
+		l = &sync.Mutex{}
+		api.locks[id] = l
+	}
+	return l
+}
+
+func (api *sessionAPI) create(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Input      string   `json:"input"`
+		AllowTools []string `json:"allow_tools"`
+		DenyTools  []string `json:"deny_tools"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil || strings.TrimSpace(req.Input) == "" {
+		http.Error(w, `body must be {"input": "..."}`, http.StatusBadRequest)
+		return
+	}
+	a, err := api.agent.WithTools(req.AllowTools, req.DenyTools)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var progress bytes.Buffer
+	sess, err := a.Start(r.Context(), req.Input, &progress)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	pubErr := api.integ.publish(r.Context(), sess.Report, &progress)
+	if _, err := api.integ.sessions.Save(sess); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if pubErr != nil {
+		slog.Warn("publishing session failed", "session", sess.ID, "err", pubErr)
+	}
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, sess)
+}
+
+func (api *sessionAPI) load(w http.ResponseWriter, r *http.Request) (*agent.Session, bool) {
+	sess, err := api.integ.sessions.Load(r.PathValue("id"))
+	if errors.Is(err, store.ErrSessionNotFound) {
+		http.NotFound(w, r)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	return sess, true
+}
+
+func (api *sessionAPI) get(w http.ResponseWriter, r *http.Request) {
+	if sess, ok := api.load(w, r); ok {
+		writeJSON(w, sess)
+	}
+}
+
+func (api *sessionAPI) ask(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Question string `json:"question"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil || strings.TrimSpace(req.Question) == "" {
+		http.Error(w, `body must be {"question": "..."}`, http.StatusBadRequest)
+		return
+	}
+	l := api.lock(r.PathValue("id"))
+	l.Lock()
+	defer l.Unlock()
+
+	sess, ok := api.load(w, r)
+	if !ok {
+		return
+	}
+	answer, err := api.agent.Ask(r.Context(), sess, req.Question, io.Discard)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if _, err := api.integ.sessions.Save(sess); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]interface{}{"answer": answer, "report": sess.Report})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
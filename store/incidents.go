@@ -0,0 +1,81 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/emyjamalian/laas-ladybug/oncall"
+)
+
+// Incidents remembers the on-call incidents that were paged, one JSON file
+// per open incident, so they can be resolved across restarts. It
+// implements oncall.State.
+type Incidents struct {
+	Path string
+}
+
+type pagedIncident struct {
+	Key     string       `json:"key"`
+	Route   oncall.Route `json:"route"`
+	PagedAt time.Time    `json:"paged_at"`
+}
+
+// OpenIncidents returns an Incidents store rooted at path, creating it if
+// needed.
+func OpenIncidents(path string) (*Incidents, error) {
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		return nil, fmt.Errorf("create incident store: %w", err)
+	}
+	return &Incidents{Path: path}, nil
+}
+
+func (d *Incidents) Paged(key string) (oncall.Route, bool, error) {
+	data, err := os.ReadFile(d.file(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return oncall.Route{}, false, nil
+	}
+	if err != nil {
+		return oncall.Route{}, false, err
+	}
+	var inc pagedIncident
+	if err := json.Unmarshal(data, &inc); err != nil {
+		return oncall.Route{}, false, fmt.Errorf("decode incident %s: %w", key, err)
+	}
+	return inc.Route, true, nil
+}
+
+func (d *Incidents) RecordPaged(key string, route oncall.Route) error {
+	data, err := json.MarshalIndent(pagedIncident{Key: key, Route: route, PagedAt: time.Now().UTC()}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal incident: %w", err)
+	}
+	// Routes hold PagerDuty routing keys, so keep them private.
+	tmp := d.file(key) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("write incident: %w", err)
+	}
+	if err := os.Rename(tmp, d.file(key)); err != nil {
+		return fmt.Errorf("write incident: %w", err)
+	}
+	return nil
+}
+
+func (d *Incidents) RecordResolved(key string) error {
+	if err := os.Remove(d.file(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("remove incident: %w", err)
+	}
+	return nil
+}
+
+// file names an incident by a hash of its key, which may hold any
+// characters.
+func (d *Incidents) file(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(d.Path, hex.EncodeToString(sum[:12])+".json")
+}
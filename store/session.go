@@ -0,0 +1,99 @@
+package store
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/emyjamalian/laas-ladybug/agent"
+)
+
+// ErrSessionNotFound is returned by Sessions.Load for unknown session IDs.
+var ErrSessionNotFound = errors.New("session not found")
+
+// Sessions stores agent conversations, one JSON file per session, so
+// follow-up questions can resume them later.
+type Sessions struct {
+	Path string
+}
+
+// OpenSessions returns a Sessions store rooted at path, creating it if needed.
+func OpenSessions(path string) (*Sessions, error) {
+	if err := os.MkdirAll(path, 0o700); err != nil {
+		return nil, fmt.Errorf("create session store: %w", err)
+	}
+	return &Sessions{Path: path}, nil
+}
+
+// Save assigns s an ID (if it has none) and writes it to disk.
+func (d *Sessions) Save(s *agent.Session) (string, error) {
+	if s.ID == "" {
+		var b [4]byte
+		rand.Read(b[:])
+		s.ID = s.CreatedAt.Format("20060102T150405Z") + "-" + hex.EncodeToString(b[:])
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("marshal session: %w", err)
+	}
+	// Sessions hold the pasted report verbatim, so keep them private.
+	tmp := d.file(s.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return "", fmt.Errorf("write session: %w", err)
+	}
+	if err := os.Rename(tmp, d.file(s.ID)); err != nil {
+		return "", fmt.Errorf("write session: %w", err)
+	}
+	return s.ID, nil
+}
+
+// Load reads the session with the given ID.
+func (d *Sessions) Load(id string) (*agent.Session, error) {
+	if !validID.MatchString(id) {
+		return nil, ErrSessionNotFound
+	}
+	data, err := os.ReadFile(d.file(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var s agent.Session
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("decode session %s: %w", id, err)
+	}
+	return &s, nil
+}
+
+// List returns all stored sessions, most recently updated first.
+func (d *Sessions) List() ([]*agent.Session, error) {
+	entries, err := os.ReadDir(d.Path)
+	if err != nil {
+		return nil, err
+	}
+	var out []*agent.Session
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		s, err := d.Load(strings.TrimSuffix(name, ".json"))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].UpdatedAt.After(out[j].UpdatedAt) })
+	return out, nil
+}
+
+func (d *Sessions) file(id string) string {
+	return filepath.Join(d.Path, id+".json")
+}
@@ -0,0 +1,157 @@
+// Package store persists finished analyses so they can be linked to from
+// tickets and notifications and looked up again later.
+package store
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/emyjamalian/laas-ladybug/report"
+)
+
+// ErrNotFound is returned by Load for unknown analysis IDs.
+var ErrNotFound = errors.New("analysis not found")
+
+var validID = regexp.MustCompile(`^[0-9A-Za-z-]+$`)
+
+// Dir stores one JSON file per analysis in a directory.
+type Dir struct {
+	Path string
+}
+
+// Open returns a Dir rooted at path, creating it if needed.
+func Open(path string) (*Dir, error) {
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		return nil, fmt.Errorf("create store: %w", err)
+	}
+	return &Dir{Path: path}, nil
+}
+
+// Save assigns rep an ID (if it has none) and writes it to disk.
+func (d *Dir) Save(rep *report.Report) (string, error) {
+	if rep.ID == "" {
+		var b [4]byte
+		rand.Read(b[:])
+		rep.ID = rep.CreatedAt.Format("20060102T150405Z") + "-" + hex.EncodeToString(b[:])
+	}
+	data, err := json.MarshalIndent(rep, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("marshal analysis: %w", err)
+	}
+	// Write-then-rename so readers never see a partial file.
+	tmp := d.file(rep.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return "", fmt.Errorf("write analysis: %w", err)
+	}
+	if err := os.Rename(tmp, d.file(rep.ID)); err != nil {
+		return "", fmt.Errorf("write analysis: %w", err)
+	}
+	return rep.ID, nil
+}
+
+// Load reads the analysis with the given ID.
+func (d *Dir) Load(id string) (*report.Report, error) {
+	if !validID.MatchString(id) {
+		return nil, ErrNotFound
+	}
+	data, err := os.ReadFile(d.file(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rep report.Report
+	if err := json.Unmarshal(data, &rep); err != nil {
+		return nil, fmt.Errorf("decode analysis %s: %w", id, err)
+	}
+	return &rep, nil
+}
+
+// List returns all stored analyses, oldest first.
+func (d *Dir) List() ([]*report.Report, error) {
+	entries, err := os.ReadDir(d.Path)
+	if err != nil {
+		return nil, err
+	}
+	var out []*report.Report
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		rep, err := d.Load(strings.TrimSuffix(name, ".json"))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, rep)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out, nil
+}
+
+// Resolve marks every unresolved analysis with the given signature that was
+// created before at as fixed at at. It returns how many were updated.
+func (d *Dir) Resolve(signature string, at time.Time) (int, error) {
+	return d.resolve(func(rep *report.Report) bool { return rep.Signature() == signature }, at)
+}
+
+// ResolveIncident is Resolve for the analyses of a source incident (see
+// report.Report.Incident).
+func (d *Dir) ResolveIncident(incident string, at time.Time) (int, error) {
+	return d.resolve(func(rep *report.Report) bool { return rep.Incident == incident }, at)
+}
+
+func (d *Dir) resolve(match func(*report.Report) bool, at time.Time) (int, error) {
+	reps, err := d.List()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, rep := range reps {
+		if rep.ResolvedAt != nil || !rep.CreatedAt.Before(at) || !match(rep) {
+			continue
+		}
+		resolved := at.UTC()
+		rep.ResolvedAt = &resolved
+		if _, err := d.Save(rep); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// FixDurations returns the time-to-fix of resolved analyses of the given
+// regression type, optionally restricted to one component. It implements
+// tools.FixHistory.
+func (d *Dir) FixDurations(regressionType, component string) ([]time.Duration, error) {
+	reps, err := d.List()
+	if err != nil {
+		return nil, err
+	}
+	var out []time.Duration
+	for _, rep := range reps {
+		if rep.ResolvedAt == nil || rep.RegressionType() != regressionType {
+			continue
+		}
+		if component != "" && rep.Component() != component {
+			continue
+		}
+		out = append(out, rep.ResolvedAt.Sub(rep.CreatedAt))
+	}
+	return out, nil
+}
+
+func (d *Dir) file(id string) string {
+	return filepath.Join(d.Path, id+".json")
+}
@@ -0,0 +1,271 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// scopeName identifies this package as the instrumentation scope.
+const scopeName = "github.com/emyjamalian/laas-ladybug/telemetry"
+
+// maxQueuedSpans bounds the spans buffered between flushes; newer spans
+// are dropped when a collector is unreachable for long.
+const maxQueuedSpans = 2048
+
+// OTLP exports spans and metrics to an OpenTelemetry collector using OTLP
+// over HTTP with JSON encoding: spans are posted to <endpoint>/v1/traces and
+// the metrics of a registry to <endpoint>/v1/metrics, every interval and on
+// Shutdown. The periodic export ignores failures, so telemetry never fails
+// an analysis; Flush and Shutdown return them.
+type OTLP struct {
+	endpoint string
+	headers  map[string]string
+	resource map[string]interface{}
+	metrics  *Registry
+	http     *http.Client
+
+	mu    sync.Mutex
+	spans []SpanData
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// NewOTLP starts an exporter for the collector at endpoint, e.g.
+// http://localhost:4318. metrics may be nil to export spans only.
+func NewOTLP(endpoint, service string, headers map[string]string, metrics *Registry, interval time.Duration) *OTLP {
+	o := &OTLP{
+		endpoint: strings.TrimRight(endpoint, "/"),
+		headers:  headers,
+		resource: map[string]interface{}{"attributes": attributes([]Attr{String("service.name", service)})},
+		metrics:  metrics,
+		http:     &http.Client{Timeout: 10 * time.Second},
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go o.loop(interval)
+	return o
+}
+
+// ExportSpan queues an ended span for the next flush.
+func (o *OTLP) ExportSpan(s SpanData) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.spans) < maxQueuedSpans {
+		o.spans = append(o.spans, s)
+	}
+}
+func (o *OTLP) loop(interval time.Duration) {
+	defer close(o.done)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			o.Flush(ctx)
+			cancel()
+		case <-o.stop:
+			return
+		}
+	}
+}
+
+// Flush sends the queued spans and the current metrics.
+func (o *OTLP) Flush(ctx context.Context) error {
+	o.mu.Lock()
+	spans := o.spans
+	o.spans = nil
+	o.mu.Unlock()
+
+	var errs []string
+	if len(spans) > 0 {
+		if err := o.post(ctx, "/v1/traces", o.tracesPayload(spans)); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if o.metrics != nil {
+		if payload := o.metricsPayload(); payload != nil {
+			if err := o.post(ctx, "/v1/metrics", payload); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("otlp export: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Shutdown stops the periodic export and flushes what is left.
+func (o *OTLP) Shutdown(ctx context.Context) error {
+	select {
+	case <-o.stop:
+	default:
+		close(o.stop)
+	}
+	<-o.done
+	return o.Flush(ctx)
+}
+
+func (o *OTLP) post(ctx context.Context, path string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range o.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := o.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned %s: %s", path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+func (o *OTLP) tracesPayload(spans []SpanData) map[string]interface{} {
+	out := make([]map[string]interface{}, len(spans))
+	for i, s := range spans {
+		span := map[string]interface{}{
+			"traceId":           s.TraceID,
+			"spanId":            s.SpanID,
+			"name":              s.Name,
+			"kind":              1, // SPAN_KIND_INTERNAL
+			"startTimeUnixNano": unixNano(s.Start),
+			"endTimeUnixNano":   unixNano(s.End),
+			"attributes":        attributes(s.Attributes),
+		}
+		if s.ParentSpanID != "" {
+			span["parentSpanId"] = s.ParentSpanID
+		}
+		if s.Failed {
+			span["status"] = map[string]interface{}{"code": 2, "message": s.Error} // STATUS_CODE_ERROR
+		}
+		out[i] = span
+	}
+	return map[string]interface{}{"resourceSpans": []interface{}{map[string]interface{}{
+		"resource":   o.resource,
+		"scopeSpans": []interface{}{map[string]interface{}{"scope": map[string]string{"name": scopeName}, "spans": out}},
+	}}}
+}
+
+func (o *OTLP) metricsPayload() map[string]interface{} {
+	now := time.Now()
+	var metrics []interface{}
+	for _, m := range o.metrics.list() {
+		if data := m.otlp(o.metrics.start, now); data != nil {
+			metrics = append(metrics, data)
+		}
+	}
+	if len(metrics) == 0 {
+		return nil
+	}
+	return map[string]interface{}{"resourceMetrics": []interface{}{map[string]interface{}{
+		"resource":     o.resource,
+		"scopeMetrics": []interface{}{map[string]interface{}{"scope": map[string]string{"name": scopeName}, "metrics": metrics}},
+	}}}
+}
+
+// aggregationCumulative is AGGREGATION_TEMPORALITY_CUMULATIVE.
+const aggregationCumulative = 2
+
+func (c *Counter) otlp(start, now time.Time) map[string]interface{} {
+	var points []interface{}
+	for _, s := range c.sorted() {
+		points = append(points, map[string]interface{}{
+			"attributes":        labelAttributes(c.labels, s.labels),
+			"startTimeUnixNano": unixNano(start),
+			"timeUnixNano":      unixNano(now),
+			"asDouble":          s.value,
+		})
+	}
+	if points == nil {
+		return nil
+	}
+	return map[string]interface{}{"name": c.metric, "description": c.help, "sum": map[string]interface{}{
+		"aggregationTemporality": aggregationCumulative, "isMonotonic": true, "dataPoints": points,
+	}}
+}
+
+func (h *Histogram) otlp(start, now time.Time) map[string]interface{} {
+	var points []interface{}
+	for _, s := range h.sorted() {
+		counts := make([]string, len(s.counts))
+		for i, n := range s.counts {
+			counts[i] = strconv.FormatUint(n, 10)
+		}
+		points = append(points, map[string]interface{}{
+			"attributes":        labelAttributes(h.labels, s.labels),
+			"startTimeUnixNano": unixNano(start),
+			"timeUnixNano":      unixNano(now),
+			"count":             strconv.FormatUint(s.count, 10),
+			"sum":               s.sum,
+			"bucketCounts":      counts,
+			"explicitBounds":    h.buckets,
+		})
+	}
+	if points == nil {
+		return nil
+	}
+	return map[string]interface{}{"name": h.metric, "description": h.help, "histogram": map[string]interface{}{
+		"aggregationTemporality": aggregationCumulative, "dataPoints": points,
+	}}
+}
+
+func labelAttributes(names, values []string) []interface{} {
+	attrs := make([]Attr, len(names))
+	for i, n := range names {
+		attrs[i] = String(n, values[i])
+	}
+	return attributes(attrs)
+}
+
+// attributes converts attributes to OTLP KeyValues.
+func attributes(attrs []Attr) []interface{} {
+	out := make([]interface{}, 0, len(attrs))
+	for _, a := range attrs {
+		out = append(out, map[string]interface{}{"key": a.Key, "value": anyValue(a.Value)})
+	}
+	return out
+}
+
+func anyValue(v interface{}) map[string]interface{} {
+	switch v := v.(type) {
+	case string:
+		return map[string]interface{}{"stringValue": v}
+	case int:
+		return map[string]interface{}{"intValue": strconv.Itoa(v)}
+	case float64:
+		return map[string]interface{}{"doubleValue": v}
+	case bool:
+		return map[string]interface{}{"boolValue": v}
+	case []string:
+		values := make([]interface{}, len(v))
+		for i, s := range v {
+			values[i] = anyValue(s)
+		}
+		return map[string]interface{}{"arrayValue": map[string]interface{}{"values": values}}
+	}
+	return map[string]interface{}{"stringValue": fmt.Sprint(v)}
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
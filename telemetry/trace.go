@@ -0,0 +1,158 @@
+// Package telemetry records OpenTelemetry-style traces and Prometheus
+// metrics without third-party dependencies. Spans and metrics are exported
+// to an OpenTelemetry collector with OTLP over HTTP (JSON encoding), and
+// metrics are also served in the Prometheus text format.
+package telemetry
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// Attr is a span attribute. Values are strings, ints, float64s, bools or
+// string slices.
+type Attr struct {
+	Key   string
+	Value interface{}
+}
+
+func String(key, value string) Attr        { return Attr{key, value} }
+func Int(key string, value int) Attr       { return Attr{key, value} }
+func Float(key string, value float64) Attr { return Attr{key, value} }
+func Bool(key string, value bool) Attr     { return Attr{key, value} }
+func Strings(key string, v []string) Attr  { return Attr{key, v} }
+
+// SpanData is an ended span as handed to the exporter.
+type SpanData struct {
+	TraceID      string // 32 hex digits
+	SpanID       string // 16 hex digits
+	ParentSpanID string // empty for a root span
+	Name         string
+	Start, End   time.Time
+	Attributes   []Attr
+	// Error is set when the span recorded a failure.
+	Error  string
+	Failed bool
+}
+
+// Exporter receives spans as they end. It must not block.
+type Exporter interface {
+	ExportSpan(SpanData)
+}
+
+var (
+	exporterMu sync.RWMutex
+	exporter   Exporter
+)
+
+// SetExporter installs the span exporter; nil turns tracing off, which
+// makes Start free.
+func SetExporter(e Exporter) {
+	exporterMu.Lock()
+	defer exporterMu.Unlock()
+	exporter = e
+}
+
+func currentExporter() Exporter {
+	exporterMu.RLock()
+	defer exporterMu.RUnlock()
+	return exporter
+}
+
+// Span is one timed operation. All methods are safe on a nil *Span, which
+// is what Start returns while tracing is off.
+type Span struct {
+	mu    sync.Mutex
+	data  SpanData
+	ended bool
+}
+
+type spanKey struct{}
+
+// Start begins a span named name as a child of the span in ctx, if any,
+// and returns a context carrying the new span.
+func Start(ctx context.Context, name string, attrs ...Attr) (context.Context, *Span) {
+	if currentExporter() == nil {
+		return ctx, nil
+	}
+	s := &Span{data: SpanData{Name: name, Start: time.Now(), Attributes: attrs, SpanID: newID(8)}}
+	if parent := SpanFromContext(ctx); parent != nil {
+		s.data.TraceID, s.data.ParentSpanID = parent.data.TraceID, parent.data.SpanID
+	} else {
+		s.data.TraceID = newID(16)
+	}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// SpanFromContext returns the span carried by ctx, or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// SetAttributes adds or replaces attributes.
+func (s *Span) SetAttributes(attrs ...Attr) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, a := range attrs {
+		replaced := false
+		for i := range s.data.Attributes {
+			if s.data.Attributes[i].Key == a.Key {
+				s.data.Attributes[i], replaced = a, true
+			}
+		}
+		if !replaced {
+			s.data.Attributes = append(s.data.Attributes, a)
+		}
+	}
+}
+
+// RecordError marks the span as failed with err. A nil err is ignored.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Failed, s.data.Error = true, err.Error()
+}
+
+// End finishes the span and exports it. Later calls do nothing.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	data.Attributes = append([]Attr(nil), s.data.Attributes...)
+	s.mu.Unlock()
+	if e := currentExporter(); e != nil {
+		e.ExportSpan(data)
+	}
+}
+
+// TraceID returns the span's trace ID in hex, or "" for a nil span.
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return s.data.TraceID
+}
+
+func newID(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,79 @@
+package ticket
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// GitHub files tickets as GitHub Issues in Repo ("owner/name").
+type GitHub struct {
+	Repo  string
+	Token string
+	// APIURL overrides the REST API base (GitHub Enterprise, tests).
+	APIURL string
+	HTTP   *http.Client
+}
+
+type githubIssue struct {
+	Number  int    `json:"number"`
+	HTMLURL string `json:"html_url"`
+	// PullRequest is set when the "issue" is a pull request.
+	PullRequest *struct{} `json:"pull_request"`
+}
+
+func (GitHub) Name() string { return "github" }
+
+func (g GitHub) Payload(t Ticket) (interface{}, error) {
+	p := map[string]interface{}{
+		"title":  t.Title,
+		"body":   t.Body,
+		"labels": t.Labels,
+	}
+	if t.Assignee != "" {
+		p["assignees"] = []string{t.Assignee}
+	}
+	return p, nil
+}
+
+func (g GitHub) FindOpen(ctx context.Context, signature string) (*Filed, error) {
+	var issues []githubIssue
+	// The issues API lists pull requests too; a labeled PR is not a ticket.
+	path := "/issues?state=open&per_page=30&labels=" + url.QueryEscape(SignatureLabel(signature))
+	if err := doJSON(ctx, g.HTTP, http.MethodGet, g.url(path), g.header(), nil, &issues); err != nil {
+		return nil, err
+	}
+	for _, issue := range issues {
+		if issue.PullRequest == nil {
+			return &Filed{ID: strconv.Itoa(issue.Number), URL: issue.HTMLURL}, nil
+		}
+	}
+	return nil, nil
+}
+
+func (g GitHub) Create(ctx context.Context, t Ticket) (*Filed, error) {
+	payload, _ := g.Payload(t)
+	var issue githubIssue
+	if err := doJSON(ctx, g.HTTP, http.MethodPost, g.url("/issues"), g.header(), payload, &issue); err != nil {
+		return nil, err
+	}
+	return &Filed{ID: strconv.Itoa(issue.Number), URL: issue.HTMLURL}, nil
+}
+
+func (g GitHub) url(path string) string {
+	base := g.APIURL
+	if base == "" {
+		base = "https://api.github.com"
+	}
+	return fmt.Sprintf("%s/repos/%s%s", strings.TrimRight(base, "/"), g.Repo, path)
+}
+
+func (g GitHub) header() http.Header {
+	return http.Header{
+		"Authorization": {"Bearer " + g.Token},
+		"Accept":        {"application/vnd.github+json"},
+	}
+}
@@ -0,0 +1,116 @@
+package ticket
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Jira files tickets as issues in Project via the Jira REST API v2.
+type Jira struct {
+	BaseURL   string
+	User      string
+	Token     string
+	Project   string
+	IssueType string // default "Bug"
+	HTTP      *http.Client
+}
+
+// jiraPriority maps triage priorities onto Jira's default priority scheme.
+var jiraPriority = map[string]string{
+	"P0": "Highest",
+	"P1": "High",
+	"P2": "Medium",
+	"P3": "Low",
+}
+
+func (Jira) Name() string { return "jira" }
+
+func (j Jira) Payload(t Ticket) (interface{}, error) {
+	issueType := j.IssueType
+	if issueType == "" {
+		issueType = "Bug"
+	}
+	// Jira labels cannot contain spaces; ours never do, but colons read
+	// poorly in JQL so swap them for dashes.
+	labels := make([]string, len(t.Labels))
+	for i, l := range t.Labels {
+		labels[i] = strings.ReplaceAll(l, ":", "-")
+	}
+	fields := map[string]interface{}{
+		"project":     map[string]string{"key": j.Project},
+		"summary":     t.Title,
+		"description": toJiraMarkup(t.Body),
+		"issuetype":   map[string]string{"name": issueType},
+		"labels":      labels,
+	}
+	if p, ok := jiraPriority[t.Priority]; ok {
+		fields["priority"] = map[string]string{"name": p}
+	}
+	if t.Assignee != "" {
+		// Jira Cloud identifies users by account ID only.
+		fields["assignee"] = map[string]string{"accountId": t.Assignee}
+	}
+	return map[string]interface{}{"fields": fields}, nil
+}
+
+func (j Jira) FindOpen(ctx context.Context, signature string) (*Filed, error) {
+	jql := fmt.Sprintf(`project = %q AND labels = %q AND statusCategory != Done`, j.Project, SignatureLabel(signature))
+	var result struct {
+		Issues []struct {
+			Key string `json:"key"`
+		} `json:"issues"`
+	}
+	path := "/rest/api/2/search?maxResults=1&fields=key&jql=" + url.QueryEscape(jql)
+	if err := doJSON(ctx, j.HTTP, http.MethodGet, j.url(path), j.header(), nil, &result); err != nil {
+		return nil, err
+	}
+	if len(result.Issues) == 0 {
+		return nil, nil
+	}
+	key := result.Issues[0].Key
+	return &Filed{ID: key, URL: j.url("/browse/" + key)}, nil
+}
+
+func (j Jira) Create(ctx context.Context, t Ticket) (*Filed, error) {
+	payload, _ := j.Payload(t)
+	var created struct {
+		Key string `json:"key"`
+	}
+	if err := doJSON(ctx, j.HTTP, http.MethodPost, j.url("/rest/api/2/issue"), j.header(), payload, &created); err != nil {
+		return nil, err
+	}
+	return &Filed{ID: created.Key, URL: j.url("/browse/" + created.Key)}, nil
+}
+
+func (j Jira) url(path string) string {
+	return strings.TrimRight(j.BaseURL, "/") + path
+}
+
+func (j Jira) header() http.Header {
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	req.SetBasicAuth(j.User, j.Token)
+	return http.Header{"Authorization": req.Header["Authorization"]}
+}
+
+var (
+	mdHeading   = regexp.MustCompile(`(?m)^## (.*)$`)
+	mdCheckbox  = regexp.MustCompile(`(?m)^- \[ \] `)
+	mdBullet    = regexp.MustCompile(`(?m)^- `)
+	mdBold      = regexp.MustCompile(`\*\*([^*]+)\*\*`)
+	mdCode      = regexp.MustCompile("`([^`]+)`")
+	htmlComment = regexp.MustCompile(`<!--\s*(.*?)\s*-->`)
+)
+
+// toJiraMarkup converts the small markdown subset Build emits to Jira wiki markup.
+func toJiraMarkup(md string) string {
+	s := mdHeading.ReplaceAllString(md, "h2. $1")
+	s = mdCheckbox.ReplaceAllString(s, "* ☐ ")
+	s = mdBullet.ReplaceAllString(s, "* ")
+	s = mdBold.ReplaceAllString(s, "*$1*")
+	s = mdCode.ReplaceAllString(s, "{{$1}}")
+	return htmlComment.ReplaceAllString(s, "{color:gray}$1{color}")
+}
@@ -0,0 +1,166 @@
+// Package ticket files a finished analysis as a ticket in an issue tracker.
+//
+// Build turns a report.Report into a tracker-agnostic Ticket: the title comes
+// from detection, labels and priority from triage, the assignee from
+// attribution, and the body carries the fix steps as a checklist. A Sink then
+// files it, reusing an already-open ticket with the same regression signature.
+package ticket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/emyjamalian/laas-ladybug/report"
+)
+
+// Ticket is the tracker-agnostic content of an issue.
+type Ticket struct {
+	Title     string   `json:"title"`
+	Body      string   `json:"body"`
+	Labels    []string `json:"labels"`
+	Priority  string   `json:"priority"`
+	Assignee  string   `json:"assignee,omitempty"`
+	Component string   `json:"component"`
+	Signature string   `json:"signature"`
+}
+
+// Filed identifies a ticket in the tracker.
+type Filed struct {
+	ID  string `json:"id"`
+	URL string `json:"url,omitempty"`
+	// Existing is true when an open ticket with the same signature was found
+	// and no new ticket was created.
+	Existing bool `json:"existing"`
+}
+
+// Sink is an issue tracker that tickets can be filed in.
+type Sink interface {
+	Name() string
+	// Payload returns the request body Create would send, for dry runs.
+	Payload(t Ticket) (interface{}, error)
+	// FindOpen returns the open ticket carrying signature, or nil if none.
+	FindOpen(ctx context.Context, signature string) (*Filed, error)
+	Create(ctx context.Context, t Ticket) (*Filed, error)
+}
+
+// SignatureLabel is the label that marks a ticket with its regression signature.
+func SignatureLabel(signature string) string {
+	return "ladybug-" + signature
+}
+
+// Build renders a report as a ticket. assignees maps attributed components to
+// tracker usernames; components without an entry are left unassigned.
+func Build(r *report.Report, assignees map[string]string) Ticket {
+	t := Ticket{
+		Title:     fmt.Sprintf("[%s] %s", orDefault(r.Priority(), "untriaged"), r.Headline()),
+		Priority:  r.Priority(),
+		Component: r.Component(),
+		Signature: r.Signature(),
+		Assignee:  assignees[r.Component()],
+		Labels:    []string{"ladybug", "regression", SignatureLabel(r.Signature())},
+	}
+	if r.Detection != nil {
+		t.Title = fmt.Sprintf("[%s] %s %s: %s", orDefault(r.Priority(), "untriaged"),
+			r.Detection.Severity, r.Detection.RegressionType, r.Headline())
+		t.Labels = append(t.Labels, "type:"+string(r.Detection.RegressionType), "severity:"+string(r.Detection.Severity))
+	}
+	if r.Priority() != "" {
+		t.Labels = append(t.Labels, "priority:"+r.Priority())
+	}
+	if r.Component() != "unknown" {
+		t.Labels = append(t.Labels, "component:"+r.Component())
+	}
+	t.Body = body(r)
+	return t
+}
+
+func body(r *report.Report) string {
+	var b strings.Builder
+	if d := r.Detection; d != nil {
+		fmt.Fprintf(&b, "## Detection\n\n%s\n\n", d.Summary)
+		fmt.Fprintf(&b, "- Type: `%s`\n- Severity: %s\n- Confidence: %.2f\n", d.RegressionType, d.Severity, d.Confidence)
+		if len(d.Indicators) > 0 {
+			fmt.Fprintf(&b, "- Indicators: %s\n", strings.Join(d.Indicators, "; "))
+		}
+		b.WriteString("\n")
+	}
+	if len(r.Overrides) > 0 {
+		b.WriteString("## Reviewer overrides\n\n")
+		for _, o := range r.Overrides {
+			fmt.Fprintf(&b, "- %s\n", o)
+		}
+		b.WriteString("\n")
+	}
+	if t := r.Triage; t != nil {
+		fmt.Fprintf(&b, "## Triage\n\n- Priority: **%s**\n- CPD score: %.0f\n- Action: %s\n\n%s\n\n",
+			t.Priority, t.CPDScore, t.RecommendedAction, t.CostRationale)
+	}
+	if a := r.Attribution; a != nil {
+		fmt.Fprintf(&b, "## Attribution\n\n- Component: `%s`\n- Recommended reviewer: %s\n", a.HighestConfidence, a.RecommendedReviewer)
+		for _, o := range a.SuspectedOwners {
+			fmt.Fprintf(&b, "- %s (%.0f%%): %s\n", o.Component, o.Confidence*100, strings.Join(o.FilePaths, ", "))
+		}
+		b.WriteString("\n")
+	}
+	if p := r.FixPlan; p != nil {
+		if len(p.ImmediateActions) > 0 {
+			b.WriteString("## Immediate actions\n\n")
+			for _, a := range p.ImmediateActions {
+				fmt.Fprintf(&b, "- %s\n", a)
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("## Fix steps\n\n")
+		for _, s := range p.FixSteps {
+			fmt.Fprintf(&b, "- [ ] **%s** — %s\n", s.Action, s.Description)
+		}
+		fmt.Fprintf(&b, "\n## Rollback plan\n\n%s\n\n## Test strategy\n\n%s\n\n", p.RollbackPlan, p.TestStrategy)
+		fmt.Fprintf(&b, "Estimated effort: %s\n\n", p.EstimatedEffort)
+	}
+	if r.Detection == nil && r.FixPlan == nil {
+		fmt.Fprintf(&b, "%s\n\n", strings.TrimSpace(r.Summary))
+	}
+	fmt.Fprintf(&b, "<!-- ladybug-signature: %s -->\n", r.Signature())
+	return b.String()
+}
+
+// File files t in s. An open ticket with the same signature is returned as-is
+// instead of creating a duplicate. With dryRun set, the payload is written to
+// w and nothing is sent.
+func File(ctx context.Context, s Sink, t Ticket, dryRun bool, w io.Writer) (*Filed, error) {
+	if dryRun {
+		payload, err := s.Payload(t)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(w, "[dry-run] %s ticket payload:\n", s.Name())
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		return nil, enc.Encode(payload)
+	}
+
+	existing, err := s.FindOpen(ctx, t.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("%s: search for open ticket: %w", s.Name(), err)
+	}
+	if existing != nil {
+		existing.Existing = true
+		return existing, nil
+	}
+	filed, err := s.Create(ctx, t)
+	if err != nil {
+		return nil, fmt.Errorf("%s: create ticket: %w", s.Name(), err)
+	}
+	return filed, nil
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
@@ -0,0 +1,247 @@
+package ticket
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var sample = Ticket{
+	Title:     "[P0] high null_pointer: NPE in auth/login.go",
+	Body:      "## Fix steps\n\n- [ ] **Add nil check** — in `Login`\n\n<!-- ladybug-signature: abc123 -->\n",
+	Labels:    []string{"ladybug", "regression", "ladybug-abc123", "priority:P0"},
+	Priority:  "P0",
+	Assignee:  "alice",
+	Component: "auth-service",
+	Signature: "abc123",
+}
+
+// tracker is a fake issue tracker that records the requests it receives.
+type tracker struct {
+	t        *testing.T
+	requests []*http.Request
+	bodies   []string
+	handle   func(w http.ResponseWriter, r *http.Request)
+}
+
+func newTracker(t *testing.T, handle func(w http.ResponseWriter, r *http.Request)) (*tracker, *httptest.Server) {
+	tr := &tracker{t: t, handle: handle}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		tr.requests = append(tr.requests, r)
+		tr.bodies = append(tr.bodies, string(body))
+		tr.handle(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return tr, srv
+}
+
+func decode(t *testing.T, s string) map[string]interface{} {
+	t.Helper()
+	var v map[string]interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatalf("request body %q: %v", s, err)
+	}
+	return v
+}
+
+func TestGitHubReusesOpenIssueButNotPullRequest(t *testing.T) {
+	tr, srv := newTracker(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"number": 3, "html_url": "https://github.com/o/r/pull/3", "pull_request": {}},
+			{"number": 7, "html_url": "https://github.com/o/r/issues/7"}]`))
+	})
+	gh := GitHub{Repo: "o/r", Token: "tok", APIURL: srv.URL}
+	filed, err := File(context.Background(), gh, sample, false, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filed.ID != "7" || !filed.Existing {
+		t.Errorf("filed = %+v, want the open issue #7, not the pull request", filed)
+	}
+	if len(tr.requests) != 1 {
+		t.Fatalf("got %d requests, want only the search", len(tr.requests))
+	}
+	r := tr.requests[0]
+	if r.URL.Path != "/repos/o/r/issues" || r.URL.Query().Get("labels") != "ladybug-abc123" || r.URL.Query().Get("state") != "open" {
+		t.Errorf("search = %s, want open issues labeled with the signature", r.URL)
+	}
+	if got := r.Header.Get("Accept"); got != "application/vnd.github+json" {
+		t.Errorf("Accept = %q, want GitHub's media type", got)
+	}
+	if got := r.Header.Get("Authorization"); got != "Bearer tok" {
+		t.Errorf("Authorization = %q", got)
+	}
+}
+
+func TestGitHubCreate(t *testing.T) {
+	tr, srv := newTracker(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`[]`))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"number": 12, "html_url": "https://github.com/o/r/issues/12"}`))
+	})
+	filed, err := File(context.Background(), GitHub{Repo: "o/r", Token: "tok", APIURL: srv.URL}, sample, false, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filed.ID != "12" || filed.Existing || filed.URL != "https://github.com/o/r/issues/12" {
+		t.Errorf("filed = %+v, want new issue #12", filed)
+	}
+	if len(tr.requests) != 2 || tr.requests[1].Method != http.MethodPost || tr.requests[1].URL.Path != "/repos/o/r/issues" {
+		t.Fatalf("requests = %d, want a search then a POST to /issues", len(tr.requests))
+	}
+	if got := tr.requests[1].Header.Get("Accept"); got != "application/vnd.github+json" {
+		t.Errorf("Accept = %q, want GitHub's media type", got)
+	}
+	p := decode(t, tr.bodies[1])
+	if p["title"] != sample.Title || p["body"] != sample.Body {
+		t.Errorf("payload = %v, want the ticket's title and body", p)
+	}
+	if a, _ := p["assignees"].([]interface{}); len(a) != 1 || a[0] != "alice" {
+		t.Errorf("assignees = %v, want [alice]", p["assignees"])
+	}
+	if l, _ := p["labels"].([]interface{}); len(l) != len(sample.Labels) {
+		t.Errorf("labels = %v, want %v", p["labels"], sample.Labels)
+	}
+}
+
+func TestJira(t *testing.T) {
+	existing := false
+	tr, srv := newTracker(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/rest/api/2/search" && existing:
+			w.Write([]byte(`{"issues": [{"key": "OPS-4"}]}`))
+		case r.URL.Path == "/rest/api/2/search":
+			w.Write([]byte(`{"issues": []}`))
+		case r.URL.Path == "/rest/api/2/issue" && r.Method == http.MethodPost:
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"key": "OPS-9"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	j := Jira{BaseURL: srv.URL + "/", User: "bot@example.com", Token: "tok", Project: "OPS"}
+
+	filed, err := File(context.Background(), j, sample, false, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filed.ID != "OPS-9" || filed.Existing || filed.URL != srv.URL+"/browse/OPS-9" {
+		t.Errorf("filed = %+v, want new issue OPS-9", filed)
+	}
+	jql := tr.requests[0].URL.Query().Get("jql")
+	if !strings.Contains(jql, `project = "OPS"`) || !strings.Contains(jql, `labels = "ladybug-abc123"`) || !strings.Contains(jql, "statusCategory != Done") {
+		t.Errorf("jql = %q, want open OPS issues labeled with the signature", jql)
+	}
+	if user, pass, ok := tr.requests[1].BasicAuth(); !ok || user != "bot@example.com" || pass != "tok" {
+		t.Errorf("create request is not authenticated with basic auth")
+	}
+	fields, _ := decode(t, tr.bodies[1])["fields"].(map[string]interface{})
+	if fields["summary"] != sample.Title {
+		t.Errorf("summary = %v, want the title", fields["summary"])
+	}
+	if p, _ := fields["priority"].(map[string]interface{}); p["name"] != "Highest" {
+		t.Errorf("priority = %v, want P0 mapped to Highest", fields["priority"])
+	}
+	if it, _ := fields["issuetype"].(map[string]interface{}); it["name"] != "Bug" {
+		t.Errorf("issuetype = %v, want the Bug default", fields["issuetype"])
+	}
+	if a, _ := fields["assignee"].(map[string]interface{}); a["accountId"] != "alice" {
+		t.Errorf("assignee = %v, want the account ID", fields["assignee"])
+	}
+	if l, _ := fields["labels"].([]interface{}); len(l) != 4 || l[3] != "priority-P0" {
+		t.Errorf("labels = %v, want colons replaced", fields["labels"])
+	}
+	desc, _ := fields["description"].(string)
+	if !strings.Contains(desc, "h2. Fix steps") || !strings.Contains(desc, "* ☐ *Add nil check*") || !strings.Contains(desc, "{{Login}}") {
+		t.Errorf("description = %q, want Jira markup", desc)
+	}
+
+	existing = true
+	tr.requests, tr.bodies = nil, nil
+	filed, err = File(context.Background(), j, sample, false, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filed.ID != "OPS-4" || !filed.Existing || len(tr.requests) != 1 {
+		t.Errorf("filed = %+v after %d requests, want the open OPS-4 and no create", filed, len(tr.requests))
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	open := false
+	tr, srv := newTracker(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/tickets/abc123" && open:
+			w.Write([]byte(`{"id": "T-1", "url": "https://tracker/T-1"}`))
+		case r.URL.Path == "/tickets/abc123":
+			http.NotFound(w, r)
+		case r.URL.Path == "/tickets" && r.Method == http.MethodPost:
+			w.Write([]byte(`{"id": "T-2"}`))
+		default:
+			http.Error(w, "unexpected", http.StatusTeapot)
+		}
+	})
+	wh := Webhook{
+		URL:       srv.URL + "/tickets",
+		DedupeURL: srv.URL + "/tickets/{signature}",
+		Template:  `{"summary": {{json .Title}}, "team": {{json .Component}}}`,
+		Header:    http.Header{"X-Api-Key": {"secret"}},
+	}
+
+	filed, err := File(context.Background(), wh, sample, false, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filed.ID != "T-2" || filed.Existing {
+		t.Errorf("filed = %+v, want new ticket T-2", filed)
+	}
+	if len(tr.requests) != 2 {
+		t.Fatalf("got %d requests, want a dedupe lookup and a POST", len(tr.requests))
+	}
+	post := tr.requests[1]
+	if post.Header.Get("X-Api-Key") != "secret" || post.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("POST headers = %v, want the configured header and JSON content type", post.Header)
+	}
+	if p := decode(t, tr.bodies[1]); p["summary"] != sample.Title || p["team"] != "auth-service" {
+		t.Errorf("payload = %v, want the rendered template", p)
+	}
+
+	open = true
+	tr.requests, tr.bodies = nil, nil
+	filed, err = File(context.Background(), wh, sample, false, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filed.ID != "T-1" || filed.URL != "https://tracker/T-1" || !filed.Existing || len(tr.requests) != 1 {
+		t.Errorf("filed = %+v after %d requests, want the open T-1 and no POST", filed, len(tr.requests))
+	}
+}
+
+func TestDryRunSendsNothing(t *testing.T) {
+	_, srv := newTracker(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("dry run sent %s %s", r.Method, r.URL)
+	})
+	sinks := []Sink{
+		GitHub{Repo: "o/r", APIURL: srv.URL},
+		Jira{BaseURL: srv.URL, Project: "OPS"},
+		Webhook{URL: srv.URL, DedupeURL: srv.URL + "/{signature}"},
+	}
+	for _, s := range sinks {
+		var out bytes.Buffer
+		filed, err := File(context.Background(), s, sample, true, &out)
+		if err != nil || filed != nil {
+			t.Errorf("%s: File = %+v, %v; want nothing filed", s.Name(), filed, err)
+		}
+		if !strings.HasPrefix(out.String(), "[dry-run] "+s.Name()+" ticket payload:") || !strings.Contains(out.String(), "NPE in auth/login.go") {
+			t.Errorf("%s: dry-run output = %q, want the payload", s.Name(), out.String())
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package ticket
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+)
+
+// DefaultWebhookTemplate renders the whole Ticket as JSON.
+const DefaultWebhookTemplate = `{{json .}}`
+
+// Webhook posts tickets to an arbitrary endpoint using a text/template body.
+// The template is executed with the Ticket as dot and a "json" function for
+// safely embedding values.
+//
+// Deduplication is optional: if DedupeURL is set it is expanded with
+// {signature} and fetched; a 200 response whose JSON body has an "id" field
+// is treated as an existing open ticket, a 404 as none.
+type Webhook struct {
+	URL       string
+	Template  string
+	DedupeURL string
+	Header    http.Header
+	HTTP      *http.Client
+}
+
+func (Webhook) Name() string { return "webhook" }
+
+func (wh Webhook) Payload(t Ticket) (interface{}, error) {
+	raw, err := wh.render(t)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		// Non-JSON templates are shown verbatim.
+		return string(raw), nil
+	}
+	return v, nil
+}
+
+func (wh Webhook) FindOpen(ctx context.Context, signature string) (*Filed, error) {
+	if wh.DedupeURL == "" {
+		return nil, nil
+	}
+	u := strings.ReplaceAll(wh.DedupeURL, "{signature}", signature)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range wh.Header {
+		req.Header[k] = v
+	}
+	resp, err := wh.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return nil, nil
+	case http.StatusOK:
+		var f Filed
+		if err := json.NewDecoder(resp.Body).Decode(&f); err != nil || f.ID == "" {
+			return nil, fmt.Errorf("dedupe lookup returned no ticket id")
+		}
+		return &f, nil
+	default:
+		return nil, fmt.Errorf("dedupe lookup returned status %d", resp.StatusCode)
+	}
+}
+
+func (wh Webhook) Create(ctx context.Context, t Ticket) (*Filed, error) {
+	body, err := wh.render(t)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range wh.Header {
+		req.Header[k] = v
+	}
+	if req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := wh.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("POST %s returned status %d: %s", wh.URL, resp.StatusCode, respBody)
+	}
+
+	// Receivers may echo back an id/url; fall back to the signature.
+	f := Filed{ID: t.Signature}
+	json.Unmarshal(respBody, &f)
+	if f.ID == "" {
+		f.ID = t.Signature
+	}
+	return &f, nil
+}
+
+func (wh Webhook) render(t Ticket) ([]byte, error) {
+	text := wh.Template
+	if text == "" {
+		text = DefaultWebhookTemplate
+	}
+	tmpl, err := template.New("ticket").Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse ticket template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, t); err != nil {
+		return nil, fmt.Errorf("render ticket template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (wh Webhook) client() *http.Client {
+	if wh.HTTP != nil {
+		return wh.HTTP
+	}
+	return http.DefaultClient
+}
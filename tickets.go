@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/emyjamalian/laas-ladybug/report"
+	"github.com/emyjamalian/laas-ladybug/ticket"
+)
+
+// ticketSink builds the named ticket sink from environment configuration.
+func ticketSink(name string) (ticket.Sink, error) {
+	switch name {
+	case "github":
+		repo := os.Getenv("LADYBUG_GITHUB_REPO")
+		if repo == "" {
+			return nil, fmt.Errorf("LADYBUG_GITHUB_REPO (owner/name) is not set")
+		}
+		return ticket.GitHub{Repo: repo, Token: os.Getenv("GITHUB_TOKEN")}, nil
+	case "jira":
+		j := ticket.Jira{
+			BaseURL:   os.Getenv("JIRA_BASE_URL"),
+			User:      os.Getenv("JIRA_USER"),
+			Token:     os.Getenv("JIRA_API_TOKEN"),
+			Project:   os.Getenv("JIRA_PROJECT"),
+			IssueType: os.Getenv("JIRA_ISSUE_TYPE"),
+		}
+		if j.BaseURL == "" || j.Project == "" {
+			return nil, fmt.Errorf("JIRA_BASE_URL and JIRA_PROJECT must be set")
+		}
+		return j, nil
+	case "webhook":
+		wh := ticket.Webhook{
+			URL:       os.Getenv("LADYBUG_TICKET_WEBHOOK_URL"),
+			DedupeURL: os.Getenv("LADYBUG_TICKET_DEDUPE_URL"),
+		}
+		if wh.URL == "" {
+			return nil, fmt.Errorf("LADYBUG_TICKET_WEBHOOK_URL is not set")
+		}
+		if path := os.Getenv("LADYBUG_TICKET_TEMPLATE"); path != "" {
+			tmpl, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("read ticket template: %w", err)
+			}
+			wh.Template = string(tmpl)
+		}
+		if token := os.Getenv("LADYBUG_TICKET_WEBHOOK_TOKEN"); token != "" {
+			wh.Header = http.Header{"Authorization": {"Bearer " + token}}
+		}
+		return wh, nil
+	}
+	return nil, fmt.Errorf("unknown ticket sink %q (want github, jira or webhook)", name)
+}
+
+// loadAssignees reads the component → assignee (GitHub login or Jira
+// account ID) map named by LADYBUG_ASSIGNEES, if set.
+func loadAssignees() (map[string]string, error) {
+	path := os.Getenv("LADYBUG_ASSIGNEES")
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read assignees: %w", err)
+	}
+	var m map[string]string
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parse assignees %s: %w", path, err)
+	}
+	return m, nil
+}
+
+// fileTicket files rep in the named sink and reports the outcome to w.
+func fileTicket(ctx context.Context, sinkName string, rep *report.Report, dryRun bool, w io.Writer) error {
+	sink, err := ticketSink(sinkName)
+	if err != nil {
+		return err
+	}
+	assignees, err := loadAssignees()
+	if err != nil {
+		return err
+	}
+	filed, err := ticket.File(ctx, sink, ticket.Build(rep, assignees), dryRun, w)
+	if err != nil {
+		return err
+	}
+	switch {
+	case filed == nil:
+	case filed.Existing:
+		fmt.Fprintf(w, "Open %s ticket %s already tracks signature %s: %s\n", sink.Name(), filed.ID, rep.Signature(), filed.URL)
+	default:
+		fmt.Fprintf(w, "Filed %s ticket %s: %s\n", sink.Name(), filed.ID, filed.URL)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/emyjamalian/laas-ladybug/agent"
+)
+
+// toolOptions restrict which tools the model may call in one run.
+type toolOptions struct {
+	allow string
+	deny  string
+}
+
+func (o *toolOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.allow, "allow-tools", "", "comma-separated tool `names` or globs the model may call (default all)")
+	fs.StringVar(&o.deny, "deny-tools", "", "comma-separated tool `names` or globs the model may not call, e.g. propose_patch,git_*")
+}
+
+// apply returns a copy of a limited to the selected tools.
+func (o toolOptions) apply(a *agent.Agent) (*agent.Agent, error) {
+	b, err := a.WithTools(splitList(o.allow), splitList(o.deny))
+	if err != nil {
+		return nil, usageError("%v", err)
+	}
+	return b, nil
+}
+
+// loadPlugins registers the external tool plugins named by LADYBUG_PLUGINS.
+func loadPlugins() error {
+	list := os.Getenv("LADYBUG_PLUGINS")
+	if list == "" {
+		return nil
+	}
+	tools, err := agent.LoadPlugins(list)
+	if err != nil {
+		return err
+	}
+	for _, t := range tools {
+		if err := agent.RegisterTool(t); err != nil {
+			return fmt.Errorf("plugin: %w", err)
+		}
+	}
+	return nil
+}
+
+// loadToolTimeouts applies LADYBUG_TOOL_TIMEOUTS: a default limit and/or
+// name=limit pairs, e.g. "45s,generate_repro_test=5m". 0 means no limit.
+func loadToolTimeouts() error {
+	for _, item := range splitList(os.Getenv("LADYBUG_TOOL_TIMEOUTS")) {
+		name, value, ok := strings.Cut(item, "=")
+		if !ok {
+			name, value = "", item
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("LADYBUG_TOOL_TIMEOUTS: invalid duration %q", value)
+		}
+		if err := agent.SetToolTimeout(strings.TrimSpace(name), d); err != nil {
+			return fmt.Errorf("LADYBUG_TOOL_TIMEOUTS: %w", err)
+		}
+	}
+	return nil
+}
+
+type toolsOptions struct {
+	toolOptions
+	prompt bool
+	schema bool
+}
+
+func (o *toolsOptions) flags() *flag.FlagSet {
+	fs := flag.NewFlagSet("tools", flag.ContinueOnError)
+	o.register(fs)
+	fs.BoolVar(&o.prompt, "prompt", false, "print the system prompt built for these tools")
+	fs.BoolVar(&o.schema, "schema", false, "print the tools and their generated input schemas as JSON (the plugin --describe format)")
+	return fs
+}
+
+// runTools lists the tools an analysis would offer the model with the
+// current configuration. Names given as arguments select tools to show.
+func runTools(args []string) error {
+	var o toolsOptions
+	rest, err := parseArgs(o.flags(), args)
+	if err != nil {
+		return err
+	}
+	a, err := o.apply(agent.New())
+	if err != nil {
+		return err
+	}
+	if o.prompt {
+		fmt.Println(a.SystemPrompt())
+		return nil
+	}
+	list := a.Tools().Tools()
+	if len(rest) > 0 {
+		list = nil
+		for _, name := range rest {
+			t, ok := a.Tools().Lookup(name)
+			if !ok {
+				return usageError("unknown tool %q", name)
+			}
+			list = append(list, t)
+		}
+	}
+	if o.schema {
+		type description struct {
+			Name        string                 `json:"name"`
+			Description string                 `json:"description"`
+			Parameters  map[string]interface{} `json:"parameters"`
+		}
+		out := make([]description, len(list))
+		for i, t := range list {
+			out[i] = description{t.Name(), t.Description(), t.Schema()}
+		}
+		return printJSON(os.Stdout, out)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, t := range list {
+		desc, _, _ := strings.Cut(t.Description(), ". ")
+		timeout := "no limit"
+		if d := a.Tools().Timeout(t.Name()); d > 0 {
+			timeout = d.String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", t.Name(), timeout, strings.TrimSuffix(desc, "."))
+	}
+	return tw.Flush()
+}
@@ -1,6 +1,7 @@
 package tools
 
 import (
+	"context"
 	"encoding/json"
 	"path/filepath"
 	"strings"
@@ -8,9 +9,9 @@ import (
 
 // AttributeIssueInput is the input for the attribute_to_owner tool.
 type AttributeIssueInput struct {
-	FilesChanged   []string `json:"files_changed" jsonschema_description:"List of files changed in the suspected commit or diff"`
+	FilesChanged   []string `json:"files_changed,omitempty" jsonschema_description:"List of files changed in the suspected commit or diff"`
 	Description    string   `json:"description" jsonschema_description:"Description of the regression or bug"`
-	RegressionType string   `json:"regression_type" jsonschema_description:"Type of regression from detect_regression"`
+	RegressionType string   `json:"regression_type" jsonschema_description:"Type of regression from detect_regression" jsonschema_enum:"regression_type"`
 }
 
 // SuspectedOwner represents a likely owner with attribution confidence.
@@ -50,9 +51,9 @@ var componentPatterns = []struct {
 }
 
 // AttributeToOwner identifies suspected owners based on files changed and regression type.
-func AttributeToOwner(inputJSON string) (string, error) {
+func AttributeToOwner(ctx context.Context, inputJSON string) (string, error) {
 	var input AttributeIssueInput
-	if err := json.Unmarshal([]byte(inputJSON), &input); err != nil {
+	if err := decodeInput(inputJSON, &input); err != nil {
 		return "", err
 	}
 
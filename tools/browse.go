@@ -0,0 +1,589 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// The repository-browsing tools (read_file, search_code, list_dir, git_log)
+// let the model inspect the configured checkout. Every path is resolved
+// inside RepoRoot, secrets are never readable, file contents are limited to
+// the allowlist, and every result is capped.
+const (
+	readMaxFileBytes  = 1 << 20 // larger files are not read or searched
+	readMaxLines      = 300
+	readMaxBytes      = 32 << 10
+	searchMaxResults  = 100
+	searchMaxFiles    = 20000
+	searchMaxLineLen  = 200
+	listMaxEntries    = 200
+	gitLogMaxCommits  = 50
+	gitLogMaxFileList = 20
+)
+
+// defaultRepoAllowlist is the set of files the browsing tools may read:
+// source, build and configuration files, matched against the base name.
+var defaultRepoAllowlist = []string{
+	"*.go", "*.mod", "*.sum", "*.py", "*.java", "*.kt", "*.kts", "*.scala", "*.groovy", "*.gradle",
+	"*.js", "*.jsx", "*.mjs", "*.cjs", "*.ts", "*.tsx", "*.vue", "*.svelte", "*.rb", "*.rs", "*.c", "*.cc",
+	"*.cpp", "*.h", "*.hpp", "*.cs", "*.php", "*.swift", "*.m", "*.sh", "*.sql", "*.proto", "*.graphql",
+	"*.html", "*.css", "*.scss", "*.md", "*.rst", "*.txt", "*.json", "*.yaml", "*.yml", "*.toml", "*.xml",
+	"*.ini", "*.cfg", "*.conf", "*.tf", "*.tmpl", "*.lock",
+	"Makefile", "Dockerfile", "Jenkinsfile", "Gemfile", "Procfile", "README*", "LICENSE*", "CODEOWNERS",
+	".gitignore", ".dockerignore", ".editorconfig",
+}
+
+// repoDenylist is never readable or listable, whatever the allowlist says.
+var repoDenylist = []string{
+	".git", ".env", ".env.*", "*.pem", "*.key", "*.p12", "*.pfx", "*.jks", "*.keystore",
+	"id_rsa*", "id_ed25519*", "id_ecdsa*", ".netrc", ".npmrc", ".pypirc", "credentials", "credentials.json",
+	"secrets.*", "*.secret", "*.secrets", "*.tfstate", "*.tfvars",
+}
+
+var (
+	repoAllowMu sync.RWMutex
+	repoAllow   = defaultRepoAllowlist
+)
+
+// SetRepoAllowlist replaces the glob patterns (matched against a file's base
+// name or its repository-relative path) that the browsing tools may read.
+// An empty list restores the default.
+func SetRepoAllowlist(patterns []string) {
+	repoAllowMu.Lock()
+	defer repoAllowMu.Unlock()
+	if len(patterns) == 0 {
+		patterns = defaultRepoAllowlist
+	}
+	repoAllow = patterns
+}
+
+func matchAny(patterns []string, rel string) bool {
+	base := path.Base(rel)
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, base); ok {
+			return true
+		}
+		if ok, _ := path.Match(p, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// denied reports whether rel or any of its parent directories is on the denylist.
+func denied(rel string) bool {
+	for p := rel; p != "." && p != "/" && p != ""; p = path.Dir(p) {
+		if matchAny(repoDenylist, strings.ToLower(p)) {
+			return true
+		}
+	}
+	return false
+}
+
+func readable(rel string) bool {
+	repoAllowMu.RLock()
+	defer repoAllowMu.RUnlock()
+	return !denied(rel) && matchAny(repoAllow, rel)
+}
+
+// resolveRepoPath maps a repository-relative path onto the checkout,
+// rejecting absolute paths, ".." escapes, denied files and symlinks that
+// lead outside the repository or to a denied file. "" and "." mean the
+// repository root.
+func resolveRepoPath(root, rel string) (abs, clean string, err error) {
+	if root == "" {
+		return "", "", fmt.Errorf("no repository configured (set LADYBUG_REPO)")
+	}
+	clean = "."
+	if rel = strings.TrimSpace(rel); rel != "" && rel != "." && rel != "/" {
+		if clean = cleanRel(strings.TrimPrefix(rel, "./")); clean == "" {
+			return "", "", fmt.Errorf("path %q is outside the repository; use a repository-relative path", rel)
+		}
+	}
+	if denied(clean) {
+		return "", "", fmt.Errorf("access to %s is not allowed", clean)
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", "", err
+	}
+	abs, err = filepath.EvalSymlinks(filepath.Join(realRoot, filepath.FromSlash(clean)))
+	if err != nil {
+		var pe *fs.PathError
+		if errors.As(err, &pe) {
+			err = pe.Err
+		}
+		return "", "", fmt.Errorf("%s: %w", clean, err)
+	}
+	r, err := filepath.Rel(realRoot, abs)
+	if err != nil || r == ".." || strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+		return "", "", fmt.Errorf("path %q leaves the repository", rel)
+	}
+	// A symlink inside the repository must not lead to a denied file either.
+	if denied(filepath.ToSlash(r)) {
+		return "", "", fmt.Errorf("access to %s is not allowed", clean)
+	}
+	return abs, clean, nil
+}
+
+// The helpers below are the only way the other tools (propose_patch,
+// generate_repro_test, tailored fix plans) reach the checkout, so they get
+// the same symlink and denylist protection as the browsing tools.
+
+// cleanRel normalises a repository-relative path, returning "" for paths
+// that are absolute or escape the repository.
+func cleanRel(p string) string {
+	if p == "" || path.IsAbs(p) || filepath.IsAbs(p) {
+		return ""
+	}
+	p = path.Clean(filepath.ToSlash(p))
+	if p == ".." || strings.HasPrefix(p, "../") {
+		return ""
+	}
+	return p
+}
+
+// repoRelative maps a path from a stack trace (often absolute, from another
+// machine) onto a file in the repository by trying successively shorter suffixes.
+func repoRelative(root, p string) string {
+	parts := strings.Split(filepath.ToSlash(p), "/")
+	for i := range parts {
+		if f := cleanRel(strings.Join(parts[i:], "/")); f != "" && fileExists(root, f) {
+			return f
+		}
+	}
+	return ""
+}
+
+// fileExists reports whether rel is a regular file resolveRepoPath lets
+// tools reach.
+func fileExists(root, rel string) bool {
+	abs, _, err := resolveRepoPath(root, rel)
+	if err != nil {
+		return false
+	}
+	info, err := os.Stat(abs)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// dirExists reports whether rel is a directory resolveRepoPath lets tools reach.
+func dirExists(root, rel string) bool {
+	abs, _, err := resolveRepoPath(root, rel)
+	if err != nil {
+		return false
+	}
+	info, err := os.Stat(abs)
+	return err == nil && info.IsDir()
+}
+
+// readRepoFile reads a file of the checkout that is on the readable
+// allowlist and within the size cap.
+func readRepoFile(root, rel string) (data []byte, clean string, err error) {
+	abs, clean, err := resolveRepoPath(root, rel)
+	if err != nil {
+		return nil, "", err
+	}
+	info, err := os.Stat(abs)
+	switch {
+	case err != nil:
+		return nil, "", err
+	case !info.Mode().IsRegular():
+		return nil, "", fmt.Errorf("%s is not a regular file", clean)
+	case !readable(clean):
+		return nil, "", fmt.Errorf("%s is not on the readable file allowlist", clean)
+	case info.Size() > readMaxFileBytes:
+		return nil, "", fmt.Errorf("%s is too large to read (%d bytes)", clean, info.Size())
+	}
+	data, err = os.ReadFile(abs)
+	return data, clean, err
+}
+
+// createRepoFile writes data to a new file at rel in the checkout, creating
+// missing directories. It refuses the paths resolveRepoPath refuses,
+// including directories that lead outside the repository through symlinks,
+// and never overwrites or follows an existing file (fs.ErrExist).
+func createRepoFile(root, rel string, data []byte) error {
+	clean := cleanRel(strings.TrimPrefix(strings.TrimSpace(rel), "./"))
+	if clean == "" || clean == "." {
+		return fmt.Errorf("path %q is outside the repository; use a repository-relative path", rel)
+	}
+	if denied(clean) {
+		return fmt.Errorf("access to %s is not allowed", clean)
+	}
+	// Resolve the deepest existing directory; only the missing ones below
+	// it are created, and they cannot be symlinks.
+	dir, missing := path.Dir(clean), "."
+	for {
+		abs, _, err := resolveRepoPath(root, dir)
+		if err == nil {
+			dir = filepath.Join(abs, filepath.FromSlash(missing))
+			break
+		}
+		if dir == "." || !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		missing = path.Join(path.Base(dir), missing)
+		dir = path.Dir(dir)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	name := filepath.Join(dir, path.Base(clean))
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(name)
+		return err
+	}
+	return f.Close()
+}
+
+// ReadFileInput is the input for the read_file tool.
+type ReadFileInput struct {
+	Path      string `json:"path" jsonschema_description:"Repository-relative path of the file to read"`
+	StartLine int    `json:"start_line,omitempty" jsonschema_description:"First line to return, 1-based (optional, default 1)" jsonschema_minimum:"0"`
+	EndLine   int    `json:"end_line,omitempty" jsonschema_description:"Last line to return (optional; at most 300 lines are returned per call)" jsonschema_minimum:"0"`
+}
+
+// ReadFileOutput is a numbered excerpt of a repository file.
+type ReadFileOutput struct {
+	Path       string `json:"path"`
+	StartLine  int    `json:"start_line"`
+	EndLine    int    `json:"end_line"`
+	TotalLines int    `json:"total_lines"`
+	Content    string `json:"content"`
+	// Truncated is set when the requested range was cut short by the caps;
+	// continue from EndLine+1.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// ReadFile returns numbered lines of a file in the configured repository.
+func ReadFile(ctx context.Context, inputJSON string) (string, error) {
+	var input ReadFileInput
+	if err := decodeInput(inputJSON, &input); err != nil {
+		return "", err
+	}
+	abs, rel, err := resolveRepoPath(RepoRoot(), input.Path)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(abs)
+	switch {
+	case err != nil:
+		return "", err
+	case info.IsDir():
+		return "", fmt.Errorf("%s is a directory; use list_dir", rel)
+	case !readable(rel):
+		return "", fmt.Errorf("%s is not on the readable file allowlist", rel)
+	case info.Size() > readMaxFileBytes:
+		return "", fmt.Errorf("%s is too large to read (%d bytes); use search_code to find the relevant lines", rel, info.Size())
+	}
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return "", err
+	}
+	if isBinary(data) {
+		return "", fmt.Errorf("%s is a binary file", rel)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	out := ReadFileOutput{Path: rel, TotalLines: len(lines), StartLine: max(1, input.StartLine)}
+	if out.StartLine > len(lines) {
+		return "", fmt.Errorf("%s has only %d lines", rel, len(lines))
+	}
+	out.EndLine = len(lines)
+	if input.EndLine > 0 && input.EndLine < out.EndLine {
+		out.EndLine = max(input.EndLine, out.StartLine)
+	}
+	var b strings.Builder
+	for i := out.StartLine; i <= out.EndLine; i++ {
+		if i-out.StartLine == readMaxLines || b.Len() > readMaxBytes {
+			out.EndLine, out.Truncated = i-1, true
+			break
+		}
+		fmt.Fprintf(&b, "%5d  %s\n", i, lines[i-1])
+	}
+	out.Content = b.String()
+	result, err := json.Marshal(out)
+	return string(result), err
+}
+
+// SearchCodeInput is the input for the search_code tool.
+type SearchCodeInput struct {
+	Pattern         string `json:"pattern" jsonschema_description:"RE2 regular expression to search for, e.g. 'func \\(s \\*Server\\) Login' or 'user_preferences'"`
+	Path            string `json:"path,omitempty" jsonschema_description:"Repository-relative directory or file to search (optional, default the whole repository)"`
+	Glob            string `json:"glob,omitempty" jsonschema_description:"Only search files whose base name matches this glob, e.g. '*.go' (optional)"`
+	CaseInsensitive bool   `json:"case_insensitive,omitempty" jsonschema_description:"Match case-insensitively (optional)"`
+	MaxResults      int    `json:"max_results,omitempty" jsonschema_description:"Maximum matches to return (optional, default and cap 100)" jsonschema_minimum:"0"`
+}
+
+// CodeMatch is one matching line.
+type CodeMatch struct {
+	File string `json:"file"`
+	Line int    `json:"line"`
+	Text string `json:"text"`
+}
+
+// SearchCodeOutput lists matching lines in the repository.
+type SearchCodeOutput struct {
+	Pattern   string      `json:"pattern"`
+	Matches   []CodeMatch `json:"matches"`
+	Truncated bool        `json:"truncated,omitempty"`
+}
+
+// SearchCode greps the readable files of the configured repository.
+func SearchCode(ctx context.Context, inputJSON string) (string, error) {
+	var input SearchCodeInput
+	if err := decodeInput(inputJSON, &input); err != nil {
+		return "", err
+	}
+	if input.Pattern == "" {
+		return "", fmt.Errorf("pattern is required")
+	}
+	expr := input.Pattern
+	if input.CaseInsensitive {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return "", fmt.Errorf("invalid pattern: %w", err)
+	}
+	if input.Glob != "" {
+		if _, err := path.Match(input.Glob, ""); err != nil {
+			return "", fmt.Errorf("invalid glob %q", input.Glob)
+		}
+	}
+	limit := searchMaxResults
+	if input.MaxResults > 0 && input.MaxResults < limit {
+		limit = input.MaxResults
+	}
+	root := RepoRoot()
+	start, rel, err := resolveRepoPath(root, input.Path)
+	if err != nil {
+		return "", err
+	}
+	realRoot, _ := filepath.EvalSymlinks(root)
+
+	out := SearchCodeOutput{Pattern: input.Pattern, Matches: []CodeMatch{}}
+	files := 0
+	err = filepath.WalkDir(start, func(p string, d fs.DirEntry, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			return nil
+		}
+		r, _ := filepath.Rel(realRoot, p)
+		r = filepath.ToSlash(r)
+		if d.IsDir() {
+			if r != rel && (denied(r) || skipDir(d.Name())) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || !readable(r) {
+			return nil
+		}
+		if input.Glob != "" {
+			if ok, _ := path.Match(input.Glob, d.Name()); !ok {
+				return nil
+			}
+		}
+		if files++; files > searchMaxFiles {
+			out.Truncated = true
+			return filepath.SkipAll
+		}
+		if info, err := d.Info(); err != nil || info.Size() > readMaxFileBytes {
+			return nil
+		}
+		data, err := os.ReadFile(p)
+		if err != nil || isBinary(data) {
+			return nil
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(make([]byte, 64<<10), readMaxFileBytes)
+		for n := 1; scanner.Scan(); n++ {
+			line := scanner.Text()
+			if !re.MatchString(line) {
+				continue
+			}
+			if len(out.Matches) == limit {
+				out.Truncated = true
+				return filepath.SkipAll
+			}
+			line = strings.TrimSpace(line)
+			if len(line) > searchMaxLineLen {
+				line = line[:searchMaxLineLen] + "…"
+			}
+			out.Matches = append(out.Matches, CodeMatch{File: r, Line: n, Text: line})
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	result, err := json.Marshal(out)
+	return string(result), err
+}
+
+// skipDir reports directories that hold dependencies or build output rather
+// than the project's own code.
+func skipDir(name string) bool {
+	switch name {
+	case "node_modules", "vendor", "dist", "build", "target", "__pycache__", ".venv", "venv", ".gradle", ".idea":
+		return true
+	}
+	return false
+}
+
+func isBinary(data []byte) bool {
+	return bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0
+}
+
+// ListDirInput is the input for the list_dir tool.
+type ListDirInput struct {
+	Path string `json:"path,omitempty" jsonschema_description:"Repository-relative directory to list (optional, default the repository root)"`
+}
+
+// DirEntry is one listed file or directory.
+type DirEntry struct {
+	Name string `json:"name"`
+	Type string `json:"type"` // "file", "dir" or "symlink"
+	Size int64  `json:"size,omitempty"`
+}
+
+// ListDirOutput lists a repository directory.
+type ListDirOutput struct {
+	Path      string     `json:"path"`
+	Entries   []DirEntry `json:"entries"`
+	Truncated bool       `json:"truncated,omitempty"`
+}
+
+// ListDir lists a directory of the configured repository. Denied entries
+// such as .git and secrets are omitted.
+func ListDir(ctx context.Context, inputJSON string) (string, error) {
+	var input ListDirInput
+	if err := decodeInput(inputJSON, &input); err != nil {
+		return "", err
+	}
+	abs, rel, err := resolveRepoPath(RepoRoot(), input.Path)
+	if err != nil {
+		return "", err
+	}
+	entries, err := os.ReadDir(abs)
+	if err != nil {
+		return "", fmt.Errorf("%s is not a directory", rel)
+	}
+	out := ListDirOutput{Path: rel, Entries: []DirEntry{}}
+	for _, e := range entries {
+		if denied(path.Join(rel, e.Name())) {
+			continue
+		}
+		if len(out.Entries) == listMaxEntries {
+			out.Truncated = true
+			break
+		}
+		entry := DirEntry{Name: e.Name(), Type: "file"}
+		switch {
+		case e.Type()&fs.ModeSymlink != 0:
+			entry.Type = "symlink"
+		case e.IsDir():
+			entry.Type = "dir"
+		default:
+			if info, err := e.Info(); err == nil {
+				entry.Size = info.Size()
+			}
+		}
+		out.Entries = append(out.Entries, entry)
+	}
+	result, err := json.Marshal(out)
+	return string(result), err
+}
+
+// GitLogInput is the input for the git_log tool.
+type GitLogInput struct {
+	Path     string `json:"path,omitempty" jsonschema_description:"Only show commits touching this repository-relative file or directory (optional)"`
+	MaxCount int    `json:"max_count,omitempty" jsonschema_description:"Number of commits to return (optional, default 10, cap 50)" jsonschema_minimum:"0"`
+	Since    string `json:"since,omitempty" jsonschema_description:"Only commits after this date, e.g. '2024-05-01' or '3 days ago' (optional)"`
+	Grep     string `json:"grep,omitempty" jsonschema_description:"Only commits whose message matches this pattern (optional)"`
+}
+
+// LogCommit is one commit in a git_log result.
+type LogCommit struct {
+	SHA     string   `json:"sha"`
+	Author  string   `json:"author"`
+	Date    string   `json:"date"`
+	Subject string   `json:"subject"`
+	Files   []string `json:"files,omitempty"`
+}
+
+// GitLogOutput lists recent commits.
+type GitLogOutput struct {
+	Path    string      `json:"path,omitempty"`
+	Commits []LogCommit `json:"commits"`
+}
+
+// GitLog returns recent commits of the configured repository with the files
+// each one changed.
+func GitLog(ctx context.Context, inputJSON string) (string, error) {
+	var input GitLogInput
+	if err := decodeInput(inputJSON, &input); err != nil {
+		return "", err
+	}
+	root := RepoRoot()
+	_, rel, err := resolveRepoPath(root, input.Path)
+	if err != nil {
+		return "", err
+	}
+	n := 10
+	if input.MaxCount > 0 {
+		n = min(input.MaxCount, gitLogMaxCommits)
+	}
+	args := []string{"log", fmt.Sprintf("-n%d", n), "--no-color", "--name-only", "--format=%x1e%H%x1f%an%x1f%aI%x1f%s"}
+	if input.Since != "" {
+		args = append(args, "--since="+input.Since)
+	}
+	if input.Grep != "" {
+		args = append(args, "--grep="+input.Grep)
+	}
+	args = append(args, "--", rel)
+	raw, err := git(ctx, root, args...)
+	if err != nil {
+		return "", err
+	}
+
+	out := GitLogOutput{Commits: []LogCommit{}}
+	if rel != "." {
+		out.Path = rel
+	}
+	for _, rec := range strings.Split(raw, "\x1e") {
+		lines := strings.Split(strings.TrimSpace(rec), "\n")
+		f := strings.Split(lines[0], "\x1f")
+		if len(f) != 4 {
+			continue
+		}
+		c := LogCommit{SHA: f[0], Author: f[1], Date: f[2], Subject: f[3]}
+		for _, file := range lines[1:] {
+			if file = strings.TrimSpace(file); file != "" && !denied(file) && len(c.Files) < gitLogMaxFileList {
+				c.Files = append(c.Files, file)
+			}
+		}
+		out.Commits = append(out.Commits, c)
+	}
+	result, err := json.Marshal(out)
+	return string(result), err
+}
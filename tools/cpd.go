@@ -0,0 +1,359 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// EnvironmentStage is one stage of the delivery pipeline in a CPD model.
+type EnvironmentStage struct {
+	// Multiplier is how much more a bug costs when found at this stage
+	// compared to the IDE.
+	Multiplier float64 `json:"multiplier"`
+	// ShiftLeft is the stage immediately to the left of this one, i.e. the
+	// next-earlier place the bug could have been caught. Empty for the
+	// leftmost stage.
+	ShiftLeft string `json:"shift_left,omitempty"`
+}
+
+// PriorityThresholds are the minimum CPD scores for P0, P1 and P2.
+// Anything below P2 is P3.
+type PriorityThresholds struct {
+	P0 float64 `json:"p0"`
+	P1 float64 `json:"p1"`
+	P2 float64 `json:"p2"`
+}
+
+// User impact scaling modes.
+const (
+	// UserImpactStep is the original three-step factor: 1.0x up to 100 users,
+	// 1.5x up to 1000, 2.0x beyond.
+	UserImpactStep = "step"
+	// UserImpactLog scales continuously: 1 + weight × log10(1 + users).
+	UserImpactLog = "log"
+)
+
+// CPDModel parameterises the Cost Per Developer calculation:
+//
+//	CPD = severity score × environment multiplier × user impact × revenue factor × SLA factor
+type CPDModel struct {
+	Environments   map[string]EnvironmentStage `json:"environments"`
+	SeverityScores map[string]float64          `json:"severity_scores"`
+	// DefaultEnvironment is used for environments the model does not know.
+	DefaultEnvironment string `json:"default_environment"`
+	// DefaultSeverity is used for severities the model does not know.
+	DefaultSeverity string `json:"default_severity"`
+
+	UserImpact       string  `json:"user_impact"`
+	UserImpactWeight float64 `json:"user_impact_weight,omitempty"`
+	// MaxUserImpact caps the user impact factor; 0 means uncapped.
+	MaxUserImpact float64 `json:"max_user_impact,omitempty"`
+
+	// RevenueWeight scales revenue at risk: 1 + weight × log10(1 + revenue per hour).
+	RevenueWeight float64 `json:"revenue_weight,omitempty"`
+	// SLAWeights maps SLA tiers (e.g. "gold") to a multiplier.
+	SLAWeights map[string]float64 `json:"sla_weights,omitempty"`
+
+	Thresholds PriorityThresholds `json:"thresholds"`
+	// OrgThresholds overrides Thresholds per organisation.
+	OrgThresholds map[string]PriorityThresholds `json:"org_thresholds,omitempty"`
+
+	// Detectability overrides the built-in detectability matrix:
+	// regression type → stage → technique that catches it there. Use it to
+	// teach the model what custom stages (e.g. canary) can catch.
+	Detectability map[string]map[string]string `json:"detectability,omitempty"`
+}
+
+// DefaultCPDModel reproduces the original Fix Fast cost model. Its chain
+// follows the framework's order: production → staging → code_review → ci →
+// local_test → ide.
+func DefaultCPDModel() CPDModel {
+	return CPDModel{
+		Environments: map[string]EnvironmentStage{
+			"ide":         {Multiplier: 1},
+			"local_test":  {Multiplier: 3, ShiftLeft: "ide"},
+			"ci":          {Multiplier: 10, ShiftLeft: "local_test"},
+			"code_review": {Multiplier: 15, ShiftLeft: "ci"},
+			"staging":     {Multiplier: 30, ShiftLeft: "code_review"},
+			"production":  {Multiplier: 100, ShiftLeft: "staging"},
+		},
+		SeverityScores: map[string]float64{
+			"critical": 100.0,
+			"high":     50.0,
+			"medium":   20.0,
+			"low":      5.0,
+		},
+		DefaultEnvironment: "staging",
+		DefaultSeverity:    "medium",
+		UserImpact:         UserImpactStep,
+		Thresholds:         PriorityThresholds{P0: 5000, P1: 1000, P2: 200},
+	}
+}
+
+// LoadCPDModel reads a CPD model from a JSON file. Fields left out of the
+// file, including single thresholds, keep their DefaultCPDModel values;
+// environments, severities and the entries of the other maps listed in the
+// file are added to (or override) the defaults, and a default environment
+// listed in the file keeps the stage fields the file leaves out.
+func LoadCPDModel(path string) (CPDModel, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return CPDModel{}, fmt.Errorf("read CPD model: %w", err)
+	}
+	// Decoding over the defaults merges top-level fields and adds to the
+	// maps, but replaces a struct stored under a map key whole, so each
+	// environment is decoded over its default stage separately: overriding
+	// production's multiplier keeps its place in the shift-left chain.
+	m := DefaultCPDModel()
+	if err := json.Unmarshal(data, &m); err != nil {
+		return CPDModel{}, fmt.Errorf("parse CPD model %s: %w", path, err)
+	}
+	var envs struct {
+		Environments map[string]json.RawMessage `json:"environments"`
+	}
+	if err := json.Unmarshal(data, &envs); err != nil {
+		return CPDModel{}, fmt.Errorf("parse CPD model %s: %w", path, err)
+	}
+	defaults := DefaultCPDModel().Environments
+	for name, raw := range envs.Environments {
+		stage := defaults[name]
+		if err := json.Unmarshal(raw, &stage); err != nil {
+			return CPDModel{}, fmt.Errorf("parse CPD model %s: environment %q: %w", path, name, err)
+		}
+		m.Environments[name] = stage
+	}
+	if err := m.Validate(); err != nil {
+		return CPDModel{}, fmt.Errorf("CPD model %s: %w", path, err)
+	}
+	return m, nil
+}
+
+// Validate checks that the model is internally consistent.
+func (m CPDModel) Validate() error {
+	if _, ok := m.Environments[m.DefaultEnvironment]; !ok {
+		return fmt.Errorf("default_environment %q is not a defined environment", m.DefaultEnvironment)
+	}
+	if _, ok := m.SeverityScores[m.DefaultSeverity]; !ok {
+		return fmt.Errorf("default_severity %q is not a defined severity", m.DefaultSeverity)
+	}
+	for name, score := range m.SeverityScores {
+		if score <= 0 {
+			return fmt.Errorf("severity %q: score must be positive", name)
+		}
+	}
+	for name, stage := range m.Environments {
+		if stage.Multiplier <= 0 {
+			return fmt.Errorf("environment %q: multiplier must be positive", name)
+		}
+		if stage.ShiftLeft == "" {
+			continue
+		}
+		if _, ok := m.Environments[stage.ShiftLeft]; !ok {
+			return fmt.Errorf("environment %q: shift_left %q is not a defined environment", name, stage.ShiftLeft)
+		}
+		// Walk the chain to make sure it terminates.
+		seen := map[string]bool{name: true}
+		for cur := stage.ShiftLeft; cur != ""; cur = m.Environments[cur].ShiftLeft {
+			if seen[cur] {
+				return fmt.Errorf("environment %q: shift_left chain loops at %q", name, cur)
+			}
+			seen[cur] = true
+		}
+	}
+	switch m.UserImpact {
+	case UserImpactStep, UserImpactLog:
+	default:
+		return fmt.Errorf("user_impact must be %q or %q, got %q", UserImpactStep, UserImpactLog, m.UserImpact)
+	}
+	for org, t := range m.OrgThresholds {
+		if !(t.P0 >= t.P1 && t.P1 >= t.P2) {
+			return fmt.Errorf("org %q: thresholds must satisfy p0 >= p1 >= p2", org)
+		}
+	}
+	if !(m.Thresholds.P0 >= m.Thresholds.P1 && m.Thresholds.P1 >= m.Thresholds.P2) {
+		return fmt.Errorf("thresholds must satisfy p0 >= p1 >= p2")
+	}
+	return nil
+}
+
+// EnvironmentNames returns the model's environments ordered from the most
+// expensive stage to the cheapest.
+func (m CPDModel) EnvironmentNames() []string {
+	names := make([]string, 0, len(m.Environments))
+	for name := range m.Environments {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		mi, mj := m.Environments[names[i]].Multiplier, m.Environments[names[j]].Multiplier
+		if mi != mj {
+			return mi > mj
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+var (
+	cpdModelMu sync.RWMutex
+	cpdModel   = DefaultCPDModel()
+)
+
+// SetCPDModel replaces the model used by triage_issue.
+func SetCPDModel(m CPDModel) error {
+	if err := m.Validate(); err != nil {
+		return err
+	}
+	cpdModelMu.Lock()
+	defer cpdModelMu.Unlock()
+	cpdModel = m
+	return nil
+}
+
+// CurrentCPDModel returns the model used by triage_issue.
+func CurrentCPDModel() CPDModel {
+	cpdModelMu.RLock()
+	defer cpdModelMu.RUnlock()
+	return cpdModel
+}
+
+// cpdFactor is one multiplicative term of a CPD score and its explanation.
+type cpdFactor struct {
+	value       float64
+	explanation string
+}
+
+// cpdBreakdown is the result of scoring one triage input.
+type cpdBreakdown struct {
+	environment string // the stage actually used (after defaulting)
+	baseScore   float64
+	stage       EnvironmentStage
+	userImpact  float64
+	// downstream holds the factors that do not depend on the environment:
+	// user impact, revenue and SLA.
+	downstream []cpdFactor
+	notes      []string
+	score      float64
+	thresholds PriorityThresholds
+	org        string // set when org-specific thresholds apply
+}
+
+// score computes the CPD score for input under the model.
+func (m CPDModel) score(input TriageIssueInput) cpdBreakdown {
+	b := cpdBreakdown{environment: input.Environment}
+
+	stage, ok := m.Environments[input.Environment]
+	if !ok {
+		b.notes = append(b.notes, fmt.Sprintf("unknown environment %q scored as %s", input.Environment, m.DefaultEnvironment))
+		b.environment = m.DefaultEnvironment
+		stage = m.Environments[m.DefaultEnvironment]
+	}
+	b.stage = stage
+
+	base, ok := m.SeverityScores[input.Severity]
+	if !ok {
+		b.notes = append(b.notes, fmt.Sprintf("unknown severity %q scored as %s", input.Severity, m.DefaultSeverity))
+		base = m.SeverityScores[m.DefaultSeverity]
+	}
+	b.baseScore = base
+
+	b.userImpact = m.userImpactFactor(input.AffectedUsersEstimate)
+	b.downstream = append(b.downstream, cpdFactor{b.userImpact,
+		fmt.Sprintf("%.2fx user impact (%d users, %s scale)", b.userImpact, input.AffectedUsersEstimate, m.UserImpact)})
+
+	if m.RevenueWeight > 0 && input.RevenueImpactPerHour > 0 {
+		f := 1 + m.RevenueWeight*math.Log10(1+input.RevenueImpactPerHour)
+		b.downstream = append(b.downstream, cpdFactor{f,
+			fmt.Sprintf("%.2fx revenue impact (%.0f/hour at risk, weight %.2f)", f, input.RevenueImpactPerHour, m.RevenueWeight)})
+	}
+	if input.SLATier != "" {
+		if f, ok := m.SLAWeights[input.SLATier]; ok {
+			b.downstream = append(b.downstream, cpdFactor{f, fmt.Sprintf("%.2fx SLA weight (%s tier)", f, input.SLATier)})
+		} else {
+			b.notes = append(b.notes, fmt.Sprintf("no SLA weight configured for tier %q", input.SLATier))
+		}
+	}
+
+	b.thresholds = m.Thresholds
+	if t, ok := m.OrgThresholds[input.Org]; ok && input.Org != "" {
+		b.thresholds = t
+		b.org = input.Org
+	}
+
+	b.score = b.scoreAt(stage.Multiplier)
+	return b
+}
+
+// scoreAt returns what the CPD score would be at a stage with the given multiplier.
+func (b cpdBreakdown) scoreAt(multiplier float64) float64 {
+	s := b.baseScore * multiplier
+	for _, f := range b.downstream {
+		s *= f.value
+	}
+	return math.Round(s*100) / 100
+}
+
+func (m CPDModel) userImpactFactor(users int) float64 {
+	if users < 0 {
+		users = 0
+	}
+	var f float64
+	switch m.UserImpact {
+	case UserImpactLog:
+		weight := m.UserImpactWeight
+		if weight == 0 {
+			weight = 0.25
+		}
+		f = 1 + weight*math.Log10(1+float64(users))
+	default:
+		f = 1.0
+		if users > 1000 {
+			f = 2.0
+		} else if users > 100 {
+			f = 1.5
+		}
+	}
+	if m.MaxUserImpact > 0 && f > m.MaxUserImpact {
+		f = m.MaxUserImpact
+	}
+	return math.Round(f*100) / 100
+}
+
+// priority maps the score onto P0-P3 using the effective thresholds.
+func (b cpdBreakdown) priority() Priority {
+	switch {
+	case b.score >= b.thresholds.P0:
+		return PriorityP0
+	case b.score >= b.thresholds.P1:
+		return PriorityP1
+	case b.score >= b.thresholds.P2:
+		return PriorityP2
+	default:
+		return PriorityP3
+	}
+}
+
+// explain renders every factor of the score.
+func (b cpdBreakdown) explain() string {
+	parts := []string{
+		fmt.Sprintf("Base severity score %.0f", b.baseScore),
+		fmt.Sprintf("%gx environment multiplier (found in %s)", b.stage.Multiplier, b.environment),
+	}
+	for _, f := range b.downstream {
+		parts = append(parts, f.explanation)
+	}
+	s := strings.Join(parts, " × ") + fmt.Sprintf(" = CPD %.0f. ", b.score)
+	scope := "Thresholds"
+	if b.org != "" {
+		scope = b.org + " thresholds"
+	}
+	s += fmt.Sprintf("%s: P0 ≥ %.0f, P1 ≥ %.0f, P2 ≥ %.0f.", scope, b.thresholds.P0, b.thresholds.P1, b.thresholds.P2)
+	if len(b.notes) > 0 {
+		s += " Note: " + strings.Join(b.notes, "; ") + "."
+	}
+	return s
+}
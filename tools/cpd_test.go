@@ -0,0 +1,61 @@
+package tools_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/emyjamalian/laas-ladybug/tools"
+)
+
+func TestLoadCPDModelPartialOverride(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cpd.json")
+	data := `{"environments": {"production": {"multiplier": 200}, "canary": {"multiplier": 60, "shift_left": "staging"}}, "thresholds": {"p0": 8000}}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := tools.LoadCPDModel(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Environments["production"]; got.Multiplier != 200 || got.ShiftLeft != "staging" {
+		t.Errorf("production = %+v, want multiplier 200 and the default shift left to staging", got)
+	}
+	if got := m.Environments["canary"]; got.Multiplier != 60 || got.ShiftLeft != "staging" {
+		t.Errorf("canary = %+v, want it added", got)
+	}
+	if got := m.Environments["ci"]; got != tools.DefaultCPDModel().Environments["ci"] {
+		t.Errorf("ci = %+v, want the default", got)
+	}
+	if m.Thresholds.P0 != 8000 || m.Thresholds.P1 != 1000 {
+		t.Errorf("thresholds = %+v, want p0 overridden and the rest default", m.Thresholds)
+	}
+
+	if err := tools.SetCPDModel(m); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = tools.SetCPDModel(tools.DefaultCPDModel()) })
+	out, err := tools.TriageIssue(context.Background(), `{"regression_type": "null_pointer", "severity": "high", "environment": "production", "affected_users_estimate": 10}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var triage tools.TriageIssueOutput
+	if err := json.Unmarshal([]byte(out), &triage); err != nil {
+		t.Fatal(err)
+	}
+	var stages []string
+	for _, s := range triage.ShiftLeftPath {
+		stages = append(stages, s.Stage)
+	}
+	want := []string{"production", "staging", "code_review", "ci", "local_test", "ide"}
+	if len(stages) != len(want) {
+		t.Fatalf("shift-left path = %v, want %v", stages, want)
+	}
+	for i := range want {
+		if stages[i] != want[i] {
+			t.Fatalf("shift-left path = %v, want %v", stages, want)
+		}
+	}
+}
@@ -3,6 +3,7 @@
 package tools
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"math"
@@ -38,13 +39,13 @@ const (
 type DetectRegressionInput struct {
 	Description  string   `json:"description" jsonschema_description:"Description of the bug, crash, or code change to analyze for regressions"`
 	FilesChanged []string `json:"files_changed,omitempty" jsonschema_description:"List of files modified in the change (optional)"`
-	Environment  string   `json:"environment" jsonschema_description:"Where the issue was found: ide, local_test, ci, code_review, staging, or production"`
-	ErrorMessage string   `json:"error_message,omitempty" jsonschema_description:"The actual error or stack trace if available"`
+	Environment  string   `json:"environment,omitempty" jsonschema_description:"Where the issue was found: ide, local_test, ci, code_review, staging, or production (optional, defaults to the CPD model's default stage)" jsonschema_enum:"environment"`
+	ErrorMessage string   `json:"error_message,omitempty" jsonschema_description:"The actual error or stack trace if available (optional)"`
 	// RunHistory is an optional list of recent run results for the same test or check,
 	// oldest first. Each entry must be "pass" or "fail". When provided, statistical
 	// confidence is derived from the failure rate across runs (BrowserLab-style) rather
 	// than purely from keyword scoring.
-	RunHistory []string `json:"run_history,omitempty" jsonschema_description:"Recent run results oldest-first, each 'pass' or 'fail'. Enables statistical confidence scoring."`
+	RunHistory []string `json:"run_history,omitempty" jsonschema_description:"Recent run results oldest-first, each 'pass' or 'fail'. Enables BrowserLab-style statistical confidence scoring." jsonschema_enum:"run_result"`
 }
 
 // RunHistoryStats holds the statistical analysis of the run history.
@@ -88,11 +89,14 @@ var regressionSignals = []struct {
 }
 
 // DetectRegression analyzes a description and returns a structured regression report.
-func DetectRegression(inputJSON string) (string, error) {
+func DetectRegression(ctx context.Context, inputJSON string) (string, error) {
 	var input DetectRegressionInput
-	if err := json.Unmarshal([]byte(inputJSON), &input); err != nil {
+	if err := decodeInput(inputJSON, &input); err != nil {
 		return "", err
 	}
+	if input.Environment == "" {
+		input.Environment = CurrentCPDModel().DefaultEnvironment
+	}
 
 	desc := strings.ToLower(input.Description + " " + input.ErrorMessage)
 	output := DetectRegressionOutput{
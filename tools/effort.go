@@ -0,0 +1,211 @@
+package tools
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"sort"
+	"sync"
+	"time"
+)
+
+// EffortEstimate is a time-to-fix range with the factors that produced it.
+type EffortEstimate struct {
+	P50Hours float64        `json:"p50_hours"`
+	P90Hours float64        `json:"p90_hours"`
+	Factors  []EffortFactor `json:"factors"`
+	// HistorySamples is how many past fixes informed the estimate.
+	HistorySamples int `json:"history_samples,omitempty"`
+}
+
+// EffortFactor is one input to the estimate and how much it scaled it.
+type EffortFactor struct {
+	Name       string  `json:"name"`
+	Value      string  `json:"value"`
+	Multiplier float64 `json:"multiplier"`
+}
+
+// FixHistory supplies actual time-to-fix for past regressions. An empty
+// component matches any component.
+type FixHistory interface {
+	FixDurations(regressionType, component string) ([]time.Duration, error)
+}
+
+var (
+	historyMu  sync.RWMutex
+	fixHistory FixHistory
+)
+
+// SetFixHistory sets where effort estimates look up past fixes. nil disables
+// history.
+func SetFixHistory(h FixHistory) {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	fixHistory = h
+}
+
+func currentFixHistory() FixHistory {
+	historyMu.RLock()
+	defer historyMu.RUnlock()
+	return fixHistory
+}
+
+// effortBaselines is the median hours to fix an isolated, medium-severity
+// regression of each type. The p90 is spreadP90 times the median.
+var effortBaselines = map[string]float64{
+	"null_pointer":        2,
+	"performance":         8,
+	"crash":               4,
+	"security_flaw":       16,
+	"memory_leak":         8,
+	"logic_error":         4,
+	"data_corruption":     12,
+	"api_breaking_change": 6,
+}
+
+const (
+	defaultEffortBaseline = 6
+	spreadP90             = 3
+	// minHistorySamples is how many past fixes are needed before history
+	// is used at all; historyPrior is how many samples it takes for history
+	// to outweigh the model.
+	minHistorySamples = 3
+	historyPrior      = 5
+)
+
+var severityEffort = map[string]float64{
+	"critical": 1.5,
+	"high":     1.2,
+	"medium":   1,
+	"low":      0.8,
+}
+
+// estimateEffort computes a p50/p90 time-to-fix from the shape of the
+// change and, when available, how long similar regressions took to fix.
+func estimateEffort(input GenerateFixPlanInput) *EffortEstimate {
+	e := &EffortEstimate{}
+	base, ok := effortBaselines[input.RegressionType]
+	if !ok {
+		base = defaultEffortBaseline
+	}
+	p50 := base
+	e.factor("regression_type", orUnknown(input.RegressionType), 1)
+
+	scale := func(name, value string, m float64) {
+		if m != 1 {
+			p50 *= m
+			e.factor(name, value, m)
+		}
+	}
+	if m, ok := severityEffort[input.Severity]; ok {
+		scale("severity", input.Severity, m)
+	}
+	if n := len(input.AffectedFiles); n > 1 {
+		scale("affected_files", fmt.Sprint(n), math.Min(1+0.15*float64(n-1), 2.5))
+	}
+	if n := countComponents(input.AffectedFiles); n > 1 {
+		scale("components", fmt.Sprint(n), 1+0.3*float64(n-1))
+	}
+	switch d := input.DiffLines; {
+	case d > 500:
+		scale("diff_lines", fmt.Sprint(d), 1.6)
+	case d > 200:
+		scale("diff_lines", fmt.Sprint(d), 1.3)
+	case d > 0 && d < 20:
+		scale("diff_lines", fmt.Sprint(d), 0.8)
+	}
+	p90 := p50 * spreadP90
+	e.Factors[0].Value = fmt.Sprintf("%s (baseline %s)", e.Factors[0].Value, formatHours(base))
+
+	if h := currentFixHistory(); h != nil {
+		samples, scope := fixSamples(h, input.RegressionType, input.Component)
+		if n := len(samples); n >= minHistorySamples {
+			w := float64(n) / float64(n+historyPrior)
+			hp50, hp90 := percentile(samples, 0.5), percentile(samples, 0.9)
+			p50 = w*hp50 + (1-w)*p50
+			p90 = w*hp90 + (1-w)*p90
+			e.HistorySamples = n
+			e.Factors = append(e.Factors, EffortFactor{
+				Name:       "history",
+				Value:      fmt.Sprintf("%d past %s fixes: p50 %s, p90 %s (weight %.0f%%)", n, scope, formatHours(hp50), formatHours(hp90), w*100),
+				Multiplier: 1,
+			})
+		}
+	}
+
+	e.P50Hours = roundHours(p50)
+	e.P90Hours = roundHours(math.Max(p90, p50))
+	return e
+}
+
+// fixSamples prefers history for the same type and component, falling
+// back to the same type anywhere.
+func fixSamples(h FixHistory, regressionType, component string) ([]float64, string) {
+	toHours := func(ds []time.Duration) []float64 {
+		out := make([]float64, 0, len(ds))
+		for _, d := range ds {
+			if d > 0 {
+				out = append(out, d.Hours())
+			}
+		}
+		return out
+	}
+	if component != "" && component != "unknown" {
+		if ds, err := h.FixDurations(regressionType, component); err == nil {
+			if s := toHours(ds); len(s) >= minHistorySamples {
+				return s, regressionType + " in " + component
+			}
+		}
+	}
+	ds, err := h.FixDurations(regressionType, "")
+	if err != nil {
+		return nil, ""
+	}
+	return toHours(ds), regressionType
+}
+
+func (e *EffortEstimate) factor(name, value string, m float64) {
+	e.Factors = append(e.Factors, EffortFactor{Name: name, Value: value, Multiplier: m})
+}
+
+// String renders the estimate for the plan's estimated_effort field.
+func (e *EffortEstimate) String() string {
+	return fmt.Sprintf("%s (p50) – %s (p90)", formatHours(e.P50Hours), formatHours(e.P90Hours))
+}
+
+// countComponents counts the distinct directories among files.
+func countComponents(files []string) int {
+	dirs := map[string]bool{}
+	for _, f := range files {
+		dirs[path.Dir(f)] = true
+	}
+	return len(dirs)
+}
+
+// percentile returns the nearest-rank percentile of xs.
+func percentile(xs []float64, p float64) float64 {
+	s := append([]float64(nil), xs...)
+	sort.Float64s(s)
+	i := int(math.Ceil(p*float64(len(s)))) - 1
+	return s[max(i, 0)]
+}
+
+func roundHours(h float64) float64 {
+	if h < 1 {
+		return math.Round(h*4) / 4
+	}
+	return math.Round(h*2) / 2
+}
+
+// formatHours renders hours, switching to 8-hour working days past two days.
+func formatHours(h float64) string {
+	switch {
+	case h < 1:
+		return fmt.Sprintf("%.0f minutes", h*60)
+	case h > 16:
+		return fmt.Sprintf("%.1f days", h/8)
+	case h == 1:
+		return "1 hour"
+	}
+	return fmt.Sprintf("%g hours", roundHours(h))
+}
@@ -1,17 +1,21 @@
 package tools
 
 import (
+	"context"
 	"encoding/json"
 	"strings"
 )
 
 // GenerateFixPlanInput is the input for the generate_fix_plan tool.
 type GenerateFixPlanInput struct {
-	RegressionType string   `json:"regression_type" jsonschema_description:"Type of regression from detect_regression"`
-	Severity       string   `json:"severity" jsonschema_description:"Severity level: critical, high, medium, or low"`
-	AffectedFiles  []string `json:"affected_files" jsonschema_description:"Files involved in the regression"`
+	RegressionType string   `json:"regression_type" jsonschema_description:"Type of regression from detect_regression" jsonschema_enum:"regression_type"`
+	Severity       string   `json:"severity" jsonschema_description:"Severity level: critical, high, medium, or low" jsonschema_enum:"severity"`
+	AffectedFiles  []string `json:"affected_files,omitempty" jsonschema_description:"Files involved in the regression"`
 	RootCause      string   `json:"root_cause" jsonschema_description:"Description of the suspected root cause"`
-	Priority       string   `json:"priority" jsonschema_description:"Priority from triage: P0, P1, P2, or P3"`
+	Priority       string   `json:"priority" jsonschema_description:"Priority from triage: P0, P1, P2, or P3" jsonschema_enum:"priority"`
+	Component      string   `json:"component,omitempty" jsonschema_description:"Highest-confidence component from attribute_to_owner; used to look up past fix times"`
+	DiffLines      int      `json:"diff_lines,omitempty" jsonschema_description:"Number of changed lines in the suspected change (optional)" jsonschema_minimum:"0"`
+	Diff           string   `json:"diff,omitempty" jsonschema_description:"The suspected change as a unified diff, if available; scanned for feature flags that can mitigate it"`
 }
 
 // FixStep represents a single actionable step in the fix plan.
@@ -29,8 +33,16 @@ type GenerateFixPlanOutput struct {
 	PreventionMeasures     []string  `json:"prevention_measures"`
 	ShiftLeftRecommendations []string `json:"shift_left_recommendations"`
 	EstimatedEffort        string    `json:"estimated_effort"`
+	// Effort is the structured estimate EstimatedEffort is rendered from.
+	Effort                 *EffortEstimate `json:"effort,omitempty"`
+	// FlagCandidates are feature flags referenced by the suspected change;
+	// turning one off may mitigate the regression without a deploy.
+	FlagCandidates         []string `json:"flag_candidates,omitempty"`
 	RollbackPlan           string    `json:"rollback_plan"`
 	TestStrategy           string    `json:"test_strategy"`
+	// RepoContext is the repository tooling the plan was tailored to, if a
+	// repository root is configured (see SetRepoRoot).
+	RepoContext *RepoContext `json:"repo_context,omitempty"`
 }
 
 // fixPlaybooks maps regression types to structured fix strategies.
@@ -58,7 +70,6 @@ var fixPlaybooks = map[string]GenerateFixPlanOutput{
 			"Run staticcheck in pre-commit hook (shift to local_test)",
 			"Add nil-pointer detection to CI pipeline",
 		},
-		EstimatedEffort: "1-4 hours",
 		RollbackPlan:    "Revert the commit that removed the non-null guarantee",
 		TestStrategy:    "Unit test with nil/zero-value inputs, integration test for the affected flow",
 	},
@@ -86,7 +97,6 @@ var fixPlaybooks = map[string]GenerateFixPlanOutput{
 			"Use continuous profiling in staging before prod promotion",
 			"Add performance linting to catch O(n²) patterns statically",
 		},
-		EstimatedEffort: "4-16 hours (profiling + fix + benchmarks)",
 		RollbackPlan:    "Revert to previous release; apply hotfix forward",
 		TestStrategy:    "Benchmark tests, load testing with realistic data volumes",
 	},
@@ -113,7 +123,6 @@ var fixPlaybooks = map[string]GenerateFixPlanOutput{
 			"Add panic/exception tracking to staging environment",
 			"Enable race detector in test runs (go test -race)",
 		},
-		EstimatedEffort: "2-8 hours for hotfix; 1-3 days for root cause fix",
 		RollbackPlan:    "Immediately revert via feature flag; if no flag, revert deployment",
 		TestStrategy:    "Crash reproduction test, fault injection, end-to-end scenario test",
 	},
@@ -141,7 +150,6 @@ var fixPlaybooks = map[string]GenerateFixPlanOutput{
 			"Require security review checklist in PR template",
 			"Add automated SQL injection / XSS checks to CI",
 		},
-		EstimatedEffort: "1-2 days for patch; 1 week for full audit",
 		RollbackPlan:    "Immediately revert; do NOT wait for a clean fix if actively exploited",
 		TestStrategy:    "OWASP test suite, targeted exploit PoC test, regression test suite",
 	},
@@ -165,16 +173,15 @@ var fixPlaybooks = map[string]GenerateFixPlanOutput{
 			"Run go test -memprofile in CI and fail on unexpected heap growth",
 			"Enable leak detector in integration tests",
 		},
-		EstimatedEffort: "4-12 hours",
 		RollbackPlan:    "Revert the change; restart affected services to clear leaked memory",
 		TestStrategy:    "Memory benchmark, long-running soak test, heap profiling",
 	},
 }
 
 // GenerateFixPlan produces an actionable fix plan based on regression type and context.
-func GenerateFixPlan(inputJSON string) (string, error) {
+func GenerateFixPlan(ctx context.Context, inputJSON string) (string, error) {
 	var input GenerateFixPlanInput
-	if err := json.Unmarshal([]byte(inputJSON), &input); err != nil {
+	if err := decodeInput(inputJSON, &input); err != nil {
 		return "", err
 	}
 
@@ -201,12 +208,36 @@ func GenerateFixPlan(inputJSON string) (string, error) {
 				"Add test coverage to catch this class of bug earlier in the pipeline",
 				"Consider adding a linter rule to detect this pattern",
 			},
-			EstimatedEffort:  "Unknown — depends on root cause",
 			RollbackPlan:     "Revert the introducing commit",
 			TestStrategy:     "Unit test covering the failure scenario",
 		}
 	}
 
+	// Tailor generic advice to the repository's actual tooling.
+	if root := RepoRoot(); root != "" {
+		if rc, err := DetectRepo(root); err == nil && len(rc.Languages) > 0 {
+			tailorToRepo(&playbook, rc, input)
+			playbook.RepoContext = rc
+		}
+	}
+
+	playbook.Effort = estimateEffort(input)
+	playbook.EstimatedEffort = playbook.Effort.String()
+
+	// Flags touched by the change are the fastest mitigation there is.
+	flags := flagCandidates(ctx, input)
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if len(flags) > 0 {
+		playbook.FlagCandidates = flags
+		actions := make([]string, 0, len(flags)+len(playbook.ImmediateActions))
+		for _, f := range flags {
+			actions = append(actions, "Turn off feature flag '"+f+"' (referenced in the change): ladybug mitigate --flag "+f+" --off")
+		}
+		playbook.ImmediateActions = append(actions, playbook.ImmediateActions...)
+	}
+
 	// Customize based on severity/priority.
 	if input.Priority == "P0" || input.Severity == "critical" {
 		playbook.ImmediateActions = append([]string{
@@ -0,0 +1,109 @@
+package tools
+
+import (
+	"context"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// FlagCatalog lists the feature flags a flag provider knows about.
+type FlagCatalog interface {
+	Keys(ctx context.Context) ([]string, error)
+}
+
+var (
+	flagsMu     sync.RWMutex
+	flagCatalog FlagCatalog
+)
+
+// SetFlagCatalog sets the flag provider used to recognize flag keys in
+// diffs; nil limits detection to SDK call patterns.
+func SetFlagCatalog(c FlagCatalog) {
+	flagsMu.Lock()
+	defer flagsMu.Unlock()
+	flagCatalog = c
+}
+
+func currentFlagCatalog() FlagCatalog {
+	flagsMu.RLock()
+	defer flagsMu.RUnlock()
+	return flagCatalog
+}
+
+// flagCall matches flag evaluations in the common SDKs: LaunchDarkly
+// (BoolVariation, variationDetail), OpenFeature (BooleanValue,
+// GetBooleanValue, getStringDetails), Unleash (isEnabled) and home-grown
+// helpers (IsFeatureEnabled, featureFlag, flags.enabled). Generic calls such
+// as ctx.Value("request_id") do not match. An optional leading context
+// argument is skipped.
+var flagCall = regexp.MustCompile("(?:\\b\\w*[Vv]ariation(?:Detail)?|\\b(?:[Gg]et)?(?:Boolean|String|Integer|Int|Float|Number|Object)(?:Value|Details|ValueDetails)|\\b[Ii]s[Ee]nabled|\\b[Ii]s[Ff]eature\\w*|\\b[Ff]eature[Ff]lag\\w*|\\b[Ff]lags?\\.[Ee]nabled)\\s*\\(\\s*(?:[\\w.]+\\s*,\\s*)?[\"'`]([\\w.:/-]+)[\"'`]")
+
+var quotedString = regexp.MustCompile("[\"'`]([\\w.:/-]+)[\"'`]")
+
+// FlagReferences returns the feature-flag keys used on the changed lines of
+// a unified diff: keys passed to flag SDK calls, plus any quoted string that
+// is a key in known. When known is non-empty only its keys are returned, so
+// an SDK-looking call with an unknown key is not mistaken for a flag. Text
+// that is not a diff is scanned whole.
+func FlagReferences(diff string, known []string) []string {
+	knownSet := map[string]bool{}
+	for _, k := range known {
+		knownSet[k] = true
+	}
+	lines := strings.Split(diff, "\n")
+	isDiff := strings.Contains(diff, "\n@@") || strings.HasPrefix(diff, "@@")
+
+	found := map[string]bool{}
+	for _, line := range lines {
+		if isDiff {
+			if strings.HasPrefix(line, "+++") || strings.HasPrefix(line, "---") {
+				continue
+			}
+			if !strings.HasPrefix(line, "+") && !strings.HasPrefix(line, "-") {
+				continue
+			}
+		}
+		for _, m := range flagCall.FindAllStringSubmatch(line, -1) {
+			if len(knownSet) == 0 || knownSet[m[1]] {
+				found[m[1]] = true
+			}
+		}
+		for _, m := range quotedString.FindAllStringSubmatch(line, -1) {
+			if knownSet[m[1]] {
+				found[m[1]] = true
+			}
+		}
+	}
+	out := make([]string, 0, len(found))
+	for k := range found {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// flagCandidates finds flags referenced by the suspected change: the given
+// diff, or else the last commit touching the affected files.
+func flagCandidates(ctx context.Context, input GenerateFixPlanInput) []string {
+	diff := input.Diff
+	if diff == "" && len(input.AffectedFiles) > 0 {
+		if root := RepoRoot(); root != "" {
+			args := append([]string{"log", "-1", "-p", "--format=", "--"}, input.AffectedFiles...)
+			diff, _ = git(ctx, root, args...)
+		}
+	}
+	if diff == "" {
+		return nil
+	}
+	var known []string
+	if c := currentFlagCatalog(); c != nil {
+		var err error
+		if known, err = c.Keys(ctx); err != nil {
+			slog.Warn("listing feature flags failed; matching flag SDK calls only", "err", err)
+		}
+	}
+	return FlagReferences(diff, known)
+}
@@ -0,0 +1,337 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ProposePatchInput is the input for the propose_patch tool.
+type ProposePatchInput struct {
+	File           string `json:"file,omitempty" jsonschema_description:"Repository-relative path of the file to fix (optional if stack_trace or diff is given)"`
+	Line           int    `json:"line,omitempty" jsonschema_description:"Line number of the failure in file (optional)" jsonschema_minimum:"0"`
+	StackTrace     string `json:"stack_trace,omitempty" jsonschema_description:"Stack trace; the first frame inside the repository is used to locate the fix"`
+	Diff           string `json:"diff,omitempty" jsonschema_description:"The suspected change as a unified diff; its first hunk is used to locate the fix"`
+	RegressionType string `json:"regression_type" jsonschema_description:"Type of regression from detect_regression" jsonschema_enum:"regression_type"`
+	RootCause      string `json:"root_cause" jsonschema_description:"Description of the suspected root cause"`
+}
+
+// ProposePatchOutput is a validated (or rejected) unified diff.
+type ProposePatchOutput struct {
+	File      string   `json:"file"`
+	Line      int      `json:"line,omitempty"`
+	Patch     string   `json:"patch,omitempty"`
+	Files     []string `json:"files,omitempty"`
+	Valid     bool     `json:"valid"`
+	Rejection string   `json:"rejection,omitempty"`
+}
+
+// Completer sends a single prompt to the language model and returns its reply.
+type Completer func(ctx context.Context, system, prompt string) (string, error)
+
+// patchContextLines is how many lines either side of the failure are shown to the model.
+const patchContextLines = 25
+
+const patchSystemPrompt = `You are a careful senior engineer writing the smallest possible fix for a regression.
+Reply with ONLY a unified diff in git format (--- a/<path>, +++ b/<path>, @@ hunks with correct line numbers and context).
+Only edit file contents: no renames, copies, mode changes, symlinks or binary files.
+Do not reformat unrelated code. Do not add explanations outside the diff.`
+
+var (
+	frameRef   = regexp.MustCompile(`([\w./-]+\.(?:go|py|java|kt|js|jsx|ts|tsx|rb|rs|c|cc|cpp|h|cs|php|scala|swift)):(\d+)`)
+	pyFrameRef = regexp.MustCompile(`File "([^"]+)", line (\d+)`)
+	hunkHeader = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+(\d+)(?:,\d+)? @@`)
+	diffFence  = regexp.MustCompile("(?s)```(?:diff|patch)?\\s*\\n(.*?)```")
+)
+
+type attributionKey struct{}
+
+// WithAttribution returns a copy of ctx carrying the attribute_to_owner
+// output recorded for the analysis, which bounds what propose_patch may touch.
+func WithAttribution(ctx context.Context, a *AttributeIssueOutput) context.Context {
+	if a == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, attributionKey{}, a)
+}
+
+// attributedFiles returns the file paths of the highest-confidence component
+// of the attribution carried by ctx, if any.
+func attributedFiles(ctx context.Context) []string {
+	a, _ := ctx.Value(attributionKey{}).(*AttributeIssueOutput)
+	if a == nil {
+		return nil
+	}
+	for _, o := range a.SuspectedOwners {
+		if o.Component == a.HighestConfidence {
+			return o.FilePaths
+		}
+	}
+	return nil
+}
+
+// ProposePatch returns the propose_patch handler. complete is used to ask the
+// model for the fix; the resulting diff is only returned as valid if it stays
+// inside the attributed component and applies cleanly with git apply --check.
+// The component is the one recorded by attribute_to_owner (see
+// WithAttribution), never one named by the model; without an attribution
+// the patch may only touch the directory of the file being fixed.
+func ProposePatch(complete Completer) Handler {
+	return func(ctx context.Context, inputJSON string) (string, error) {
+		var input ProposePatchInput
+		if err := decodeInput(inputJSON, &input); err != nil {
+			return "", err
+		}
+		root := RepoRoot()
+		if root == "" {
+			return "", fmt.Errorf("propose_patch needs a repository root (set LADYBUG_REPO)")
+		}
+
+		file, line := locateFix(root, input)
+		if file == "" {
+			return "", fmt.Errorf("could not locate the file to fix: give file, or a stack_trace or diff referencing a file in the repository")
+		}
+		excerpt, err := readExcerpt(root, file, line)
+		if err != nil {
+			return "", err
+		}
+
+		prompt := fmt.Sprintf("Regression type: %s\nRoot cause: %s\n\nFile: %s\n", input.RegressionType, input.RootCause, file)
+		if line > 0 {
+			prompt += fmt.Sprintf("Failure at line %d.\n", line)
+		}
+		if input.StackTrace != "" {
+			prompt += "\nStack trace:\n" + input.StackTrace + "\n"
+		}
+		prompt += "\nSource (line numbers on the left are for reference only, they are not part of the file):\n" + excerpt
+
+		reply, err := complete(ctx, patchSystemPrompt, prompt)
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		if err != nil {
+			return "", fmt.Errorf("ask model for patch: %w", err)
+		}
+
+		out := ProposePatchOutput{File: file, Line: line, Patch: extractDiff(reply)}
+		var allowed []string
+		for _, f := range attributedFiles(ctx) {
+			if rel := firstNonEmpty(repoRelative(root, f), cleanRel(f)); rel != "" {
+				allowed = append(allowed, rel)
+			}
+		}
+		if len(allowed) == 0 {
+			allowed = []string{file}
+		}
+		out.Files, err = ValidatePatch(ctx, root, out.Patch, allowed)
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		if err != nil {
+			out.Rejection = err.Error()
+		} else {
+			out.Valid = true
+		}
+
+		result, err := json.Marshal(out)
+		return string(result), err
+	}
+}
+
+// locateFix picks the file and line to fix from explicit input, a stack
+// trace or a diff, preferring the first reference that exists under root.
+func locateFix(root string, input ProposePatchInput) (string, int) {
+	if f := cleanRel(input.File); f != "" && fileExists(root, f) {
+		return f, input.Line
+	}
+	for _, m := range append(pyFrameRef.FindAllStringSubmatch(input.StackTrace, -1), frameRef.FindAllStringSubmatch(input.StackTrace, -1)...) {
+		line, _ := strconv.Atoi(m[2])
+		if f := repoRelative(root, m[1]); f != "" {
+			return f, line
+		}
+	}
+	if input.Diff != "" {
+		var file string
+		scanner := bufio.NewScanner(strings.NewReader(input.Diff))
+		for scanner.Scan() {
+			text := scanner.Text()
+			if strings.HasPrefix(text, "+++ ") {
+				file = repoRelative(root, strings.TrimPrefix(strings.TrimPrefix(text, "+++ "), "b/"))
+			} else if m := hunkHeader.FindStringSubmatch(text); m != nil && file != "" {
+				line, _ := strconv.Atoi(m[1])
+				return file, line
+			}
+		}
+	}
+	return "", 0
+}
+
+// readExcerpt returns numbered source lines of the repository file rel
+// around line (or the head of the file if line is unknown).
+func readExcerpt(root, rel string, line int) (string, error) {
+	data, _, err := readRepoFile(root, rel)
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(string(data), "\n")
+	from, to := 1, min(len(lines), 2*patchContextLines)
+	if line > 0 {
+		from = max(1, line-patchContextLines)
+		to = min(len(lines), line+patchContextLines)
+	}
+	var b strings.Builder
+	for i := from; i <= to; i++ {
+		fmt.Fprintf(&b, "%5d  %s\n", i, lines[i-1])
+	}
+	return b.String(), nil
+}
+
+// extractDiff pulls the unified diff out of a model reply, tolerating
+// markdown fences and leading prose.
+func extractDiff(reply string) string {
+	if m := diffFence.FindStringSubmatch(reply); m != nil {
+		reply = m[1]
+	}
+	if i := strings.Index(reply, "--- "); i > 0 {
+		if j := strings.LastIndex(reply[:i], "\ndiff --git "); j >= 0 {
+			i = j + 1
+		}
+		reply = reply[i:]
+	}
+	reply = strings.TrimSpace(reply)
+	if reply != "" {
+		reply += "\n"
+	}
+	return reply
+}
+
+// PatchFiles returns the repository-relative paths a unified diff touches:
+// those in the ---/+++ lines and, for git diffs, in the diff --git headers
+// and rename/copy lines. Renames, copies, mode changes, symlinks and
+// submodules are rejected, since a minimal fix needs none of them and they
+// can touch files without ---/+++ lines.
+func PatchFiles(patch string) ([]string, error) {
+	var files []string
+	seen := map[string]bool{}
+	add := func(p string) error {
+		p = patchPath(p)
+		if p == "" || p == "/dev/null" {
+			return nil
+		}
+		rel := cleanRel(p)
+		if rel == "" {
+			return fmt.Errorf("patch touches a path outside the repository: %s", p)
+		}
+		if !seen[rel] {
+			seen[rel] = true
+			files = append(files, rel)
+		}
+		return nil
+	}
+	scanner := bufio.NewScanner(strings.NewReader(patch))
+	for scanner.Scan() {
+		text := scanner.Text()
+		switch {
+		case strings.HasPrefix(text, "--- "), strings.HasPrefix(text, "+++ "):
+			if err := add(text[4:]); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(text, "diff --git "):
+			a, b := splitGitHeader(strings.TrimPrefix(text, "diff --git "))
+			for _, p := range []string{a, b} {
+				if err := add(p); err != nil {
+					return nil, err
+				}
+			}
+		case strings.HasPrefix(text, "rename from "), strings.HasPrefix(text, "rename to "),
+			strings.HasPrefix(text, "copy from "), strings.HasPrefix(text, "copy to "),
+			strings.HasPrefix(text, "old mode "), strings.HasPrefix(text, "new mode "):
+			return nil, fmt.Errorf("patch renames, copies or changes the mode of a file (%s); propose a plain edit", text)
+		case strings.HasPrefix(text, "new file mode "), strings.HasPrefix(text, "deleted file mode "), strings.HasPrefix(text, "index "):
+			f := strings.Fields(text)
+			if mode := f[len(f)-1]; mode == "120000" || mode == "160000" {
+				return nil, fmt.Errorf("patch adds or removes a symlink or submodule (%s)", text)
+			}
+		case strings.HasPrefix(text, "GIT binary patch"), strings.HasPrefix(text, "Binary files "):
+			return nil, fmt.Errorf("patch changes a binary file")
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("not a unified diff: no file headers found")
+	}
+	return files, nil
+}
+
+// patchPath extracts the path from a diff header field: it drops a
+// trailing tab and timestamp, unquotes git's quoted form and strips the a/
+// or b/ prefix.
+func patchPath(p string) string {
+	p = strings.TrimSpace(p)
+	if i := strings.IndexByte(p, '\t'); i >= 0 {
+		p = p[:i]
+	}
+	if strings.HasPrefix(p, `"`) {
+		if u, err := strconv.Unquote(p); err == nil {
+			p = u
+		}
+	}
+	return strings.TrimPrefix(strings.TrimPrefix(p, "a/"), "b/")
+}
+
+// splitGitHeader splits the "a/X b/Y" of a diff --git line, either part of
+// which git may quote.
+func splitGitHeader(s string) (a, b string) {
+	if strings.HasPrefix(s, `"`) {
+		if q, err := strconv.QuotedPrefix(s); err == nil {
+			return q, strings.TrimSpace(s[len(q):])
+		}
+	}
+	if i := strings.Index(s, " b/"); i >= 0 {
+		return s[:i], s[i+1:]
+	}
+	if i := strings.Index(s, ` "b/`); i >= 0 {
+		return s[:i], s[i+1:]
+	}
+	return s, ""
+}
+
+// ValidatePatch checks that patch only touches files in the directories of
+// allowed and that it applies cleanly to the checkout at root. It returns the
+// files the patch touches.
+func ValidatePatch(ctx context.Context, root, patch string, allowed []string) ([]string, error) {
+	files, err := PatchFiles(patch)
+	if err != nil {
+		return nil, err
+	}
+	dirs := map[string]bool{}
+	for _, a := range allowed {
+		if a = cleanRel(a); a != "" {
+			dirs[path.Dir(a)] = true
+		}
+	}
+	for _, f := range files {
+		if denied(f) {
+			return files, fmt.Errorf("patch touches %s, which is not allowed", f)
+		}
+		if !dirs[path.Dir(f)] {
+			return files, fmt.Errorf("patch touches %s, which is outside the attributed component", f)
+		}
+	}
+
+	cmd := exec.CommandContext(ctx, "git", "apply", "--check", "-")
+	cmd.Dir = root
+	cmd.Stdin = strings.NewReader(patch)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return files, fmt.Errorf("patch does not apply cleanly: %s", strings.TrimSpace(firstNonEmpty(stderr.String(), err.Error())))
+	}
+	return files, nil
+}
@@ -0,0 +1,89 @@
+package tools_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/emyjamalian/laas-ladybug/tools"
+)
+
+const hunk = "@@ -1,3 +1,3 @@\n package auth\n-var x = 1\n+var x = 2\n \n"
+
+func TestPatchFiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		patch   string
+		want    []string
+		wantErr string
+	}{
+		{
+			name:  "plain diff",
+			patch: "--- a/auth/login.go\n+++ b/auth/login.go\n" + hunk,
+			want:  []string{"auth/login.go"},
+		},
+		{
+			name: "git header names another file",
+			patch: "diff --git a/auth/login.go b/auth/login.go\nindex 1200003..4567890 100644\n--- a/auth/login.go\n+++ b/auth/login.go\n" + hunk +
+				"diff --git a/.env b/.env\nindex 1111111..2222222 100644\n",
+			want: []string{"auth/login.go", ".env"},
+		},
+		{
+			name:  "quoted git header",
+			patch: "diff --git \"a/auth/log in.go\" \"b/auth/log in.go\"\n--- \"a/auth/log in.go\"\n+++ \"b/auth/log in.go\"\n" + hunk,
+			want:  []string{"auth/log in.go"},
+		},
+		{
+			name: "rename without ---/+++ lines",
+			patch: "--- a/auth/login.go\n+++ b/auth/login.go\n" + hunk +
+				"diff --git a/auth/keys.go b/billing/keys.go\nsimilarity index 100%\nrename from auth/keys.go\nrename to billing/keys.go\n",
+			wantErr: "renames, copies or changes the mode",
+		},
+		{
+			name:    "copy",
+			patch:   "diff --git a/auth/login.go b/deploy/login.go\nsimilarity index 100%\ncopy from auth/login.go\ncopy to deploy/login.go\n",
+			wantErr: "renames, copies or changes the mode",
+		},
+		{
+			name:    "mode change",
+			patch:   "diff --git a/auth/login.go b/auth/login.go\nold mode 100644\nnew mode 100755\n",
+			wantErr: "renames, copies or changes the mode",
+		},
+		{
+			name:    "new symlink",
+			patch:   "diff --git a/auth/link b/auth/link\nnew file mode 120000\n--- /dev/null\n+++ b/auth/link\n@@ -0,0 +1 @@\n+/etc/passwd\n",
+			wantErr: "symlink or submodule",
+		},
+		{
+			name:    "path escapes the repository",
+			patch:   "--- a/../etc/passwd\n+++ b/../etc/passwd\n" + hunk,
+			wantErr: "outside the repository",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tools.PatchFiles(tt.patch)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("PatchFiles = %v, %v; want an error containing %q", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("PatchFiles = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatePatchChecksGitHeaders(t *testing.T) {
+	patch := "diff --git a/auth/login.go b/auth/login.go\n--- a/auth/login.go\n+++ b/auth/login.go\n" + hunk +
+		"diff --git a/billing/charge.go b/billing/charge.go\nindex 1111111..2222222 100644\n"
+	_, err := tools.ValidatePatch(context.Background(), t.TempDir(), patch, []string{"auth/login.go"})
+	if err == nil || !strings.Contains(err.Error(), "billing/charge.go") {
+		t.Fatalf("ValidatePatch = %v, want billing/charge.go rejected as outside the component", err)
+	}
+}
@@ -0,0 +1,436 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SLO is a service's reliability target and how much error budget is left.
+type SLO struct {
+	// Target is the availability objective, e.g. 0.999.
+	Target float64 `json:"target"`
+	// ErrorBudgetRemaining is the fraction of the current window's budget
+	// still unspent (1 = untouched, 0 or less = exhausted).
+	ErrorBudgetRemaining float64 `json:"error_budget_remaining"`
+	// BurnRate is how fast the budget is being spent relative to plan
+	// (1 = on track to exactly exhaust it). Optional.
+	BurnRate float64 `json:"burn_rate,omitempty"`
+}
+
+// SLOSource looks up the SLO state of a service. It returns nil, nil for
+// services without an SLO.
+type SLOSource interface {
+	SLO(ctx context.Context, service string) (*SLO, error)
+}
+
+// FileSLOs reads SLO state from a JSON file mapping service names to SLOs.
+// The file is re-read on every lookup so an external job can keep it fresh.
+type FileSLOs struct {
+	Path string
+}
+
+func (f FileSLOs) SLO(ctx context.Context, service string) (*SLO, error) {
+	data, err := os.ReadFile(f.Path)
+	if err != nil {
+		return nil, fmt.Errorf("read SLO file: %w", err)
+	}
+	var slos map[string]SLO
+	if err := json.Unmarshal(data, &slos); err != nil {
+		return nil, fmt.Errorf("parse SLO file %s: %w", f.Path, err)
+	}
+	if slo, ok := slos[service]; ok {
+		return &slo, nil
+	}
+	return nil, nil
+}
+
+// PrometheusSLOs queries a Prometheus-compatible HTTP API for SLO state.
+// Queries may contain a {service} placeholder; each must return a single
+// instant-vector sample.
+type PrometheusSLOs struct {
+	URL           string  `json:"url"`
+	Target        float64 `json:"target"`
+	BudgetQuery   string  `json:"budget_query"`
+	BurnRateQuery string  `json:"burn_rate_query,omitempty"`
+	HTTP          *http.Client
+}
+
+func (p PrometheusSLOs) SLO(ctx context.Context, service string) (*SLO, error) {
+	remaining, found, err := p.query(ctx, p.BudgetQuery, service)
+	if err != nil || !found {
+		return nil, err
+	}
+	slo := &SLO{Target: p.Target, ErrorBudgetRemaining: remaining}
+	if p.BurnRateQuery != "" {
+		if burn, ok, err := p.query(ctx, p.BurnRateQuery, service); err == nil && ok {
+			slo.BurnRate = burn
+		}
+	}
+	return slo, nil
+}
+
+func (p PrometheusSLOs) query(ctx context.Context, q, service string) (float64, bool, error) {
+	q = strings.ReplaceAll(q, "{service}", service)
+	u := strings.TrimRight(p.URL, "/") + "/api/v1/query?query=" + url.QueryEscape(q)
+	client := p.HTTP
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return 0, false, fmt.Errorf("query SLO: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, false, fmt.Errorf("query SLO: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+		Data   struct {
+			Result []struct {
+				Value [2]interface{} `json:"value"`
+			} `json:"result"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return 0, false, fmt.Errorf("decode SLO query response: %w", err)
+	}
+	if body.Status != "success" {
+		return 0, false, fmt.Errorf("SLO query failed: %s", body.Error)
+	}
+	if len(body.Data.Result) == 0 {
+		return 0, false, nil
+	}
+	raw, _ := body.Data.Result[0].Value[1].(string)
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("SLO query returned non-numeric sample %q", raw)
+	}
+	return v, true, nil
+}
+
+// Freeze is a period during which releases are frozen.
+type Freeze struct {
+	Name  string    `json:"name"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// BusinessHours defines when on-call is staffed without paging.
+type BusinessHours struct {
+	// Timezone is an IANA zone name; default UTC.
+	Timezone string `json:"timezone"`
+	// Days lists working days as three-letter names; default mon-fri.
+	Days []string `json:"days,omitempty"`
+	// Start and End are "HH:MM" in Timezone; default 09:00-17:00.
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+}
+
+// TriagePolicy makes triage time- and reliability-aware. All parts are optional.
+type TriagePolicy struct {
+	SLOs          SLOSource
+	Freezes       []Freeze
+	BusinessHours *BusinessHours
+	// DefaultService is used when the triage input names no service.
+	DefaultService string
+	// EscalateBelow is the error-budget fraction under which priority is
+	// raised one level; default 0.1.
+	EscalateBelow float64
+}
+
+// triagePolicyFile is the on-disk form of a TriagePolicy.
+type triagePolicyFile struct {
+	SLOFile        string          `json:"slo_file,omitempty"`
+	Prometheus     *PrometheusSLOs `json:"prometheus,omitempty"`
+	ReleaseFreezes []Freeze        `json:"release_freezes,omitempty"`
+	BusinessHours  *BusinessHours  `json:"business_hours,omitempty"`
+	DefaultService string          `json:"default_service,omitempty"`
+	EscalateBelow  float64         `json:"escalate_below,omitempty"`
+}
+
+// LoadTriagePolicy reads a policy from a JSON file. A relative slo_file is
+// resolved against the policy file's directory.
+func LoadTriagePolicy(path string) (*TriagePolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read triage policy: %w", err)
+	}
+	var f triagePolicyFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parse triage policy %s: %w", path, err)
+	}
+	p := &TriagePolicy{
+		Freezes:        f.ReleaseFreezes,
+		BusinessHours:  f.BusinessHours,
+		DefaultService: f.DefaultService,
+		EscalateBelow:  f.EscalateBelow,
+	}
+	switch {
+	case f.SLOFile != "" && f.Prometheus != nil:
+		return nil, fmt.Errorf("triage policy %s: set slo_file or prometheus, not both", path)
+	case f.SLOFile != "":
+		sloPath := f.SLOFile
+		if !filepath.IsAbs(sloPath) {
+			sloPath = filepath.Join(filepath.Dir(path), sloPath)
+		}
+		p.SLOs = FileSLOs{Path: sloPath}
+	case f.Prometheus != nil:
+		p.SLOs = *f.Prometheus
+	}
+	if p.BusinessHours != nil {
+		if _, _, _, err := p.BusinessHours.window(); err != nil {
+			return nil, fmt.Errorf("triage policy %s: %w", path, err)
+		}
+	}
+	return p, nil
+}
+
+var (
+	triagePolicyMu sync.RWMutex
+	triagePolicy   *TriagePolicy
+
+	// now is the clock used for time-aware triage.
+	now = time.Now
+)
+
+// SetClock replaces the clock time-aware triage evaluates issues without a
+// detected_at against, e.g. to replay recorded analyses; nil restores the
+// wall clock.
+func SetClock(f func() time.Time) {
+	triagePolicyMu.Lock()
+	defer triagePolicyMu.Unlock()
+	if f == nil {
+		f = time.Now
+	}
+	now = f
+}
+
+// SetTriagePolicy enables time-aware triage; nil disables it.
+func SetTriagePolicy(p *TriagePolicy) {
+	triagePolicyMu.Lock()
+	defer triagePolicyMu.Unlock()
+	triagePolicy = p
+}
+
+func currentTriagePolicy() *TriagePolicy {
+	triagePolicyMu.RLock()
+	defer triagePolicyMu.RUnlock()
+	return triagePolicy
+}
+
+func clock() time.Time {
+	triagePolicyMu.RLock()
+	defer triagePolicyMu.RUnlock()
+	return now()
+}
+
+// TimeContext explains how time and reliability state changed a triage decision.
+type TimeContext struct {
+	EvaluatedAt          string   `json:"evaluated_at"`
+	Service              string   `json:"service,omitempty"`
+	WithinBusinessHours  *bool    `json:"within_business_hours,omitempty"`
+	ReleaseFreeze        string   `json:"release_freeze,omitempty"`
+	ErrorBudgetRemaining *float64 `json:"error_budget_remaining,omitempty"`
+	FollowUpAt           string   `json:"follow_up_at,omitempty"`
+	Adjustments          []string `json:"adjustments,omitempty"`
+}
+
+// apply adjusts priority and action for the moment the issue was detected.
+func (p *TriagePolicy) apply(ctx context.Context, input TriageIssueInput, priority Priority, action string) (Priority, string, *TimeContext) {
+	at := clock()
+	if input.DetectedAt != "" {
+		if t, err := time.Parse(time.RFC3339, input.DetectedAt); err == nil {
+			at = t
+		}
+	}
+	tc := &TimeContext{EvaluatedAt: at.UTC().Format(time.RFC3339)}
+	notes := []string{}
+	budgetAtRisk := false
+
+	// Error budget: a service that is about to blow its SLO cannot absorb
+	// another regression, so escalate.
+	service := input.Service
+	if service == "" {
+		service = p.DefaultService
+	}
+	if p.SLOs != nil && service != "" {
+		tc.Service = service
+		slo, err := p.SLOs.SLO(ctx, service)
+		switch {
+		case err != nil:
+			tc.Adjustments = append(tc.Adjustments, "SLO lookup failed: "+err.Error())
+		case slo != nil:
+			remaining := math.Round(slo.ErrorBudgetRemaining*1000) / 1000
+			tc.ErrorBudgetRemaining = &remaining
+			threshold := p.EscalateBelow
+			if threshold == 0 {
+				threshold = 0.1
+			}
+			if slo.ErrorBudgetRemaining < threshold {
+				budgetAtRisk = true
+				if up := escalate(priority); up != priority {
+					tc.Adjustments = append(tc.Adjustments, fmt.Sprintf(
+						"escalated %s → %s: %s has %.1f%% of its error budget left (SLO %g)",
+						priority, up, service, slo.ErrorBudgetRemaining*100, slo.Target))
+					priority = up
+					action = recommendedAction(up)
+				}
+				if slo.ErrorBudgetRemaining <= 0 {
+					notes = append(notes, "Error budget exhausted: halt feature releases for "+service+" until it recovers.")
+				} else {
+					notes = append(notes, fmt.Sprintf("Error budget nearly exhausted (%.1f%% left).", slo.ErrorBudgetRemaining*100))
+				}
+			}
+		}
+	}
+
+	// During a release freeze nothing ships normally: urgent issues are
+	// reverted rather than fixed forward, the rest wait for the freeze to end.
+	var freeze *Freeze
+	for i, f := range p.Freezes {
+		if !at.Before(f.Start) && at.Before(f.End) {
+			freeze = &p.Freezes[i]
+			tc.ReleaseFreeze = f.Name
+			break
+		}
+	}
+	if freeze != nil {
+		until := freeze.End.UTC().Format(time.RFC3339)
+		switch priority {
+		case PriorityP0, PriorityP1:
+			tc.Adjustments = append(tc.Adjustments, "release freeze "+freeze.Name+" in effect: revert instead of fixing forward")
+			action = freezeAction(priority, freeze.Name, until)
+		default:
+			tc.FollowUpAt = until
+			tc.Adjustments = append(tc.Adjustments, "release freeze "+freeze.Name+" in effect: fix deferred until "+until)
+			action = fmt.Sprintf("Hold the fix until release freeze %q ends at %s, then schedule it with an owner assigned.", freeze.Name, until)
+		}
+	}
+
+	// Outside business hours, only genuinely critical issues (or ones eating
+	// the last of the error budget) justify a page.
+	if p.BusinessHours != nil {
+		inHours, next := p.BusinessHours.contains(at)
+		tc.WithinBusinessHours = &inHours
+		if !inHours && priority == PriorityP0 && input.Severity != string(SeverityCritical) && !budgetAtRisk {
+			tc.FollowUpAt = next.Format(time.RFC3339)
+			tc.Adjustments = append(tc.Adjustments, "downgraded P0 → P1: outside business hours and severity is not critical")
+			priority = PriorityP1
+			action = "Do not page outside business hours. Scheduled follow-up at " + tc.FollowUpAt +
+				"; assign to the owning team and block release if unresolved."
+			if freeze != nil {
+				action += fmt.Sprintf(" Release freeze %q is in effect: revert rather than fix forward.", freeze.Name)
+			}
+		} else if !inHours && priority == PriorityP1 {
+			tc.FollowUpAt = next.Format(time.RFC3339)
+			action += " Outside business hours: scheduled follow-up at " + tc.FollowUpAt + "."
+		}
+	}
+
+	if len(notes) > 0 {
+		action += " " + strings.Join(notes, " ")
+	}
+	return priority, action, tc
+}
+
+// freezeAction is the recommended action for an urgent issue while releases
+// are frozen until until.
+func freezeAction(p Priority, name, until string) string {
+	if p == PriorityP0 {
+		return fmt.Sprintf("Page on-call immediately. Revert the offending change within 1 hour: release freeze %q is in effect until %s, so a forward fix ships only as an approved hotfix.", name, until)
+	}
+	return fmt.Sprintf("Revert today rather than fixing forward: release freeze %q is in effect until %s, so a forward fix needs an approved hotfix. Assign to the last committer.", name, until)
+}
+
+func escalate(p Priority) Priority {
+	switch p {
+	case PriorityP3:
+		return PriorityP2
+	case PriorityP2:
+		return PriorityP1
+	case PriorityP1:
+		return PriorityP0
+	}
+	return p
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// window resolves the configured zone, days and daily minutes-of-day range.
+func (h BusinessHours) window() (*time.Location, map[time.Weekday]bool, [2]int, error) {
+	var span [2]int
+	loc := time.UTC
+	if h.Timezone != "" {
+		l, err := time.LoadLocation(h.Timezone)
+		if err != nil {
+			return nil, nil, span, fmt.Errorf("business_hours timezone: %w", err)
+		}
+		loc = l
+	}
+	days := map[time.Weekday]bool{}
+	names := h.Days
+	if len(names) == 0 {
+		names = []string{"mon", "tue", "wed", "thu", "fri"}
+	}
+	for _, d := range names {
+		wd, ok := weekdays[strings.ToLower(d)[:min(3, len(d))]]
+		if !ok {
+			return nil, nil, span, fmt.Errorf("business_hours: unknown day %q", d)
+		}
+		days[wd] = true
+	}
+	for i, s := range []string{h.Start, h.End} {
+		if s == "" {
+			s = []string{"09:00", "17:00"}[i]
+		}
+		t, err := time.Parse("15:04", s)
+		if err != nil {
+			return nil, nil, span, fmt.Errorf("business_hours: bad time %q (want HH:MM)", s)
+		}
+		span[i] = t.Hour()*60 + t.Minute()
+	}
+	if span[0] >= span[1] {
+		return nil, nil, span, fmt.Errorf("business_hours: start must be before end")
+	}
+	return loc, days, span, nil
+}
+
+// contains reports whether t falls in business hours and, if not, when the
+// next business period starts.
+func (h BusinessHours) contains(t time.Time) (bool, time.Time) {
+	loc, days, span, err := h.window()
+	if err != nil {
+		// An invalid policy never suppresses paging.
+		return true, t
+	}
+	local := t.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	if days[local.Weekday()] && minute >= span[0] && minute < span[1] {
+		return true, t
+	}
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	for i := 0; i < 8; i++ {
+		start := day.AddDate(0, 0, i).Add(time.Duration(span[0]) * time.Minute)
+		if days[start.Weekday()] && start.After(local) {
+			return false, start
+		}
+	}
+	return false, t
+}
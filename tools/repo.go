@@ -0,0 +1,257 @@
+package tools
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// RepoContext describes the languages and tooling detected in a local
+// repository, so fix plans can reference the project's real commands.
+type RepoContext struct {
+	Root      string   `json:"root"`
+	Languages []string `json:"languages"`
+	// GoModule is the module path from go.mod, if any.
+	GoModule       string   `json:"go_module,omitempty"`
+	PackageManager string   `json:"package_manager,omitempty"`
+	TestCommand    string   `json:"test_command,omitempty"`
+	BenchCommand   string   `json:"bench_command,omitempty"`
+	LintCommand    string   `json:"lint_command,omitempty"`
+	LinterConfigs  []string `json:"linter_configs,omitempty"`
+	CIConfigs      []string `json:"ci_configs,omitempty"`
+	MakeTargets    []string `json:"make_targets,omitempty"`
+}
+
+// Has reports whether lang was detected.
+func (c *RepoContext) Has(lang string) bool {
+	for _, l := range c.Languages {
+		if l == lang {
+			return true
+		}
+	}
+	return false
+}
+
+// Primary returns the first detected language, or "".
+func (c *RepoContext) Primary() string {
+	if len(c.Languages) == 0 {
+		return ""
+	}
+	return c.Languages[0]
+}
+
+var (
+	repoRootMu sync.RWMutex
+	repoRoot   string
+)
+
+// SetRepoRoot points repository-aware tools at a local checkout.
+// An empty path disables repository inspection.
+func SetRepoRoot(path string) {
+	repoRootMu.Lock()
+	defer repoRootMu.Unlock()
+	repoRoot = path
+}
+
+// RepoRoot returns the configured repository root, or "".
+func RepoRoot() string {
+	repoRootMu.RLock()
+	defer repoRootMu.RUnlock()
+	return repoRoot
+}
+
+var makeTarget = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9_.-]*)\s*:([^=]|$)`)
+
+// linterConfigFiles are well-known linter configuration files, by language.
+var linterConfigFiles = map[string][]string{
+	"go":         {".golangci.yml", ".golangci.yaml", ".golangci.toml", "staticcheck.conf"},
+	"javascript": {".eslintrc", ".eslintrc.js", ".eslintrc.cjs", ".eslintrc.json", ".eslintrc.yml", "eslint.config.js", "eslint.config.mjs", "biome.json", "tsconfig.json"},
+	"python":     {"ruff.toml", ".ruff.toml", ".flake8", "mypy.ini", ".pylintrc", "setup.cfg"},
+	"java":       {"checkstyle.xml", "config/checkstyle/checkstyle.xml", "spotbugs-exclude.xml", "pmd.xml"},
+}
+
+var ciConfigFiles = []string{
+	".gitlab-ci.yml", ".circleci/config.yml", "Jenkinsfile", "azure-pipelines.yml",
+	".travis.yml", "bitbucket-pipelines.yml", ".buildkite/pipeline.yml",
+}
+
+// DetectRepo inspects the manifests, Makefile and CI configuration at root.
+func DetectRepo(root string) (*RepoContext, error) {
+	if _, err := os.Stat(root); err != nil {
+		return nil, err
+	}
+	c := &RepoContext{Root: root}
+	exists := func(rel string) bool {
+		_, err := os.Stat(filepath.Join(root, rel))
+		return err == nil
+	}
+	read := func(rel string) string {
+		b, _ := os.ReadFile(filepath.Join(root, rel))
+		return string(b)
+	}
+
+	if exists("Makefile") {
+		scanner := bufio.NewScanner(strings.NewReader(read("Makefile")))
+		for scanner.Scan() {
+			if m := makeTarget.FindStringSubmatch(scanner.Text()); m != nil && m[1] != ".PHONY" {
+				c.MakeTargets = append(c.MakeTargets, m[1])
+			}
+		}
+	}
+	makeCmd := func(targets ...string) string {
+		for _, t := range targets {
+			for _, mt := range c.MakeTargets {
+				if mt == t {
+					return "make " + t
+				}
+			}
+		}
+		return ""
+	}
+
+	if exists("go.mod") {
+		c.Languages = append(c.Languages, "go")
+		for _, line := range strings.Split(read("go.mod"), "\n") {
+			if strings.HasPrefix(line, "module ") {
+				c.GoModule = strings.TrimSpace(strings.TrimPrefix(line, "module "))
+				break
+			}
+		}
+		c.TestCommand = firstNonEmpty(makeCmd("test"), "go test ./...")
+		c.BenchCommand = firstNonEmpty(makeCmd("bench", "benchmark"), "go test -run '^$' -bench . -benchmem ./...")
+		c.LintCommand = firstNonEmpty(makeCmd("lint"), "go vet ./...")
+		if exists(".golangci.yml") || exists(".golangci.yaml") || exists(".golangci.toml") {
+			c.LintCommand = firstNonEmpty(makeCmd("lint"), "golangci-lint run ./...")
+		}
+	}
+
+	if exists("package.json") {
+		c.Languages = append(c.Languages, "javascript")
+		var pkg struct {
+			Scripts map[string]string `json:"scripts"`
+		}
+		json.Unmarshal([]byte(read("package.json")), &pkg)
+		switch {
+		case exists("pnpm-lock.yaml"):
+			c.PackageManager = "pnpm"
+		case exists("yarn.lock"):
+			c.PackageManager = "yarn"
+		default:
+			c.PackageManager = "npm"
+		}
+		script := func(names ...string) string {
+			for _, n := range names {
+				if _, ok := pkg.Scripts[n]; ok {
+					if n == "test" {
+						return c.PackageManager + " test"
+					}
+					return c.PackageManager + " run " + n
+				}
+			}
+			return ""
+		}
+		c.TestCommand = firstNonEmpty(c.TestCommand, script("test"))
+		c.BenchCommand = firstNonEmpty(c.BenchCommand, script("bench", "benchmark"))
+		c.LintCommand = firstNonEmpty(c.LintCommand, script("lint"))
+	}
+
+	if exists("pyproject.toml") || exists("setup.py") || exists("requirements.txt") {
+		c.Languages = append(c.Languages, "python")
+		py := read("pyproject.toml") + read("requirements.txt") + read("requirements-dev.txt")
+		switch {
+		case strings.Contains(py, "[tool.poetry"):
+			c.PackageManager = firstNonEmpty(c.PackageManager, "poetry")
+		case exists("uv.lock"):
+			c.PackageManager = firstNonEmpty(c.PackageManager, "uv")
+		default:
+			c.PackageManager = firstNonEmpty(c.PackageManager, "pip")
+		}
+		run := ""
+		if c.PackageManager == "poetry" || c.PackageManager == "uv" {
+			run = c.PackageManager + " run "
+		}
+		if strings.Contains(py, "pytest") {
+			c.TestCommand = firstNonEmpty(c.TestCommand, makeCmd("test"), run+"pytest")
+		} else {
+			c.TestCommand = firstNonEmpty(c.TestCommand, makeCmd("test"), run+"python -m unittest")
+		}
+		if strings.Contains(py, "pytest-benchmark") {
+			c.BenchCommand = firstNonEmpty(c.BenchCommand, run+"pytest --benchmark-only")
+		}
+		switch {
+		case strings.Contains(py, "[tool.ruff") || exists("ruff.toml") || exists(".ruff.toml"):
+			c.LintCommand = firstNonEmpty(c.LintCommand, makeCmd("lint"), run+"ruff check .")
+		case strings.Contains(py, "flake8") || exists(".flake8"):
+			c.LintCommand = firstNonEmpty(c.LintCommand, makeCmd("lint"), run+"flake8")
+		}
+		if strings.Contains(py, "[tool.ruff") {
+			c.LinterConfigs = append(c.LinterConfigs, "pyproject.toml [tool.ruff]")
+		}
+		if strings.Contains(py, "[tool.mypy") {
+			c.LinterConfigs = append(c.LinterConfigs, "pyproject.toml [tool.mypy]")
+		}
+	}
+
+	if exists("pom.xml") || exists("build.gradle") || exists("build.gradle.kts") {
+		c.Languages = append(c.Languages, "java")
+		if exists("pom.xml") {
+			pom := read("pom.xml")
+			c.PackageManager = firstNonEmpty(c.PackageManager, "maven")
+			c.TestCommand = firstNonEmpty(c.TestCommand, "mvn test")
+			if strings.Contains(pom, "jmh") {
+				c.BenchCommand = firstNonEmpty(c.BenchCommand, "mvn -P jmh verify")
+			}
+			switch {
+			case strings.Contains(pom, "spotbugs"):
+				c.LintCommand = firstNonEmpty(c.LintCommand, "mvn spotbugs:check")
+			case strings.Contains(pom, "checkstyle"):
+				c.LintCommand = firstNonEmpty(c.LintCommand, "mvn checkstyle:check")
+			}
+		} else {
+			gradle := "gradle"
+			if exists("gradlew") {
+				gradle = "./gradlew"
+			}
+			c.PackageManager = firstNonEmpty(c.PackageManager, "gradle")
+			c.TestCommand = firstNonEmpty(c.TestCommand, gradle+" test")
+			if strings.Contains(read("build.gradle")+read("build.gradle.kts"), "jmh") {
+				c.BenchCommand = firstNonEmpty(c.BenchCommand, gradle+" jmh")
+			}
+		}
+	}
+
+	for _, lang := range c.Languages {
+		for _, f := range linterConfigFiles[lang] {
+			if exists(f) {
+				c.LinterConfigs = append(c.LinterConfigs, f)
+			}
+		}
+	}
+
+	workflows, _ := filepath.Glob(filepath.Join(root, ".github", "workflows", "*.y*ml"))
+	for _, w := range workflows {
+		rel, _ := filepath.Rel(root, w)
+		c.CIConfigs = append(c.CIConfigs, filepath.ToSlash(rel))
+	}
+	for _, f := range ciConfigFiles {
+		if exists(f) {
+			c.CIConfigs = append(c.CIConfigs, f)
+		}
+	}
+	sort.Strings(c.MakeTargets)
+	return c, nil
+}
+
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,573 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+	"unicode"
+)
+
+// GenerateReproTestInput is the input for the generate_repro_test tool.
+type GenerateReproTestInput struct {
+	RegressionType string `json:"regression_type" jsonschema_description:"Type of regression from detect_regression" jsonschema_enum:"regression_type"`
+	StackFrame     string `json:"stack_frame,omitempty" jsonschema_description:"The failing stack frame or whole stack trace; the first frame in the repository is used"`
+	SourceFile     string `json:"source_file,omitempty" jsonschema_description:"Repository-relative path of the source file under test (optional if stack_frame names it)"`
+	Function       string `json:"function,omitempty" jsonschema_description:"Name of the failing function (optional if stack_frame names it)"`
+	Description    string `json:"description,omitempty" jsonschema_description:"Short description of the failure, used in the test's doc comment"`
+}
+
+// GenerateReproTestOutput is a failing test skeleton for the reproduce step.
+type GenerateReproTestOutput struct {
+	Language     string `json:"language"`
+	SourceFile   string `json:"source_file"`
+	Function     string `json:"function"`
+	TestPath     string `json:"test_path"`
+	Content      string `json:"content"`
+	Written      bool   `json:"written"`
+	Verified     bool   `json:"verified"`
+	Verification string `json:"verification"`
+}
+
+var (
+	goFuncLine   = regexp.MustCompile(`(?m)^([\w./*()-]+)\(.*\)\s*$`)
+	javaFrame    = regexp.MustCompile(`at ([\w.$]+)\.([\w$<>]+)\(([\w]+\.(?:java|kt)):(\d+)\)`)
+	pyFrameFunc  = regexp.MustCompile(`File "([^"]+)", line (\d+), in (\w+)`)
+	javaPackage  = regexp.MustCompile(`(?m)^\s*package\s+([\w.]+)\s*;`)
+	identCleaner = regexp.MustCompile(`[^A-Za-z0-9_]`)
+)
+
+var (
+	writeReproMu    sync.RWMutex
+	writeReproTests bool
+)
+
+// SetWriteReproTests lets generate_repro_test write its tests into the
+// configured repository. It is off by default, so analyses (including
+// serve and batch) leave the checkout untouched and only return the test.
+func SetWriteReproTests(on bool) {
+	writeReproMu.Lock()
+	defer writeReproMu.Unlock()
+	writeReproTests = on
+}
+
+func reproTestsWritable() bool {
+	writeReproMu.RLock()
+	defer writeReproMu.RUnlock()
+	return writeReproTests
+}
+
+// GenerateReproTest produces a language-appropriate failing test skeleton for
+// the failing frame. When writing is enabled (SetWriteReproTests) and a
+// repository root is configured, it is created at the conventional test path
+// (never overwriting an existing file) and checked to compile with the local
+// toolchain; a test the toolchain rejects is removed again. Otherwise it is
+// only checked to parse.
+func GenerateReproTest(ctx context.Context, inputJSON string) (string, error) {
+	var input GenerateReproTestInput
+	if err := decodeInput(inputJSON, &input); err != nil {
+		return "", err
+	}
+	root := RepoRoot()
+
+	file, line, fn := parseFrame(root, input.StackFrame)
+	if f := cleanRel(input.SourceFile); f != "" {
+		file = f
+	}
+	if input.Function != "" {
+		fn = input.Function
+	}
+	if file == "" {
+		return "", fmt.Errorf("no source file: give source_file or a stack_frame naming a file")
+	}
+
+	out := GenerateReproTestOutput{SourceFile: file, Function: fn}
+	var source []byte
+	if root != "" {
+		source, _, _ = readRepoFile(root, file)
+	}
+
+	data := reproData{
+		RegressionType: orUnknown(input.RegressionType),
+		Location:       file,
+		Function:       fn,
+		Description:    input.Description,
+	}
+	if line > 0 {
+		data.Location += ":" + strconv.Itoa(line)
+	}
+
+	var tmpl *template.Template
+	switch ext := path.Ext(file); ext {
+	case ".go":
+		out.Language = "go"
+		data.Package = goPackageName(source, file)
+		data.TestName = "Test" + exportName(fn) + "Regression"
+		out.TestPath = strings.TrimSuffix(file, ".go") + "_regression_test.go"
+		tmpl = goReproTemplate
+	case ".py":
+		out.Language = "python"
+		module := strings.TrimSuffix(file, ".py")
+		data.Module = strings.ReplaceAll(module, "/", ".")
+		data.TestName = "test_" + snakeName(fn) + "_regression"
+		base := "test_" + path.Base(module) + "_regression.py"
+		if root != "" && dirExists(root, "tests") {
+			out.TestPath = "tests/" + base
+		} else {
+			out.TestPath = path.Join(path.Dir(file), base)
+		}
+		tmpl = pythonReproTemplate
+	case ".java", ".kt":
+		out.Language = "java"
+		data.Class = javaIdent(strings.TrimSuffix(path.Base(file), ext), "_")
+		if m := javaPackage.FindSubmatch(source); m != nil {
+			data.Package = string(m[1])
+		}
+		data.TestName = lowerFirst(javaIdent(orDefault(fn, "call"), "test")) + "Regression"
+		testFile := path.Join(path.Dir(file), data.Class+"RegressionTest.java")
+		out.TestPath = strings.Replace(testFile, "src/main/", "src/test/", 1)
+		tmpl = junitReproTemplate
+	default:
+		return "", fmt.Errorf("unsupported source language %q (supported: .go, .py, .java)", ext)
+	}
+	if out.Function == "" {
+		out.Function = fn
+	}
+	data.Function = orDefault(fn, "the failing function")
+
+	// A second reproduction for the same file gets its own file and name
+	// so the package still compiles. The file is created exclusively, so
+	// concurrent analyses never clobber each other's tests.
+	ext := testExt(out.TestPath)
+	base, testName := strings.TrimSuffix(out.TestPath, ext), data.TestName
+	write := root != "" && reproTestsWritable()
+	for i := 1; ; i++ {
+		if i > 1 {
+			data.Suffix = strconv.Itoa(i)
+			data.TestName = testName + data.Suffix
+			out.TestPath = base + data.Suffix + ext
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return "", fmt.Errorf("render test: %w", err)
+		}
+		out.Content = buf.String()
+		if !write {
+			break
+		}
+		err := createRepoFile(root, out.TestPath, buf.Bytes())
+		if errors.Is(err, fs.ErrExist) && i < maxReproTests {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("write test: %w", err)
+		}
+		out.Written = true
+		break
+	}
+
+	var rejected bool
+	out.Verified, rejected, out.Verification = verifyReproTest(ctx, root, out)
+	if out.Written && (rejected || ctx.Err() != nil) {
+		// Don't leave a test that breaks the build (or was never checked) behind.
+		if abs, _, err := resolveRepoPath(root, out.TestPath); err == nil && os.Remove(abs) == nil {
+			out.Written = false
+			out.Verification += "; the test file was removed"
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	result, err := json.Marshal(out)
+	return string(result), err
+}
+
+// parseFrame extracts the first file, line and function from a Go, Java or
+// Python stack trace (or a bare "file:line" frame).
+func parseFrame(root, trace string) (file string, line int, fn string) {
+	if m := pyFrameFunc.FindStringSubmatch(trace); m != nil {
+		line, _ = strconv.Atoi(m[2])
+		return resolveFrameFile(root, m[1]), line, m[3]
+	}
+	if m := javaFrame.FindStringSubmatch(trace); m != nil {
+		line, _ = strconv.Atoi(m[4])
+		return resolveFrameFile(root, m[3]), line, m[2]
+	}
+	loc := frameRef.FindStringSubmatchIndex(trace)
+	if loc == nil {
+		return "", 0, ""
+	}
+	file = resolveFrameFile(root, trace[loc[2]:loc[3]])
+	line, _ = strconv.Atoi(trace[loc[4]:loc[5]])
+	// Go traces put the function on the line before the file:line.
+	before := strings.TrimRight(trace[:loc[0]], " \t\n")
+	if i := strings.LastIndex(before, "\n"); i >= 0 {
+		before = before[i+1:]
+	}
+	if m := goFuncLine.FindStringSubmatch(strings.TrimSpace(before)); m != nil {
+		fn = m[1][strings.LastIndex(m[1], ".")+1:]
+	} else if i := strings.Index(trace[loc[1]:], " in "); i >= 0 {
+		// "file.go:42 in Func"
+		fields := strings.Fields(trace[loc[1]+i+4:])
+		if len(fields) > 0 {
+			fn = fields[0]
+		}
+	}
+	return file, line, fn
+}
+
+// resolveFrameFile maps a frame's file onto the repository when possible.
+// Java frames carry only a base name, so the repository is searched for it.
+func resolveFrameFile(root, f string) string {
+	if root == "" {
+		return cleanRel(strings.TrimLeft(filepath.ToSlash(f), "/"))
+	}
+	if rel := repoRelative(root, f); rel != "" {
+		return rel
+	}
+	if strings.Contains(f, "/") {
+		return ""
+	}
+	var found string
+	filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || found != "" {
+			return filepath.SkipDir
+		}
+		rel, _ := filepath.Rel(root, p)
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() && (d.Name() == "node_modules" || d.Name() == "vendor" || rel != "." && denied(rel)) {
+			return filepath.SkipDir
+		}
+		if !d.IsDir() && d.Name() == f && fileExists(root, rel) {
+			found = rel
+		}
+		return nil
+	})
+	return found
+}
+
+func testExt(p string) string {
+	if strings.HasSuffix(p, "_test.go") {
+		return "_test.go"
+	}
+	return path.Ext(p)
+}
+
+func goPackageName(source []byte, file string) string {
+	if len(source) > 0 {
+		if f, err := parser.ParseFile(token.NewFileSet(), file, source, parser.PackageClauseOnly); err == nil {
+			return f.Name.Name
+		}
+	}
+	dir := path.Base(path.Dir(file))
+	if dir == "." || dir == "/" {
+		return "main"
+	}
+	return identCleaner.ReplaceAllString(dir, "")
+}
+
+// maxReproTests bounds the numbered test files tried for one source file.
+const maxReproTests = 100
+
+// verifyReproTest checks the test compiles (Go, Java) or parses (Python)
+// with whatever toolchain is available. rejected is set when a check ran
+// and failed, as opposed to no toolchain being available.
+func verifyReproTest(ctx context.Context, root string, out GenerateReproTestOutput) (ok, rejected bool, msg string) {
+	run := func(dir, stdin, name string, args ...string) (bool, string) {
+		if _, err := exec.LookPath(name); err != nil {
+			return false, ""
+		}
+		cmd := exec.CommandContext(ctx, name, args...)
+		cmd.WaitDelay = time.Second // compilers fork; don't wait on them once killed
+		cmd.Dir = dir
+		cmd.Stdin = strings.NewReader(stdin)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			return false, fmt.Sprintf("`%s %s` failed: %s", name, strings.Join(args, " "), strings.TrimSpace(string(output)))
+		}
+		return true, fmt.Sprintf("`%s %s` succeeded", name, strings.Join(args, " "))
+	}
+
+	switch out.Language {
+	case "go":
+		if _, err := parser.ParseFile(token.NewFileSet(), out.TestPath, out.Content, parser.AllErrors); err != nil {
+			return false, true, "generated test does not parse: " + err.Error()
+		}
+		if out.Written {
+			if ok, msg := run(root, "", "go", "test", "-c", "-o", os.DevNull, "./"+path.Dir(out.TestPath)); msg != "" {
+				return ok, !ok, msg
+			}
+		}
+		return true, false, "parsed with go/parser (Go toolchain not used)"
+	case "python":
+		if out.Written {
+			if ok, msg := run(root, "", "python3", "-m", "py_compile", out.TestPath); msg != "" {
+				return ok, !ok, msg
+			}
+		} else if ok, msg := run("", out.Content, "python3", "-c", "import ast, sys; ast.parse(sys.stdin.read())"); msg != "" {
+			return ok, !ok, msg
+		}
+		return false, false, "not verified: python3 not available"
+	case "java":
+		if !out.Written {
+			return false, false, "not verified: test not written to a repository to compile against"
+		}
+		switch {
+		case fileExists(root, "pom.xml"):
+			if ok, msg := run(root, "", "mvn", "-q", "-o", "test-compile"); msg != "" {
+				return ok, !ok, msg
+			}
+		case fileExists(root, "gradlew"):
+			ok, msg := run(root, "", "./gradlew", "-q", "--offline", "compileTestJava")
+			return ok, !ok && msg != "", msg
+		}
+		return false, false, "not verified: Java build tool not available (run your build's test-compile step)"
+	}
+	return false, false, "not verified"
+}
+
+type reproData struct {
+	RegressionType string
+	Location       string
+	Function       string
+	Description    string
+	Package        string
+	Module         string
+	Class          string
+	TestName       string
+	// Suffix numbers the test file when earlier reproductions exist.
+	Suffix string
+}
+
+// reproFuncs are the template helpers. Model-supplied text (description,
+// function) only reaches a test through comment, pydoc or quote, so a quote
+// or newline in it cannot break the generated file.
+var reproFuncs = template.FuncMap{
+	"is": func(a string, bs ...string) bool {
+		for _, b := range bs {
+			if a == b {
+				return true
+			}
+		}
+		return false
+	},
+	// comment folds s onto one line that cannot close a block comment.
+	"comment": commentText,
+	// pydoc makes s safe inside a Python triple-quoted docstring.
+	"pydoc": func(s string) string {
+		return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(commentText(s))
+	},
+	// quote renders s as a double-quoted string literal valid in Go, Python
+	// and Java.
+	"quote": func(s string) string { return strconv.Quote(commentText(s)) },
+}
+
+func commentText(s string) string {
+	return strings.ReplaceAll(strings.Join(strings.Fields(s), " "), "*/", "* /")
+}
+
+var goReproTemplate = template.Must(template.New("go").Funcs(reproFuncs).Parse(`package {{.Package}}
+
+import (
+{{- if is .RegressionType "memory_leak"}}
+	"runtime"
+{{- end}}
+	"testing"
+{{- if is .RegressionType "performance"}}
+	"time"
+{{- end}}
+)
+
+// {{.TestName}} reproduces the {{.RegressionType}} regression reported at
+// {{comment .Location}}.{{if .Description}}
+// {{comment .Description}}{{end}}
+//
+// It fails until the reproduction is filled in and the regression is fixed.
+func {{.TestName}}(t *testing.T) {
+	tests := []struct {
+		name string
+		// TODO: add the inputs that trigger the failure.
+	}{
+		{name: "reported failure"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+{{- if is .RegressionType "null_pointer" "crash"}}
+			defer func() {
+				if r := recover(); r != nil {
+					t.Fatalf("%s panicked: %v", {{quote .Function}}, r)
+				}
+			}()
+{{- end}}
+{{- if is .RegressionType "performance"}}
+			const budget = 100 * time.Millisecond // TODO: set from the pre-regression baseline.
+			start := time.Now()
+			// TODO: call {{comment .Function}} with tt's inputs.
+			if elapsed := time.Since(start); elapsed > budget {
+				t.Fatalf("%s took %v, budget %v", {{quote .Function}}, elapsed, budget)
+			}
+{{- else if is .RegressionType "memory_leak"}}
+			var before, after runtime.MemStats
+			runtime.GC()
+			runtime.ReadMemStats(&before)
+			for i := 0; i < 1000; i++ {
+				// TODO: call {{comment .Function}} with tt's inputs.
+			}
+			runtime.GC()
+			runtime.ReadMemStats(&after)
+			if growth := int64(after.HeapInuse) - int64(before.HeapInuse); growth > 1<<20 {
+				t.Fatalf("heap grew by %d bytes over 1000 calls to %s", growth, {{quote .Function}})
+			}
+{{- else}}
+			// TODO: call {{comment .Function}} with tt's inputs and compare against the expected result.
+{{- end}}
+			t.Fatal({{quote (print "reproduction for " .Location " not implemented yet")}})
+		})
+	}
+}
+`))
+
+var pythonReproTemplate = template.Must(template.New("python").Funcs(reproFuncs).Parse(`"""Reproduces the {{.RegressionType}} regression reported at {{pydoc .Location}}.
+{{- if .Description}}
+
+{{pydoc .Description}}
+{{- end}}
+
+Fails until the reproduction is filled in and the regression is fixed.
+"""
+import pytest
+{{- if is .RegressionType "performance"}}
+import time
+{{- end}}
+{{- if is .RegressionType "memory_leak"}}
+import tracemalloc
+{{- end}}
+
+# TODO: from {{comment .Module}} import {{comment .Function}}
+
+
+@pytest.mark.parametrize("case", [
+    pytest.param({}, id="reported-failure"),  # TODO: inputs that trigger the failure
+])
+def {{.TestName}}(case):
+{{- if is .RegressionType "performance"}}
+    budget = 0.1  # seconds; TODO: set from the pre-regression baseline
+    start = time.perf_counter()
+    # TODO: call {{comment .Function}}(**case)
+    assert time.perf_counter() - start < budget
+{{- else if is .RegressionType "memory_leak"}}
+    tracemalloc.start()
+    before = tracemalloc.take_snapshot()
+    for _ in range(1000):
+        pass  # TODO: call {{comment .Function}}(**case)
+    growth = sum(s.size_diff for s in tracemalloc.take_snapshot().compare_to(before, "lineno"))
+    tracemalloc.stop()
+    assert growth < 1 << 20
+{{- else}}
+    # TODO: call {{comment .Function}}(**case) and assert the expected result
+{{- end}}
+    pytest.fail({{quote (print "reproduction for " .Location " not implemented yet")}})
+`))
+
+var junitReproTemplate = template.Must(template.New("java").Funcs(reproFuncs).Parse(`{{if .Package}}package {{.Package}};
+
+{{end}}{{if is .RegressionType "performance"}}import static org.junit.jupiter.api.Assertions.assertTimeout;
+{{end}}import static org.junit.jupiter.api.Assertions.fail;
+
+{{if is .RegressionType "performance"}}import java.time.Duration;
+
+{{end}}import org.junit.jupiter.api.Test;
+
+/**
+ * Reproduces the {{.RegressionType}} regression reported at {{comment .Location}}.
+{{- if .Description}}
+ * {{comment .Description}}
+{{- end}}
+ * Fails until the reproduction is filled in and the regression is fixed.
+ */
+class {{.Class}}RegressionTest{{.Suffix}} {
+
+    @Test
+    void {{.TestName}}() {
+{{- if is .RegressionType "performance"}}
+        // TODO: set the budget from the pre-regression baseline.
+        assertTimeout(Duration.ofMillis(100), () -> {
+            // TODO: call {{.Class}}.{{comment .Function}} with the failing inputs.
+        });
+{{- else}}
+        // TODO: call {{.Class}}.{{comment .Function}} with the failing inputs and assert the expected result.
+{{- end}}
+        fail({{quote (print "reproduction for " .Location " not implemented yet")}});
+    }
+}
+`))
+
+func exportName(fn string) string {
+	fn = identCleaner.ReplaceAllString(fn, "")
+	if fn == "" {
+		return "Reported"
+	}
+	r := []rune(fn)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+// javaIdent strips s to the characters of a Java identifier and adds prefix
+// when what is left does not start with a letter.
+func javaIdent(s, prefix string) string {
+	s = identCleaner.ReplaceAllString(s, "")
+	if s == "" || !unicode.IsLetter(rune(s[0])) {
+		return prefix + s
+	}
+	return s
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	r := []rune(s)
+	r[0] = unicode.ToLower(r[0])
+	return string(r)
+}
+
+func snakeName(fn string) string {
+	fn = identCleaner.ReplaceAllString(fn, "")
+	if fn == "" {
+		return "reported"
+	}
+	var b strings.Builder
+	for i, r := range fn {
+		if unicode.IsUpper(r) && i > 0 {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+func orUnknown(s string) string {
+	return orDefault(s, string(RegressionTypeUnknown))
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
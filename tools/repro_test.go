@@ -0,0 +1,29 @@
+package tools_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/emyjamalian/laas-ladybug/tools"
+)
+
+func TestReproTestJavaIdentifiers(t *testing.T) {
+	out, err := tools.GenerateReproTest(context.Background(), `{"regression_type": "crash", "source_file": "src/main/java/com/acme/3d-renderer.java", "function": "2ndPass"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var repro tools.GenerateReproTestOutput
+	if err := json.Unmarshal([]byte(out), &repro); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"class _3drendererRegressionTest {", "void test2ndPassRegression()"} {
+		if !strings.Contains(repro.Content, want) {
+			t.Errorf("test does not contain %q:\n%s", want, repro.Content)
+		}
+	}
+	if repro.TestPath != "src/test/java/com/acme/_3drendererRegressionTest.java" {
+		t.Errorf("test path = %s, want it named after the class", repro.TestPath)
+	}
+}
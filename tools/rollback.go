@@ -0,0 +1,272 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// PlanRollbackInput is the input for the plan_rollback tool.
+type PlanRollbackInput struct {
+	Culprit   string `json:"culprit" jsonschema_description:"Commit SHA, ref or release tag that introduced the regression"`
+	Component string `json:"component,omitempty" jsonschema_description:"Attributed component from attribute_to_owner; selects deploy targets"`
+}
+
+// RevertCommit is one commit the rollback reverts.
+type RevertCommit struct {
+	SHA     string `json:"sha"`
+	Subject string `json:"subject"`
+	Merge   bool   `json:"merge"`
+}
+
+// RollbackStep is one concrete command of a rollback plan. Args is the
+// argv to execute; Command is the same for display.
+type RollbackStep struct {
+	Order       int      `json:"order"`
+	Kind        string   `json:"kind"` // flag, kubernetes, helm or git
+	Description string   `json:"description"`
+	Command     string   `json:"command"`
+	Args        []string `json:"args"`
+}
+
+// RollbackPlan lists the commands that undo a bad change. It is never
+// executed by the tool itself.
+type RollbackPlan struct {
+	Culprit         string         `json:"culprit"`
+	CulpritCommit   string         `json:"culprit_commit,omitempty"`
+	Release         bool           `json:"release"`
+	PreviousGoodTag string         `json:"previous_good_tag,omitempty"`
+	Reverts         []RevertCommit `json:"reverts,omitempty"`
+	Steps           []RollbackStep `json:"steps"`
+	Notes           []string       `json:"notes,omitempty"`
+}
+
+// DeployTarget says how a component is deployed and can be rolled back.
+type DeployTarget struct {
+	Kubernetes   *KubernetesDeployment `json:"kubernetes,omitempty"`
+	Helm         *HelmRelease          `json:"helm,omitempty"`
+	FeatureFlags []string              `json:"feature_flags,omitempty"`
+	// FlagCommand turns a flag off; "{flag}" is replaced with the flag key.
+	FlagCommand string `json:"flag_command,omitempty"`
+}
+
+// KubernetesDeployment identifies a Deployment for kubectl rollout undo.
+type KubernetesDeployment struct {
+	Deployment string `json:"deployment"`
+	Namespace  string `json:"namespace,omitempty"`
+	Context    string `json:"context,omitempty"`
+}
+
+// HelmRelease identifies a Helm release. Revision 0 rolls back to the
+// previous revision.
+type HelmRelease struct {
+	Release   string `json:"release"`
+	Namespace string `json:"namespace,omitempty"`
+	Revision  int    `json:"revision,omitempty"`
+}
+
+// DeployConfig maps components to deploy targets.
+type DeployConfig struct {
+	Default    DeployTarget            `json:"default"`
+	Components map[string]DeployTarget `json:"components"`
+}
+
+// LoadDeployConfig reads a deploy-manifest config from a JSON file.
+func LoadDeployConfig(path string) (*DeployConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read deploy config: %w", err)
+	}
+	var c DeployConfig
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("parse deploy config %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// For returns the deploy target for component, falling back to the default.
+func (c *DeployConfig) For(component string) DeployTarget {
+	if t, ok := c.Components[component]; ok {
+		return t
+	}
+	return c.Default
+}
+
+var (
+	deployMu     sync.RWMutex
+	deployConfig *DeployConfig
+)
+
+// SetDeployConfig sets the deploy targets rollback plans use; nil limits
+// plans to git reverts.
+func SetDeployConfig(c *DeployConfig) {
+	deployMu.Lock()
+	defer deployMu.Unlock()
+	deployConfig = c
+}
+
+// CurrentDeployConfig returns the configured deploy targets, or nil.
+func CurrentDeployConfig() *DeployConfig {
+	deployMu.RLock()
+	defer deployMu.RUnlock()
+	return deployConfig
+}
+
+// PlanRollback produces concrete rollback commands for the culprit: feature
+// flag toggles and deploy rollbacks from the deploy config, then a git revert
+// sequence against the repository root. It never runs them.
+func PlanRollback(ctx context.Context, inputJSON string) (string, error) {
+	var input PlanRollbackInput
+	if err := decodeInput(inputJSON, &input); err != nil {
+		return "", err
+	}
+	plan, err := BuildRollbackPlan(ctx, RepoRoot(), input)
+	if err != nil {
+		return "", err
+	}
+	result, err := json.Marshal(plan)
+	return string(result), err
+}
+
+// BuildRollbackPlan is PlanRollback for callers that want the plan itself.
+func BuildRollbackPlan(ctx context.Context, root string, input PlanRollbackInput) (*RollbackPlan, error) {
+	input.Culprit = strings.TrimSpace(input.Culprit)
+	plan := &RollbackPlan{Culprit: input.Culprit}
+	add := func(kind, desc string, args ...string) {
+		plan.Steps = append(plan.Steps, RollbackStep{
+			Order:       len(plan.Steps) + 1,
+			Kind:        kind,
+			Description: desc,
+			Command:     shellJoin(args),
+			Args:        args,
+		})
+	}
+
+	// Fastest mitigations first: flags, then deploys, then the code revert.
+	if c := CurrentDeployConfig(); c != nil {
+		t := c.For(input.Component)
+		if t.FlagCommand != "" {
+			for _, flag := range t.FeatureFlags {
+				add("flag", "Turn off feature flag "+flag,
+					strings.Fields(strings.ReplaceAll(t.FlagCommand, "{flag}", flag))...)
+			}
+		} else if len(t.FeatureFlags) > 0 {
+			plan.Notes = append(plan.Notes, "Feature flags "+strings.Join(t.FeatureFlags, ", ")+
+				" are configured without a flag_command; turn them off in your flag provider")
+		}
+		if k := t.Kubernetes; k != nil && k.Deployment != "" {
+			args := []string{"kubectl"}
+			if k.Context != "" {
+				args = append(args, "--context", k.Context)
+			}
+			if k.Namespace != "" {
+				args = append(args, "-n", k.Namespace)
+			}
+			args = append(args, "rollout", "undo", "deployment/"+k.Deployment)
+			add("kubernetes", "Roll deployment "+k.Deployment+" back to its previous ReplicaSet", args...)
+		}
+		if h := t.Helm; h != nil && h.Release != "" {
+			args := []string{"helm", "rollback", h.Release}
+			desc := "Roll Helm release " + h.Release + " back to its previous revision"
+			if h.Revision > 0 {
+				args = append(args, strconv.Itoa(h.Revision))
+				desc = fmt.Sprintf("Roll Helm release %s back to revision %d", h.Release, h.Revision)
+			}
+			if h.Namespace != "" {
+				args = append(args, "-n", h.Namespace)
+			}
+			add("helm", desc, args...)
+		}
+	}
+
+	if root == "" {
+		plan.Notes = append(plan.Notes, "No repository configured (LADYBUG_REPO); git revert steps were not planned")
+		return plan, nil
+	}
+	if input.Culprit == "" {
+		return nil, fmt.Errorf("culprit commit or tag is required")
+	}
+	sha, err := git(ctx, root, "rev-parse", "--verify", "--quiet", input.Culprit+"^{commit}")
+	if err != nil {
+		return nil, fmt.Errorf("unknown commit or tag %q", input.Culprit)
+	}
+	plan.CulpritCommit = sha
+	_, tagErr := git(ctx, root, "rev-parse", "--verify", "--quiet", "refs/tags/"+input.Culprit)
+	plan.Release = tagErr == nil
+	if prev, err := git(ctx, root, "describe", "--tags", "--abbrev=0", sha+"^"); err == nil {
+		plan.PreviousGoodTag = prev
+	} else {
+		plan.Notes = append(plan.Notes, "No tag before the culprit; there is no previous good release to fall back to")
+	}
+
+	// A release reverts everything since the previous tag; following first
+	// parents keeps merged branches as single merge commits.
+	shas := []string{sha}
+	if plan.Release && plan.PreviousGoodTag != "" {
+		out, err := git(ctx, root, "rev-list", "--first-parent", plan.PreviousGoodTag+".."+sha)
+		if err != nil {
+			return nil, err
+		}
+		shas = strings.Fields(out)
+	}
+	for _, s := range shas {
+		c := RevertCommit{SHA: s}
+		c.Subject, _ = git(ctx, root, "log", "-1", "--format=%s", s)
+		if parents, err := git(ctx, root, "rev-list", "--parents", "-n", "1", s); err == nil {
+			c.Merge = len(strings.Fields(parents)) > 2
+		}
+		plan.Reverts = append(plan.Reverts, c)
+	}
+	if _, err := git(ctx, root, "merge-base", "--is-ancestor", sha, "HEAD"); err != nil {
+		plan.Notes = append(plan.Notes, "The culprit is not on the checked-out branch; check out the deployed branch before reverting")
+	}
+
+	add("git", "Create a branch for the revert", "git", "switch", "-c", "rollback/"+shortSHA(sha))
+	// Revert newest first so each revert applies to the tree it expects.
+	for _, c := range plan.Reverts {
+		if c.Merge {
+			add("git", "Revert merge "+shortSHA(c.SHA)+" ("+c.Subject+") against its mainline", "git", "revert", "--no-edit", "-m", "1", c.SHA)
+		} else {
+			add("git", "Revert "+shortSHA(c.SHA)+" ("+c.Subject+")", "git", "revert", "--no-edit", c.SHA)
+		}
+	}
+	plan.Notes = append(plan.Notes, "Push rollback/"+shortSHA(sha)+" and open a pull request; the revert ships through the normal pipeline")
+	return plan, nil
+}
+
+func git(ctx context.Context, root string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = root
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(firstNonEmpty(stderr.String(), err.Error())))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 12 {
+		return sha[:12]
+	}
+	return sha
+}
+
+// shellJoin renders argv for display, quoting arguments that need it.
+func shellJoin(args []string) string {
+	out := make([]string, len(args))
+	for i, a := range args {
+		if a == "" || strings.ContainsAny(a, " \t\n'\"\\$`!*?;&|<>(){}[]#~") {
+			a = "'" + strings.ReplaceAll(a, "'", `'\''`) + "'"
+		}
+		out[i] = a
+	}
+	return strings.Join(out, " ")
+}
@@ -0,0 +1,136 @@
+package tools
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema derives the JSON Schema of a tool input struct, so the Go type is
+// the single source of truth for what the model may send:
+//
+//   - property names come from the json tags; "-" and unexported fields are skipped
+//   - a field without omitempty is required
+//   - jsonschema_description tags become descriptions
+//   - jsonschema_enum names a value set (see enumSets); on a slice it
+//     constrains the items
+//   - jsonschema_minimum and jsonschema_format add "minimum" and "format"
+//   - unknown properties are not allowed
+//
+// v is a struct value or pointer to one.
+func Schema(v interface{}) map[string]interface{} {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return typeSchema(t)
+}
+
+// enumSets resolve jsonschema_enum tags. They are evaluated when a schema is
+// built because environments come from the configurable CPD model.
+var enumSets = map[string]func() []string{
+	"regression_type": func() []string {
+		return []string{
+			string(RegressionTypeNullPointer), string(RegressionTypePerformance), string(RegressionTypeCrash),
+			string(RegressionTypeMemoryLeak), string(RegressionTypeLogicError), string(RegressionTypeDataCorrupt),
+			string(RegressionTypeAPIBreaking), string(RegressionTypeSecurityFlaw), string(RegressionTypeUnknown),
+		}
+	},
+	"severity": func() []string {
+		return []string{string(SeverityCritical), string(SeverityHigh), string(SeverityMedium), string(SeverityLow)}
+	},
+	"priority": func() []string {
+		return []string{string(PriorityP0), string(PriorityP1), string(PriorityP2), string(PriorityP3)}
+	},
+	"environment": func() []string { return CurrentCPDModel().EnvironmentNames() },
+	"run_result":  func() []string { return []string{"pass", "fail"} },
+}
+
+// EnumValues returns the allowed values of a named enum set, or nil.
+func EnumValues(name string) []string {
+	if f, ok := enumSets[name]; ok {
+		return f()
+	}
+	return nil
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func typeSchema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case t.Kind() == reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem())}
+	case t.Kind() == reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case t.Kind() == reflect.Struct:
+		props := map[string]interface{}{}
+		required := []string{}
+		addFields(t, props, &required)
+		return map[string]interface{}{"type": "object", "properties": props, "required": required, "additionalProperties": false}
+	}
+	return map[string]interface{}{}
+}
+
+// addFields adds t's fields to props, flattening embedded structs the way
+// encoding/json does.
+func addFields(t reflect.Type, props map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			for ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				addFields(ft, props, required)
+				continue
+			}
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		s := typeSchema(f.Type)
+		if d := f.Tag.Get("jsonschema_description"); d != "" {
+			s["description"] = d
+		}
+		if e := EnumValues(f.Tag.Get("jsonschema_enum")); e != nil {
+			if items, ok := s["items"].(map[string]interface{}); ok {
+				items["enum"] = e
+			} else {
+				s["enum"] = e
+			}
+		}
+		if m := f.Tag.Get("jsonschema_minimum"); m != "" {
+			if n, err := strconv.ParseFloat(m, 64); err == nil {
+				s["minimum"] = n
+			}
+		}
+		if format := f.Tag.Get("jsonschema_format"); format != "" {
+			s["format"] = format
+		}
+		props[name] = s
+		if !strings.Contains(opts, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package tools_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/emyjamalian/laas-ladybug/agent"
+	"github.com/emyjamalian/laas-ladybug/tools"
+)
+
+// inputs maps every built-in tool to the input struct its handler decodes.
+var inputs = map[string]interface{}{
+	"detect_regression":   tools.DetectRegressionInput{},
+	"triage_issue":        tools.TriageIssueInput{},
+	"attribute_to_owner":  tools.AttributeIssueInput{},
+	"generate_fix_plan":   tools.GenerateFixPlanInput{},
+	"generate_repro_test": tools.GenerateReproTestInput{},
+	"propose_patch":       tools.ProposePatchInput{},
+	"read_file":           tools.ReadFileInput{},
+	"search_code":         tools.SearchCodeInput{},
+	"list_dir":            tools.ListDirInput{},
+	"git_log":             tools.GitLogInput{},
+	"plan_rollback":       tools.PlanRollbackInput{},
+}
+
+// registeredTools returns the tools an agent sends the model with a
+// repository configured, which enables every built-in tool.
+func registeredTools(t *testing.T) []agent.Tool {
+	t.Helper()
+	t.Setenv("LADYBUG_REPLAY", "")
+	t.Setenv("LADYBUG_RECORD", "")
+	tools.SetRepoRoot(t.TempDir())
+	t.Cleanup(func() { tools.SetRepoRoot("") })
+	return agent.New().Tools().Tools()
+}
+
+func TestToolSchemasMatchInputStructs(t *testing.T) {
+	seen := map[string]bool{}
+	for _, tool := range registeredTools(t) {
+		seen[tool.Name()] = true
+		in, ok := inputs[tool.Name()]
+		if !ok {
+			t.Errorf("%s: no input struct listed in this test", tool.Name())
+			continue
+		}
+		got, err := json.Marshal(tool.Schema())
+		if err != nil {
+			t.Fatalf("%s: %v", tool.Name(), err)
+		}
+		want, err := json.Marshal(tools.Schema(in))
+		if err != nil {
+			t.Fatalf("%s: %v", tool.Name(), err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("%s: the model is sent\n%s\nbut %T generates\n%s", tool.Name(), got, in, want)
+		}
+	}
+	for name := range inputs {
+		if !seen[name] {
+			t.Errorf("%s is not registered", name)
+		}
+	}
+}
+
+// tagRequired returns the JSON names of t's fields without omitempty,
+// flattening embedded structs the way encoding/json does.
+func tagRequired(t reflect.Type) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			names = append(names, tagRequired(f.Type)...)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if !strings.Contains(opts, "omitempty") {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func schemaRequired(t *testing.T, schema map[string]interface{}) []string {
+	t.Helper()
+	var names []string
+	switch req := schema["required"].(type) {
+	case []string:
+		names = append(names, req...)
+	case []interface{}:
+		for _, r := range req {
+			names = append(names, r.(string))
+		}
+	case nil:
+	default:
+		t.Fatalf("required is %T", req)
+	}
+	return names
+}
+
+func TestRequiredFieldsMatchOmitempty(t *testing.T) {
+	for _, tool := range registeredTools(t) {
+		in, ok := inputs[tool.Name()]
+		if !ok {
+			continue
+		}
+		got := schemaRequired(t, tool.Schema())
+		want := tagRequired(reflect.TypeOf(in))
+		sort.Strings(got)
+		sort.Strings(want)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: schema requires %v, but the fields of %T without omitempty are %v", tool.Name(), got, in, want)
+		}
+	}
+}
+
+// TestRequiredFields pins the required sets the prompt and the handlers rely
+// on, so making a field optional or mandatory is a deliberate change.
+func TestRequiredFields(t *testing.T) {
+	want := map[string][]string{
+		"detect_regression":   {"description"},
+		"triage_issue":        {"affected_users_estimate", "regression_type", "severity"},
+		"attribute_to_owner":  {"description", "regression_type"},
+		"generate_fix_plan":   {"priority", "regression_type", "root_cause", "severity"},
+		"generate_repro_test": {"regression_type"},
+		"propose_patch":       {"regression_type", "root_cause"},
+		"read_file":           {"path"},
+		"search_code":         {"pattern"},
+		"list_dir":            nil,
+		"git_log":             nil,
+		"plan_rollback":       {"culprit"},
+	}
+	for _, tool := range registeredTools(t) {
+		w, ok := want[tool.Name()]
+		if !ok {
+			continue
+		}
+		got := schemaRequired(t, tool.Schema())
+		sort.Strings(got)
+		if len(got) != len(w) || (len(w) > 0 && !reflect.DeepEqual(got, w)) {
+			t.Errorf("%s: required = %v, want %v", tool.Name(), got, w)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package tools
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// StageCost is the cost of the regression had it been caught at one stage of
+// the shift-left chain.
+type StageCost struct {
+	Stage      string  `json:"stage"`
+	Multiplier float64 `json:"multiplier"`
+	CPDScore   float64 `json:"cpd_score"`
+	// Savings is the CPD saved compared to where the bug was actually found.
+	Savings float64 `json:"savings"`
+	// Technique is how this stage could catch this regression type; empty
+	// if the detectability matrix says it realistically cannot.
+	Technique string `json:"technique,omitempty"`
+}
+
+// detectabilityMatrix records, per regression type, which stages can
+// realistically catch it and with what technique. Stages absent from a
+// type's row are assumed unable to catch that type.
+var detectabilityMatrix = map[string]map[string]string{
+	"null_pointer": {
+		"ide":         "static analysis (nilness / nullability checks)",
+		"local_test":  "unit tests with nil and zero-value inputs",
+		"ci":          "static analysis (staticcheck SA5011) in CI",
+		"code_review": "nullability review of changed call sites",
+	},
+	"performance": {
+		"ci":      "benchmarks with a regression gate (e.g. benchstat)",
+		"staging": "load tests with production-like data",
+	},
+	"crash": {
+		"local_test":  "unit and fuzz tests around the failing path",
+		"ci":          "race detector and fault-injection tests",
+		"code_review": "review of error handling on the changed path",
+		"staging":     "canary deployment with crash-rate alerting",
+	},
+	"memory_leak": {
+		"ci":      "heap-profiled soak tests (-memprofile)",
+		"staging": "long-running soak tests with heap growth alerts",
+	},
+	"logic_error": {
+		"local_test":  "unit tests covering the edge case",
+		"code_review": "code review of the changed logic",
+		"ci":          "integration tests",
+	},
+	"data_corruption": {
+		"code_review": "migration and transaction review",
+		"ci":          "integration tests against a real database",
+		"staging":     "data validation checks on a production snapshot",
+	},
+	"api_breaking_change": {
+		"code_review": "API diff review",
+		"ci":          "API compatibility checks (apidiff, buf breaking, OpenAPI diff)",
+	},
+	"security_flaw": {
+		"ide":         "SAST plugin in the editor",
+		"code_review": "security review checklist",
+		"ci":          "SAST and dependency scanning (Semgrep, CodeQL)",
+	},
+	"unknown": {
+		"local_test": "regression test for the failure",
+		"ci":         "regression test suite",
+	},
+}
+
+// detectionTechnique returns how stage could catch regrType under the model,
+// or "" if it cannot. Model overrides take precedence over the built-in matrix.
+func (m CPDModel) detectionTechnique(regrType, stage string) string {
+	if row, ok := m.Detectability[regrType]; ok {
+		if t, ok := row[stage]; ok {
+			return t
+		}
+	}
+	return detectabilityMatrix[regrType][stage]
+}
+
+// shiftLeftPath walks the model's chain from env back to its leftmost stage,
+// pricing the regression at every stage.
+func (m CPDModel) shiftLeftPath(b cpdBreakdown, regrType string) []StageCost {
+	var path []StageCost
+	seen := map[string]bool{}
+	for stage := b.environment; stage != "" && !seen[stage]; stage = m.Environments[stage].ShiftLeft {
+		seen[stage] = true
+		mult := m.Environments[stage].Multiplier
+		score := b.scoreAt(mult)
+		path = append(path, StageCost{
+			Stage:      stage,
+			Multiplier: mult,
+			CPDScore:   score,
+			Savings:    math.Round((b.score-score)*100) / 100,
+			Technique:  m.detectionTechnique(regrType, stage),
+		})
+	}
+	return path
+}
+
+// recommendShiftLeft picks the earliest stage on path that could catch the
+// regression. If the matrix knows of no such stage, it falls back to the
+// stage immediately to the left.
+func recommendShiftLeft(path []StageCost) StageCost {
+	for i := len(path) - 1; i >= 0; i-- {
+		if path[i].Technique != "" {
+			return path[i]
+		}
+	}
+	if len(path) > 1 {
+		return path[1]
+	}
+	return path[0]
+}
+
+// explainShiftLeft renders the chain and the recommendation.
+func explainShiftLeft(path []StageCost, rec StageCost) string {
+	found := path[0]
+	steps := make([]string, len(path))
+	for i, s := range path {
+		steps[i] = fmt.Sprintf("%s %.0f", s.Stage, s.CPDScore)
+	}
+	chain := " Shift-left chain (CPD): " + strings.Join(steps, " → ") + "."
+	if rec.Stage == found.Stage {
+		if found.Technique != "" {
+			return chain + fmt.Sprintf(" %s is already the earliest stage that realistically catches this regression type (%s).",
+				found.Stage, found.Technique)
+		}
+		return chain + " No earlier stage is available."
+	}
+	how := "the next stage to the left"
+	if rec.Technique != "" {
+		how = rec.Technique
+	}
+	cheaper := ""
+	if rec.CPDScore > 0 {
+		cheaper = fmt.Sprintf(", %.0fx cheaper", found.CPDScore/rec.CPDScore)
+	}
+	return chain + fmt.Sprintf(" Earliest realistic catch point is %s via %s: CPD %.0f instead of %.0f (saves %.0f%s).",
+		rec.Stage, how, rec.CPDScore, found.CPDScore, rec.Savings, cheaper)
+}
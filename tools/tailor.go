@@ -0,0 +1,264 @@
+package tools
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// tailorToRepo rewrites a playbook's generic advice in terms of the tooling
+// detected in the repository. The playbook's slices are copied first because
+// they are shared with the fixPlaybooks table.
+func tailorToRepo(p *GenerateFixPlanOutput, rc *RepoContext, input GenerateFixPlanInput) {
+	p.FixSteps = append([]FixStep(nil), p.FixSteps...)
+	p.PreventionMeasures = append([]string(nil), p.PreventionMeasures...)
+	p.ShiftLeftRecommendations = append([]string(nil), p.ShiftLeftRecommendations...)
+
+	lang := rc.Primary()
+	scope := testScope(rc, input.AffectedFiles)
+	regrType := input.RegressionType
+
+	for i := range p.FixSteps {
+		step := &p.FixSteps[i]
+		switch step.Action {
+		case "profile":
+			if cmd := profileCommand(lang, regrType, scope); cmd != "" {
+				step.Description = cmd
+			}
+		case "benchmark":
+			if cmd := benchmarkAdvice(rc, scope); cmd != "" {
+				step.Description = cmd
+			}
+		case "reproduce", "test":
+			if cmd := scopedTestCommand(rc, regrType, scope); cmd != "" {
+				step.Description += fmt.Sprintf(" (run: `%s`)", cmd)
+			}
+		case "annotation":
+			if advice := nullSafetyAdvice(rc); advice != "" {
+				step.Description = advice
+			}
+		}
+	}
+
+	if regrType == "null_pointer" && lang != "java" {
+		// The generic playbook's annotation advice only applies to Java.
+		for i, m := range p.PreventionMeasures {
+			if strings.Contains(m, "@NullSafe") {
+				p.PreventionMeasures[i] = nullSafetyAdvice(rc) + " for all new code"
+			}
+		}
+	}
+	if rule := lintRule(lang, regrType); rule != "" {
+		if len(rc.LinterConfigs) > 0 {
+			p.PreventionMeasures = append(p.PreventionMeasures,
+				fmt.Sprintf("Extend %s to enable %s, enforced by `%s`", rc.LinterConfigs[0], rule,
+					firstNonEmpty(rc.LintCommand, defaultLinter[lang].command)))
+		} else {
+			p.PreventionMeasures = append(p.PreventionMeasures,
+				fmt.Sprintf("Add %s enabling %s and run `%s` in CI", defaultLinter[lang].config, rule, defaultLinter[lang].command))
+		}
+	}
+	if len(rc.CIConfigs) > 0 {
+		if cmd := ciGate(rc, regrType, scope); cmd != "" {
+			p.ShiftLeftRecommendations = append(p.ShiftLeftRecommendations,
+				fmt.Sprintf("Add `%s` as a required job in %s", cmd, rc.CIConfigs[0]))
+		}
+	} else {
+		p.ShiftLeftRecommendations = append(p.ShiftLeftRecommendations,
+			"No CI configuration found in the repository: add a pipeline that runs `"+
+				firstNonEmpty(rc.TestCommand, "the test suite")+"` on every change")
+	}
+}
+
+// testScope narrows test commands to the packages/directories touched by the
+// affected files. For Go it returns package patterns like ./auth/...
+// Affected files may be absolute or machine-specific paths from a stack
+// trace; they are mapped onto the checkout like propose_patch does, and
+// those outside it are dropped.
+func testScope(rc *RepoContext, files []string) string {
+	var rel []string
+	for _, f := range files {
+		if f = repoRelative(rc.Root, f); f != "" {
+			rel = append(rel, f)
+		}
+	}
+	files = rel
+	if rc.Primary() != "go" {
+		seen := map[string]bool{}
+		var dirs []string
+		for _, f := range files {
+			d := path.Dir(f)
+			if d != "." && !seen[d] {
+				seen[d] = true
+				dirs = append(dirs, d)
+			}
+		}
+		sort.Strings(dirs)
+		return strings.Join(dirs, " ")
+	}
+	seen := map[string]bool{}
+	var pkgs []string
+	for _, f := range files {
+		if !strings.HasSuffix(f, ".go") {
+			continue
+		}
+		pkg := "./..."
+		if d := path.Dir(f); d != "." {
+			pkg = "./" + d + "/..."
+		}
+		if !seen[pkg] {
+			seen[pkg] = true
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	if len(pkgs) == 0 {
+		return "./..."
+	}
+	sort.Strings(pkgs)
+	return strings.Join(pkgs, " ")
+}
+
+func scopedTestCommand(rc *RepoContext, regrType, scope string) string {
+	switch rc.Primary() {
+	case "go":
+		if regrType == "crash" || regrType == "null_pointer" || regrType == "data_corruption" {
+			return "go test -race " + scope
+		}
+		return "go test " + scope
+	case "python":
+		if strings.Contains(rc.TestCommand, "pytest") && scope != "" {
+			return rc.TestCommand + " " + scope
+		}
+	case "java":
+		// Maven and Gradle select tests by class, not path.
+		return rc.TestCommand
+	}
+	return rc.TestCommand
+}
+
+func profileCommand(lang, regrType, scope string) string {
+	mem := regrType == "memory_leak"
+	switch lang {
+	case "go":
+		if mem {
+			return fmt.Sprintf("Capture heap profiles: `go test -run '^$' -bench . -memprofile mem.out %s`, then `go tool pprof -sample_index=inuse_space mem.out`", scope)
+		}
+		return fmt.Sprintf("Profile the hot path: `go test -run '^$' -bench . -cpuprofile cpu.out %s`, then `go tool pprof -top cpu.out`", scope)
+	case "python":
+		if mem {
+			return "Capture allocations with `memray run` (or tracemalloc snapshots) before and after the change"
+		}
+		return "Profile with `py-spy record -o profile.svg -- python -m pytest " + scope + "`"
+	case "javascript":
+		if mem {
+			return "Capture heap snapshots with `node --heap-prof` before and after the change"
+		}
+		return "Profile with `node --cpu-prof` and load the .cpuprofile in Chrome DevTools"
+	case "java":
+		return "Record with JDK Flight Recorder (`-XX:StartFlightRecording=duration=60s,filename=rec.jfr`) and inspect in JDK Mission Control"
+	}
+	return ""
+}
+
+func benchmarkAdvice(rc *RepoContext, scope string) string {
+	switch rc.Primary() {
+	case "go":
+		cmd := rc.BenchCommand
+		if strings.HasPrefix(cmd, "go test") {
+			cmd = "go test -run '^$' -bench . -benchmem -count 10 " + scope
+		}
+		return fmt.Sprintf("Add a Benchmark for the regressed path and compare runs with benchstat (run: `%s`)", cmd)
+	case "python":
+		if rc.BenchCommand != "" {
+			return fmt.Sprintf("Add a pytest-benchmark case for the regressed path (run: `%s`)", rc.BenchCommand)
+		}
+		return "Add pytest-benchmark to the dev dependencies and a benchmark for the regressed path"
+	case "java":
+		if rc.BenchCommand != "" {
+			return fmt.Sprintf("Add a JMH benchmark for the regressed path (run: `%s`)", rc.BenchCommand)
+		}
+		return "Add a JMH benchmark module for the regressed path"
+	case "javascript":
+		if rc.BenchCommand != "" {
+			return fmt.Sprintf("Add a benchmark for the regressed path (run: `%s`)", rc.BenchCommand)
+		}
+		return "Add a tinybench benchmark for the regressed path and a `bench` script to package.json"
+	}
+	return ""
+}
+
+func nullSafetyAdvice(rc *RepoContext) string {
+	switch rc.Primary() {
+	case "go":
+		cfg := "a new .golangci.yml"
+		for _, c := range rc.LinterConfigs {
+			if strings.HasPrefix(c, ".golangci") {
+				cfg = c
+			}
+		}
+		return "Go has no null-safety annotations: enable the nilness and staticcheck (SA5011) linters in " + cfg
+	case "javascript":
+		return "Enable strictNullChecks in tsconfig.json so possibly-undefined values fail type checking"
+	case "python":
+		return "Annotate the values as Optional[...] and enforce with mypy --strict-optional"
+	case "java":
+		return "Add @Nullable/@NonNull annotations and enforce them with NullAway or SpotBugs"
+	}
+	return ""
+}
+
+// defaultLinter is the linter to suggest when a repository has none configured.
+var defaultLinter = map[string]struct{ config, command string }{
+	"go":         {".golangci.yml", "golangci-lint run ./..."},
+	"python":     {"a [tool.ruff] section in pyproject.toml", "ruff check ."},
+	"javascript": {"eslint.config.js", "npx eslint ."},
+	"java":       {"the SpotBugs Maven/Gradle plugin", "mvn spotbugs:check"},
+}
+
+// lintRule names the linter rules that catch regrType in lang.
+func lintRule(lang, regrType string) string {
+	rules := map[string]map[string]string{
+		"go": {
+			"null_pointer":  "nilness and staticcheck SA5011",
+			"crash":         "errcheck and nilerr",
+			"security_flaw": "gosec",
+			"memory_leak":   "bodyclose and sqlclosecheck",
+			"performance":   "prealloc and perfsprint",
+		},
+		"python": {
+			"null_pointer":  "mypy strict optional checking",
+			"security_flaw": "ruff's flake8-bandit (S) rules",
+			"crash":         "ruff's flake8-bugbear (B) rules",
+		},
+		"javascript": {
+			"null_pointer":  "@typescript-eslint/no-non-null-assertion",
+			"security_flaw": "eslint-plugin-security",
+			"crash":         "@typescript-eslint/no-floating-promises",
+		},
+		"java": {
+			"null_pointer":  "SpotBugs NP_* detectors",
+			"security_flaw": "Find Security Bugs",
+		},
+	}
+	return rules[lang][regrType]
+}
+
+// ciGate is the command CI should run to catch regrType.
+func ciGate(rc *RepoContext, regrType, scope string) string {
+	switch regrType {
+	case "performance":
+		return rc.BenchCommand
+	case "null_pointer", "security_flaw":
+		return firstNonEmpty(rc.LintCommand, rc.TestCommand)
+	case "crash", "data_corruption":
+		if rc.Primary() == "go" {
+			return "go test -race " + scope
+		}
+	case "memory_leak":
+		if rc.Primary() == "go" {
+			return "go test -run '^$' -bench . -memprofile mem.out " + scope
+		}
+	}
+	return rc.TestCommand
+}
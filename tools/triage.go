@@ -1,8 +1,8 @@
 package tools
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 )
 
 // Priority levels map to P0-P3 incident severity.
@@ -15,12 +15,29 @@ const (
 	PriorityP3 Priority = "P3" // Backlog
 )
 
+// AtLeast reports whether p is as urgent as or more urgent than q
+// (P0 is the most urgent). Unknown priorities are never at least anything.
+func (p Priority) AtLeast(q Priority) bool {
+	rank := map[Priority]int{PriorityP0: 0, PriorityP1: 1, PriorityP2: 2, PriorityP3: 3}
+	pr, ok1 := rank[p]
+	qr, ok2 := rank[q]
+	return ok1 && ok2 && pr <= qr
+}
+
 // TriageIssueInput is the input for the triage_issue tool.
 type TriageIssueInput struct {
-	RegressionType      string  `json:"regression_type" jsonschema_description:"Type of regression from detect_regression output"`
-	Severity            string  `json:"severity" jsonschema_description:"Severity from detect_regression: critical, high, medium, or low"`
-	Environment         string  `json:"environment" jsonschema_description:"Where the issue was found: ide, local_test, ci, code_review, staging, or production"`
-	AffectedUsersEstimate int   `json:"affected_users_estimate" jsonschema_description:"Estimated number of users affected (0 if unknown)"`
+	RegressionType        string `json:"regression_type" jsonschema_description:"Type of regression from detect_regression output" jsonschema_enum:"regression_type"`
+	Severity              string `json:"severity" jsonschema_description:"Severity from detect_regression: critical, high, medium, or low" jsonschema_enum:"severity"`
+	Environment           string `json:"environment,omitempty" jsonschema_description:"Where the issue was found: ide, local_test, ci, code_review, staging, or production (or a custom stage from the CPD model; optional, defaults to the model's default stage)" jsonschema_enum:"environment"`
+	AffectedUsersEstimate int    `json:"affected_users_estimate" jsonschema_description:"Estimated number of users affected (0 if unknown)" jsonschema_minimum:"0"`
+	// RevenueImpactPerHour, SLATier and Org feed the optional revenue, SLA
+	// and per-org threshold terms of the CPD model.
+	RevenueImpactPerHour float64 `json:"revenue_impact_per_hour,omitempty" jsonschema_description:"Estimated revenue at risk per hour while the issue persists (optional)" jsonschema_minimum:"0"`
+	SLATier              string  `json:"sla_tier,omitempty" jsonschema_description:"SLA tier of the affected service, e.g. gold, silver, bronze (optional)"`
+	Org                  string  `json:"org,omitempty" jsonschema_description:"Organisation whose priority thresholds apply (optional)"`
+	// Service and DetectedAt feed time-aware triage (see SetTriagePolicy).
+	Service    string `json:"service,omitempty" jsonschema_description:"Name of the affected service, used to look up its SLO error budget (optional)"`
+	DetectedAt string `json:"detected_at,omitempty" jsonschema_description:"When the issue was detected, RFC 3339 (optional, defaults to now)" jsonschema_format:"date-time"`
 }
 
 // TriageIssueOutput contains the CPD score and routing decision.
@@ -28,108 +45,76 @@ type TriageIssueInput struct {
 // the further they are from the point of introduction.
 type TriageIssueOutput struct {
 	CPDScore          float64  `json:"cpd_score"`
-	CPDMultiplier     int      `json:"cpd_multiplier"`
+	CPDMultiplier     float64  `json:"cpd_multiplier"`
 	Priority          Priority `json:"priority"`
 	RecommendedAction string   `json:"recommended_action"`
 	ShiftLeftTarget   string   `json:"shift_left_target"`
-	CostRationale     string   `json:"cost_rationale"`
-}
-
-// environmentMultiplier reflects the cost escalation model from Fix Fast.
-// A bug caught in production is ~100x more expensive than one caught in the IDE.
-var environmentMultiplier = map[string]int{
-	"ide":          1,
-	"local_test":   3,
-	"ci":           10,
-	"code_review":  15,
-	"staging":      30,
-	"production":   100,
+	// ShiftLeftPath prices the regression at every stage from where it was
+	// found back to the leftmost stage, in that order.
+	ShiftLeftPath []StageCost `json:"shift_left_path"`
+	// ShiftLeftSavings is the CPD saved had it been caught at ShiftLeftTarget.
+	ShiftLeftSavings float64 `json:"shift_left_savings"`
+	CostRationale    string  `json:"cost_rationale"`
+	// TimeContext is set when a triage policy is configured.
+	TimeContext *TimeContext `json:"time_context,omitempty"`
 }
 
-var severityBaseScore = map[string]float64{
-	"critical": 100.0,
-	"high":     50.0,
-	"medium":   20.0,
-	"low":      5.0,
-}
-
-// TriageIssue calculates the Cost Per Developer score and assigns priority.
-func TriageIssue(inputJSON string) (string, error) {
+// TriageIssue calculates the Cost Per Developer score and assigns priority
+// using the current CPD model (see SetCPDModel), then adjusts it for business
+// hours, release freezes and SLO error budgets if a triage policy is set.
+func TriageIssue(ctx context.Context, inputJSON string) (string, error) {
 	var input TriageIssueInput
-	if err := json.Unmarshal([]byte(inputJSON), &input); err != nil {
+	if err := decodeInput(inputJSON, &input); err != nil {
 		return "", err
 	}
 
-	multiplier, ok := environmentMultiplier[input.Environment]
-	if !ok {
-		multiplier = 30 // default to staging-level cost
+	model := CurrentCPDModel()
+	if input.Environment == "" {
+		input.Environment = model.DefaultEnvironment
 	}
+	b := model.score(input)
+	priority := b.priority()
 
-	baseScore, ok := severityBaseScore[input.Severity]
-	if !ok {
-		baseScore = 20.0
-	}
+	action := recommendedAction(priority)
 
-	// CPD = base_severity_score × environment_multiplier × user_impact_factor
-	userImpactFactor := 1.0
-	if input.AffectedUsersEstimate > 1000 {
-		userImpactFactor = 2.0
-	} else if input.AffectedUsersEstimate > 100 {
-		userImpactFactor = 1.5
+	var timeContext *TimeContext
+	if policy := currentTriagePolicy(); policy != nil {
+		priority, action, timeContext = policy.apply(ctx, input, priority, action)
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
 	}
 
-	cpdScore := baseScore * float64(multiplier) * userImpactFactor
-
-	var priority Priority
-	var action string
-	switch {
-	case cpdScore >= 5000:
-		priority = PriorityP0
-		action = "Page on-call immediately. Revert or hotfix within 1 hour."
-	case cpdScore >= 1000:
-		priority = PriorityP1
-		action = "Fix today. Assign to the last committer and block release if unresolved."
-	case cpdScore >= 200:
-		priority = PriorityP2
-		action = "Schedule fix this sprint. Add to team backlog with owner assigned."
-	default:
-		priority = PriorityP3
-		action = "Add to backlog. Consider addressing during next refactoring cycle."
-	}
-
-	// Recommend the 'shift left' target: what stage would have caught this earlier?
-	shiftLeft := shiftLeftTarget(input.Environment)
+	// Recommend the 'shift left' target: the earliest stage that could
+	// realistically have caught this type of regression.
+	path := model.shiftLeftPath(b, input.RegressionType)
+	target := recommendShiftLeft(path)
 
 	output := TriageIssueOutput{
-		CPDScore:          cpdScore,
-		CPDMultiplier:     multiplier,
+		CPDScore:          b.score,
+		CPDMultiplier:     b.stage.Multiplier,
 		Priority:          priority,
 		RecommendedAction: action,
-		ShiftLeftTarget:   shiftLeft,
-		CostRationale: fmt.Sprintf(
-			"Base severity score %.0f × %dx environment multiplier (found in %s) × %.1fx user impact = CPD %.0f. "+
-				"If caught at %s stage, CPD would have been %.0f (%.0fx cheaper).",
-			baseScore, multiplier, input.Environment, userImpactFactor, cpdScore,
-			shiftLeft, baseScore*float64(environmentMultiplier[shiftLeft])*userImpactFactor,
-			cpdScore/(baseScore*float64(environmentMultiplier[shiftLeft])*userImpactFactor),
-		),
+		ShiftLeftTarget:   target.Stage,
+		ShiftLeftPath:     path,
+		ShiftLeftSavings:  target.Savings,
+		CostRationale:     b.explain() + explainShiftLeft(path, target),
+		TimeContext:       timeContext,
 	}
 
 	result, err := json.Marshal(output)
 	return string(result), err
 }
 
-func shiftLeftTarget(env string) string {
-	targets := map[string]string{
-		"production":  "staging",
-		"staging":     "ci",
-		"ci":          "local_test",
-		"code_review": "local_test",
-		"local_test":  "ide",
-		"ide":         "ide", // already as left as possible
-	}
-	if t, ok := targets[env]; ok {
-		return t
+func recommendedAction(p Priority) string {
+	switch p {
+	case PriorityP0:
+		return "Page on-call immediately. Revert or hotfix within 1 hour."
+	case PriorityP1:
+		return "Fix today. Assign to the last committer and block release if unresolved."
+	case PriorityP2:
+		return "Schedule fix this sprint. Add to team backlog with owner assigned."
+	default:
+		return "Add to backlog. Consider addressing during next refactoring cycle."
 	}
-	return "ci"
 }
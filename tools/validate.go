@@ -0,0 +1,228 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Handler is the signature shared by every tool: it decodes and validates
+// the JSON input, does the work and returns a JSON result. Handlers that run
+// commands or make requests stop when ctx is done and return its error, so
+// callers can tell a timeout or cancellation from a failure.
+type Handler func(ctx context.Context, inputJSON string) (string, error)
+
+// FieldError describes one invalid field of a tool input.
+type FieldError struct {
+	Field   string   `json:"field"`
+	Problem string   `json:"problem"`
+	Value   string   `json:"value,omitempty"`
+	Allowed []string `json:"allowed,omitempty"`
+}
+
+func (e FieldError) String() string {
+	s := e.Field + ": " + e.Problem
+	if e.Value != "" {
+		s += " (got " + e.Value + ")"
+	}
+	if len(e.Allowed) > 0 {
+		s += "; allowed: " + strings.Join(e.Allowed, ", ")
+	}
+	return s
+}
+
+// ValidationError lists every invalid field of a tool input. Handlers
+// return it before doing any work, so the caller (the model or a CLI user)
+// can correct all fields in one go.
+type ValidationError struct {
+	Fields []FieldError `json:"invalid_fields"`
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		parts[i] = f.String()
+	}
+	return "invalid input: " + strings.Join(parts, "; ")
+}
+
+// decodeInput validates inputJSON against the schema of v's type and then
+// decodes it into v.
+func decodeInput(inputJSON string, v interface{}) error {
+	if err := ValidateInput(Schema(v), inputJSON); err != nil {
+		return err
+	}
+	if err := json.Unmarshal([]byte(inputJSON), v); err != nil {
+		return fmt.Errorf("invalid input: %w", err)
+	}
+	return nil
+}
+
+// ValidateInput checks a tool's JSON arguments against its schema: the
+// top level must be an object, required fields must be present (required
+// strings also non-blank), unknown fields are rejected when the schema sets
+// additionalProperties to false, and types, enums, minimums and date-time
+// formats must match. It returns a *ValidationError listing every problem.
+func ValidateInput(schema map[string]interface{}, inputJSON string) error {
+	if strings.TrimSpace(inputJSON) == "" {
+		inputJSON = "{}"
+	}
+	dec := json.NewDecoder(bytes.NewReader([]byte(inputJSON)))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return &ValidationError{Fields: []FieldError{{Field: "(input)", Problem: "not valid JSON: " + err.Error()}}}
+	}
+	var errs []FieldError
+	checkValue(schema, v, "", &errs)
+	if len(errs) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: errs}
+}
+
+func checkValue(schema map[string]interface{}, v interface{}, field string, errs *[]FieldError) {
+	name := field
+	if name == "" {
+		name = "(input)"
+	}
+	add := func(problem string, allowed []string) {
+		*errs = append(*errs, FieldError{Field: name, Problem: problem, Value: shortJSON(v), Allowed: allowed})
+	}
+
+	switch schema["type"] {
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			add("must be an object", nil)
+			return
+		}
+		props, _ := schema["properties"].(map[string]interface{})
+		for _, req := range stringList(schema["required"]) {
+			val, present := obj[req]
+			if s, isString := val.(string); !present || val == nil || (isString && strings.TrimSpace(s) == "") {
+				p, _ := props[req].(map[string]interface{})
+				*errs = append(*errs, FieldError{Field: join(field, req), Problem: "is required", Allowed: stringList(p["enum"])})
+			}
+		}
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			p, known := props[k].(map[string]interface{})
+			if !known {
+				if schema["additionalProperties"] == false {
+					allowed := make([]string, 0, len(props))
+					for name := range props {
+						allowed = append(allowed, name)
+					}
+					sort.Strings(allowed)
+					*errs = append(*errs, FieldError{Field: join(field, k), Problem: "is not a known field", Allowed: allowed})
+				}
+				continue
+			}
+			if obj[k] == nil {
+				continue // null is treated as omitted
+			}
+			checkValue(p, obj[k], join(field, k), errs)
+		}
+	case "array":
+		arr, ok := v.([]interface{})
+		if !ok {
+			add("must be an array", nil)
+			return
+		}
+		items, _ := schema["items"].(map[string]interface{})
+		for i, item := range arr {
+			checkValue(items, item, fmt.Sprintf("%s[%d]", name, i), errs)
+		}
+	case "string":
+		s, ok := v.(string)
+		if !ok {
+			add("must be a string", nil)
+			return
+		}
+		if enum := stringList(schema["enum"]); len(enum) > 0 && s != "" && !containsString(enum, s) {
+			add("is not an allowed value", enum)
+		}
+		if schema["format"] == "date-time" && s != "" {
+			if _, err := time.Parse(time.RFC3339, s); err != nil {
+				add("must be an RFC 3339 date-time, e.g. 2024-05-01T14:30:00Z", nil)
+			}
+		}
+	case "integer", "number":
+		n, ok := v.(json.Number)
+		if !ok {
+			add("must be a number", nil)
+			return
+		}
+		f, err := n.Float64()
+		if err != nil {
+			add("must be a number", nil)
+			return
+		}
+		if schema["type"] == "integer" {
+			if _, err := n.Int64(); err != nil {
+				add("must be a whole number", nil)
+				return
+			}
+		}
+		if minimum, ok := schema["minimum"].(float64); ok && f < minimum {
+			add(fmt.Sprintf("must be at least %g", minimum), nil)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			add("must be true or false", nil)
+		}
+	}
+}
+
+func join(parent, field string) string {
+	if parent == "" {
+		return field
+	}
+	return parent + "." + field
+}
+
+// stringList reads a []string or []interface{} of strings from a schema.
+func stringList(v interface{}) []string {
+	switch l := v.(type) {
+	case []string:
+		return l
+	case []interface{}:
+		out := make([]string, 0, len(l))
+		for _, x := range l {
+			if s, ok := x.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+func shortJSON(v interface{}) string {
+	if _, isObj := v.(map[string]interface{}); isObj || v == nil {
+		return ""
+	}
+	b, _ := json.Marshal(v)
+	if len(b) > 60 {
+		return string(b[:57]) + "..."
+	}
+	return string(b)
+}
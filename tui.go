@@ -0,0 +1,529 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/emyjamalian/laas-ladybug/agent"
+	"github.com/emyjamalian/laas-ladybug/report"
+	"github.com/emyjamalian/laas-ladybug/ticket"
+	"github.com/emyjamalian/laas-ladybug/tools"
+)
+
+// tui is the interactive terminal front end: it collects a report, shows
+// each tool's result as the agent runs, and lets the user correct detection
+// and triage before exporting or filing the analysis. It is line-based and
+// uses ANSI styling only, so it works in any terminal.
+type tui struct {
+	in    *bufio.Reader
+	out   io.Writer
+	color bool
+	mu    sync.Mutex
+}
+
+func newTUI() *tui {
+	_, noColor := os.LookupEnv("NO_COLOR")
+	return &tui{in: bufio.NewReader(os.Stdin), out: os.Stdout, color: !noColor && os.Getenv("TERM") != "dumb"}
+}
+
+func (t *tui) style(code, s string) string {
+	if !t.color {
+		return s
+	}
+	return "\x1b[" + code + "m" + s + "\x1b[0m"
+}
+
+func (t *tui) bold(s string) string   { return t.style("1", s) }
+func (t *tui) dim(s string) string    { return t.style("2", s) }
+func (t *tui) accent(s string) string { return t.style("36", s) }
+func (t *tui) good(s string) string   { return t.style("32", s) }
+func (t *tui) bad(s string) string    { return t.style("31", s) }
+
+func (t *tui) priority(p string) string {
+	switch p {
+	case "P0":
+		return t.style("1;41;97", " "+p+" ")
+	case "P1":
+		return t.style("1;31", p)
+	case "P2":
+		return t.style("33", p)
+	}
+	return t.dim(firstNonEmptyString(p, "--"))
+}
+
+func (t *tui) printf(format string, args ...interface{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fmt.Fprintf(t.out, format, args...)
+}
+
+func (t *tui) heading(s string) {
+	t.printf("\n%s\n", t.bold(t.accent("── "+s+" "+strings.Repeat("─", max(0, 60-len(s))))))
+}
+
+// readLine prompts and returns one trimmed line; ok is false at EOF.
+func (t *tui) readLine(prompt string) (string, bool) {
+	t.printf("%s ", t.accent(prompt))
+	line, err := t.in.ReadString('\n')
+	if err != nil && line == "" {
+		return "", false
+	}
+	return strings.TrimSpace(line), true
+}
+
+// readBlock reads pasted text until a line containing only "." or EOF, so
+// stack traces with blank lines survive intact.
+func (t *tui) readBlock(prompt string) string {
+	t.printf("%s\n%s\n", t.bold(prompt), t.dim("Paste freely; blank lines are kept. Finish with a line containing only \".\" or Ctrl-D."))
+	var lines []string
+	for {
+		line, err := t.in.ReadString('\n')
+		trimmed := strings.TrimRight(line, "\r\n")
+		if trimmed == "." {
+			break
+		}
+		if line != "" {
+			lines = append(lines, trimmed)
+		}
+		if err != nil {
+			break
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// pick shows a numbered menu. An empty answer returns def.
+func (t *tui) pick(title string, options []string, def string) string {
+	t.printf("\n%s\n", t.bold(title))
+	for i, o := range options {
+		marker := "  "
+		if o == def {
+			marker = t.accent("›") + " "
+		}
+		t.printf("  %s%s %s\n", marker, t.dim(strconv.Itoa(i+1)+")"), o)
+	}
+	for {
+		hint := "Enter to skip"
+		if def != "" {
+			hint = "Enter for " + def
+		}
+		choice, ok := t.readLine("> (" + hint + ")")
+		if !ok || choice == "" {
+			return def
+		}
+		for i, o := range options {
+			if choice == strconv.Itoa(i+1) || strings.EqualFold(choice, o) {
+				return o
+			}
+		}
+		t.printf("%s\n", t.bad(fmt.Sprintf("Pick 1-%d or type an option.", len(options))))
+	}
+}
+
+func (t *tui) confirm(prompt string, def bool) bool {
+	hint := "[y/N]"
+	if def {
+		hint = "[Y/n]"
+	}
+	answer, ok := t.readLine(prompt + " " + hint)
+	if !ok || answer == "" {
+		return def
+	}
+	return strings.HasPrefix(strings.ToLower(answer), "y")
+}
+
+// runTUI drives an interactive analysis from input collection to filing.
+func runTUI(o analyzeOptions, in tools.DetectRegressionInput) error {
+	t := newTUI()
+	printBanner()
+
+	t.heading("Report")
+	if in.Description == "" {
+		in.Description = t.readBlock("Describe the bug, or paste a stack trace or diff:")
+	}
+	if strings.TrimSpace(in.Description) == "" && in.ErrorMessage == "" {
+		return usageError("no input provided")
+	}
+	if len(in.FilesChanged) == 0 {
+		if files, _ := t.readLine("Files changed (comma-separated, Enter to skip):"); files != "" {
+			in.FilesChanged = splitList(files)
+		}
+	}
+	if in.Environment == "" {
+		in.Environment = t.pick("Where was this issue detected?", tools.CurrentCPDModel().EnvironmentNames(), "")
+	}
+	if o.users == 0 {
+		if users, _ := t.readLine("Affected users (estimate, Enter to skip):"); users != "" {
+			o.users, _ = strconv.Atoi(users)
+		}
+	}
+
+	integ, err := loadIntegrations()
+	if err != nil {
+		return err
+	}
+
+	t.heading("Analysis")
+	a := agent.New()
+	if o.model != "" {
+		a.SetModel(o.model)
+	}
+	if a, err = o.tools.apply(a); err != nil {
+		return err
+	}
+	a.SetHooks(agent.Hooks{
+		ToolStarted: func(name, args string) {
+			t.printf("%s %s\n", t.accent("▶"), t.bold(name))
+		},
+		ToolFinished: func(name, args, result string, err error) {
+			if err != nil {
+				t.printf("  %s %v\n", t.bad("✗"), err)
+				return
+			}
+			for _, line := range t.toolSummary(name, result) {
+				t.printf("  %s %s\n", t.good("✓"), line)
+			}
+		},
+	})
+	ctx, stop := interruptible(context.Background())
+	sess, err := a.Start(ctx, o.prompt(in), io.Discard)
+	stop()
+	if err != nil {
+		return err
+	}
+	rep := sess.Report
+	if o.writePatch != "" {
+		if err := writePatch(rep, o.writePatch); err != nil {
+			t.printf("%s\n", t.bad("patch: "+err.Error()))
+		} else {
+			t.printf("%s\n", t.good("Wrote patch to "+o.writePatch+" (apply with: git apply "+o.writePatch+")"))
+		}
+	}
+	t.overview(rep)
+	integ.saveSession(sess, t.out)
+	defer integ.saveSession(sess, t.out)
+	return t.review(a, sess, integ, o)
+}
+
+// toolSummary condenses a tool result to the lines worth seeing live.
+func (t *tui) toolSummary(name, result string) []string {
+	rep := report.New("")
+	if err := rep.Record(name, "{}", result); err != nil {
+		return nil
+	}
+	switch {
+	case rep.Detection != nil:
+		d := rep.Detection
+		return []string{fmt.Sprintf("%s, severity %s (confidence %.2f)", t.bold(string(d.RegressionType)), d.Severity, d.Confidence)}
+	case rep.Triage != nil:
+		tr := rep.Triage
+		return []string{fmt.Sprintf("%s  CPD %.0f — %s", t.priority(string(tr.Priority)), tr.CPDScore, tr.RecommendedAction)}
+	case rep.Attribution != nil:
+		return []string{"owner " + t.bold(rep.Component()) + ", reviewer " + rep.Attribution.RecommendedReviewer}
+	case rep.FixPlan != nil:
+		lines := []string{fmt.Sprintf("%d fix steps, effort %s", len(rep.FixPlan.FixSteps), rep.FixPlan.EstimatedEffort)}
+		if len(rep.FixPlan.ImmediateActions) > 0 {
+			lines = append(lines, "first: "+rep.FixPlan.ImmediateActions[0])
+		}
+		return lines
+	case rep.ReproTest != nil:
+		return []string{"repro test " + rep.ReproTest.TestPath + " — " + rep.ReproTest.Verification}
+	case rep.Rollback != nil:
+		return []string{fmt.Sprintf("%d rollback steps planned", len(rep.Rollback.Steps))}
+	case rep.Patch != nil:
+		if rep.Patch.Valid {
+			return []string{"valid patch for " + rep.Patch.File}
+		}
+		return []string{"patch rejected: " + rep.Patch.Rejection}
+	}
+	return []string{name + " done"}
+}
+
+func (t *tui) overview(rep *report.Report) {
+	t.heading("Result")
+	t.printf("%s  %s %s in %s\n", t.priority(rep.Priority()), t.bold(rep.Severity()), rep.RegressionType(), t.bold(rep.Component()))
+	t.printf("%s\n", rep.Headline())
+	if rep.Triage != nil {
+		t.printf("%s %.0f — %s\n", t.dim("CPD"), rep.Triage.CPDScore, rep.Triage.RecommendedAction)
+	}
+	if p := rep.FixPlan; p != nil {
+		for i, a := range p.ImmediateActions {
+			if i == 3 {
+				break
+			}
+			t.printf("  • %s\n", a)
+		}
+		t.printf("%s %s\n", t.dim("Effort"), p.EstimatedEffort)
+	}
+	for _, o := range rep.Overrides {
+		t.printf("%s %s\n", t.style("33", "edited"), o)
+	}
+}
+
+// review is the post-analysis menu.
+func (t *tui) review(a *agent.Agent, sess *agent.Session, integ *integrations, o analyzeOptions) error {
+	rep := sess.Report
+	for {
+		t.printf("\n%s  %s  %s  %s  %s  %s  %s  %s\n",
+			t.accent("[s]")+"everity/type", t.accent("[p]")+"riority", t.accent("[e]")+"nv/users", t.accent("[a]")+"sk",
+			t.accent("[v]")+"iew", t.accent("[x]")+"port", t.accent("[f]")+"ile", t.accent("[q]")+"uit")
+		choice, ok := t.readLine(">")
+		if !ok {
+			return nil
+		}
+		var err error
+		switch strings.ToLower(choice) {
+		case "s":
+			err = t.editDetection(rep)
+		case "p":
+			err = t.editPriority(rep)
+		case "e":
+			err = t.editEnvironment(rep)
+		case "a":
+			t.ask(a, sess, integ)
+		case "v":
+			t.printf("\n%s\n", strings.TrimSpace(rep.Summary))
+			if len(rep.Overrides) > 0 {
+				t.printf("\n%s\n", t.style("33", "Note: the summary predates these edits: "+strings.Join(rep.Overrides, "; ")))
+			}
+			tk := ticket.Build(rep, nil)
+			t.printf("\n%s\n%s\n", t.bold(tk.Title), tk.Body)
+		case "x":
+			err = t.export(rep)
+		case "f":
+			err = t.file(rep, integ, o)
+		case "q", "quit", "exit":
+			return nil
+		default:
+			continue
+		}
+		if err != nil {
+			t.printf("%s\n", t.bad("error: "+err.Error()))
+		}
+	}
+}
+
+// ask takes follow-up questions until an empty line. Tools the model calls
+// again show up through the hooks, and their results update the report.
+func (t *tui) ask(a *agent.Agent, sess *agent.Session, integ *integrations) {
+	for {
+		q, ok := t.readLine("Question (Enter to go back):")
+		if !ok || q == "" {
+			return
+		}
+		ctx, stop := interruptible(context.Background())
+		answer, err := a.Ask(ctx, sess, q, io.Discard)
+		stop()
+		if err != nil {
+			t.printf("%s\n", t.bad("error: "+err.Error()))
+			continue
+		}
+		t.printf("\n%s\n\n", strings.TrimSpace(answer))
+		integ.saveSession(sess, t.out)
+	}
+}
+
+func (t *tui) editDetection(rep *report.Report) error {
+	if rep.Detection == nil {
+		return fmt.Errorf("detect_regression did not run")
+	}
+	oldType, oldSev := string(rep.Detection.RegressionType), string(rep.Detection.Severity)
+	newType := t.pick("Regression type", regressionTypes(), oldType)
+	newSev := t.pick("Severity", []string{"critical", "high", "medium", "low"}, oldSev)
+	if newType == oldType && newSev == oldSev {
+		return nil
+	}
+	rep.Detection.RegressionType = tools.RegressionType(newType)
+	rep.Detection.Severity = tools.Severity(newSev)
+	if newType != oldType {
+		rep.Overrides = append(rep.Overrides, "regression type: "+oldType+" → "+newType)
+	}
+	if newSev != oldSev {
+		rep.Overrides = append(rep.Overrides, "severity: "+oldSev+" → "+newSev)
+	}
+	if t.confirm("Re-run triage, attribution and fix plan?", true) {
+		if err := rerunDownstream(rep, "triage_issue", "attribute_to_owner", "generate_fix_plan"); err != nil {
+			return err
+		}
+	}
+	t.overview(rep)
+	return nil
+}
+
+func (t *tui) editPriority(rep *report.Report) error {
+	if rep.Triage == nil {
+		return fmt.Errorf("triage_issue did not run")
+	}
+	old := string(rep.Triage.Priority)
+	p := t.pick("Priority", []string{"P0", "P1", "P2", "P3"}, old)
+	if p == old {
+		return nil
+	}
+	rep.Triage.Priority = tools.Priority(p)
+	rep.Overrides = append(rep.Overrides, "priority: "+old+" → "+p)
+	if t.confirm("Re-run the fix plan for "+p+"?", true) {
+		if err := rerunDownstream(rep, "generate_fix_plan"); err != nil {
+			return err
+		}
+	}
+	t.overview(rep)
+	return nil
+}
+
+func (t *tui) editEnvironment(rep *report.Report) error {
+	if rep.TriageInput == nil {
+		return fmt.Errorf("triage_issue did not run")
+	}
+	in := rep.TriageInput
+	env := t.pick("Where was this issue detected?", tools.CurrentCPDModel().EnvironmentNames(), in.Environment)
+	users := in.AffectedUsersEstimate
+	if s, _ := t.readLine(fmt.Sprintf("Affected users (Enter for %d):", users)); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return fmt.Errorf("affected users must be a non-negative number")
+		}
+		users = n
+	}
+	if env == in.Environment && users == in.AffectedUsersEstimate {
+		return nil
+	}
+	if env != in.Environment {
+		rep.Overrides = append(rep.Overrides, "environment: "+firstNonEmptyString(in.Environment, "unspecified")+" → "+env)
+	}
+	if users != in.AffectedUsersEstimate {
+		rep.Overrides = append(rep.Overrides, fmt.Sprintf("affected users: %d → %d", in.AffectedUsersEstimate, users))
+	}
+	in.Environment, in.AffectedUsersEstimate = env, users
+	if err := rerunDownstream(rep, "triage_issue", "generate_fix_plan"); err != nil {
+		return err
+	}
+	t.overview(rep)
+	return nil
+}
+
+func (t *tui) export(rep *report.Report) error {
+	path, _ := t.readLine("Export to (.json for the full report, anything else for markdown):")
+	if path == "" {
+		return nil
+	}
+	var data []byte
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		var err error
+		if data, err = json.MarshalIndent(rep, "", "  "); err != nil {
+			return err
+		}
+	} else {
+		assignees, err := loadAssignees()
+		if err != nil {
+			return err
+		}
+		tk := ticket.Build(rep, assignees)
+		data = []byte("# " + tk.Title + "\n\n" + tk.Body)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return err
+	}
+	t.printf("%s\n", t.good("Wrote "+path))
+	return nil
+}
+
+func (t *tui) file(rep *report.Report, integ *integrations, o analyzeOptions) error {
+	sink := t.pick("File a ticket in", []string{"github", "jira", "webhook", "none"}, firstNonEmptyString(firstNonEmptyString(o.ticket, integ.ticketSink), "none"))
+	if sink == "none" {
+		sink = ""
+	}
+	integ.ticketSink = sink
+	integ.dryRun = o.dryRun || (sink != "" && t.confirm("Dry run (print the payload only)?", false))
+	var buf strings.Builder
+	err := integ.publish(context.Background(), rep, &buf)
+	t.printf("%s", buf.String())
+	if err == nil {
+		t.printf("%s\n", t.good("Done."))
+	}
+	return err
+}
+
+// rerunDownstream re-runs the named tools, in order, from the report's
+// current (possibly edited) results, recording the new outputs.
+func rerunDownstream(rep *report.Report, names ...string) error {
+	for _, name := range names {
+		var in interface{}
+		var handler tools.Handler
+		switch name {
+		case "triage_issue":
+			ti := tools.TriageIssueInput{}
+			if rep.TriageInput != nil {
+				ti = *rep.TriageInput
+			}
+			ti.RegressionType, ti.Severity = rep.RegressionType(), rep.Severity()
+			in, handler = ti, tools.TriageIssue
+		case "attribute_to_owner":
+			ai := tools.AttributeIssueInput{}
+			if rep.AttributionInput != nil {
+				ai = *rep.AttributionInput
+			} else if rep.DetectInput != nil {
+				ai.FilesChanged, ai.Description = rep.DetectInput.FilesChanged, rep.DetectInput.Description
+			}
+			ai.RegressionType = rep.RegressionType()
+			in, handler = ai, tools.AttributeToOwner
+		case "generate_fix_plan":
+			fi := tools.GenerateFixPlanInput{}
+			if rep.FixPlanInput != nil {
+				fi = *rep.FixPlanInput
+			} else {
+				// The model never planned a fix: start from what detection found.
+				fi.RootCause = rep.Headline()
+				if rep.Detection != nil && rep.Detection.Summary != "" {
+					fi.RootCause = rep.Detection.Summary
+				}
+				if rep.DetectInput != nil {
+					fi.AffectedFiles = rep.DetectInput.FilesChanged
+				}
+			}
+			fi.RegressionType, fi.Severity, fi.Priority = rep.RegressionType(), rep.Severity(), rep.Priority()
+			if c := rep.Component(); c != "unknown" {
+				fi.Component = c
+			}
+			in, handler = fi, tools.GenerateFixPlan
+		default:
+			return fmt.Errorf("cannot re-run %s", name)
+		}
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		out, err := handler(context.Background(), string(data))
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		// Keep a priority the reviewer set by hand.
+		var kept tools.Priority
+		if name == "triage_issue" && rep.Triage != nil && hasOverride(rep, "priority:") {
+			kept = rep.Triage.Priority
+		}
+		if err := rep.Record(name, string(data), out); err != nil {
+			return err
+		}
+		if kept != "" {
+			rep.Triage.Priority = kept
+		}
+	}
+	return nil
+}
+
+func hasOverride(rep *report.Report, prefix string) bool {
+	for _, o := range rep.Overrides {
+		if strings.HasPrefix(o, prefix) {
+			return true
+		}
+	}
+	return false
+}
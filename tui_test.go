@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/emyjamalian/laas-ladybug/report"
+	"github.com/emyjamalian/laas-ladybug/tools"
+)
+
+func TestRerunDownstreamWithoutFixPlanInput(t *testing.T) {
+	in := `{"description": "NPE in auth/login.go after deploying v2.3.1", "environment": "production", "files_changed": ["auth/login.go"]}`
+	out, err := tools.DetectRegression(context.Background(), in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rep := &report.Report{Input: "NPE in auth/login.go after deploying v2.3.1"}
+	if err := rep.Record("detect_regression", in, out); err != nil {
+		t.Fatal(err)
+	}
+	if err := rerunDownstream(rep, "triage_issue", "generate_fix_plan"); err != nil {
+		t.Fatalf("rerunDownstream: %v", err)
+	}
+	if rep.FixPlan == nil || rep.FixPlanInput.RootCause != rep.Detection.Summary {
+		t.Errorf("fix plan input = %+v, want the detection summary as the root cause", rep.FixPlanInput)
+	}
+	if len(rep.FixPlanInput.AffectedFiles) != 1 {
+		t.Errorf("affected files = %v, want the changed files", rep.FixPlanInput.AffectedFiles)
+	}
+}
@@ -0,0 +1,151 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/emyjamalian/laas-ladybug/tools"
+)
+
+// Alertmanager adapts Prometheus Alertmanager webhook_config payloads.
+// Alertmanager does not sign payloads; the receiver must be configured with
+// an http_config.authorization bearer credential matching Token.
+type Alertmanager struct {
+	Token string
+}
+
+type alertmanagerPayload struct {
+	Status            string            `json:"status"`
+	GroupKey          string            `json:"groupKey"`
+	ExternalURL       string            `json:"externalURL"`
+	CommonLabels      map[string]string `json:"commonLabels"`
+	CommonAnnotations map[string]string `json:"commonAnnotations"`
+	Alerts            []struct {
+		Status       string            `json:"status"`
+		Labels       map[string]string `json:"labels"`
+		Annotations  map[string]string `json:"annotations"`
+		StartsAt     string            `json:"startsAt"`
+		GeneratorURL string            `json:"generatorURL"`
+		Fingerprint  string            `json:"fingerprint"`
+	} `json:"alerts"`
+}
+
+func (Alertmanager) Name() string { return "alertmanager" }
+
+func (a Alertmanager) Verify(r *http.Request, body []byte) error {
+	return verifyBearer(a.Token, r.Header.Get("Authorization"))
+}
+
+// Parse returns one event per alert; resolved alerts yield Resolved events
+// for the same incident, the alert fingerprint.
+func (Alertmanager) Parse(ctx context.Context, r *http.Request, body []byte) ([]Event, error) {
+	var p alertmanagerPayload
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, err
+	}
+
+	var events []Event
+	for _, alert := range p.Alerts {
+		resolved := alert.Status == "resolved"
+		if alert.Status != "firing" && !resolved {
+			continue
+		}
+		labels := merge(p.CommonLabels, alert.Labels)
+		annotations := merge(p.CommonAnnotations, alert.Annotations)
+
+		desc := "Alert " + labels["alertname"] + " " + alert.Status
+		if svc := firstNonEmpty(labels["service"], labels["job"], labels["app"]); svc != "" {
+			desc += " for " + svc
+		}
+		if s := annotations["summary"]; s != "" {
+			desc += ": " + s
+		}
+
+		var files []string
+		if f := labels["file"]; f != "" {
+			files = append(files, f)
+		}
+
+		// A firing alert is a single observed failure; a resolved one
+		// is that failure followed by a pass.
+		history := []string{"fail"}
+		if resolved {
+			history = append(history, "pass")
+		}
+		id := firstNonEmpty(alert.Fingerprint, p.GroupKey+"/"+labels["alertname"])
+		events = append(events, Event{
+			Source:     "alertmanager",
+			ID:         id,
+			Incident:   "alertmanager:" + id,
+			URL:        firstNonEmpty(alert.GeneratorURL, p.ExternalURL),
+			DetectedAt: timestamp(alert.StartsAt),
+			Input: tools.DetectRegressionInput{
+				Description:  desc,
+				FilesChanged: files,
+				Environment:  normalizeEnvironment(firstNonEmpty(labels["env"], labels["environment"]), "production"),
+				ErrorMessage: firstNonEmpty(annotations["description"], annotations["message"]),
+				RunHistory:   history,
+			},
+			Resolved: resolved,
+		})
+	}
+	return events, nil
+}
+
+// timestamp normalises the first parseable RFC 3339 value to UTC, or
+// returns "" so triage falls back to the current time.
+func timestamp(vals ...string) string {
+	for _, v := range vals {
+		if t, err := time.Parse(time.RFC3339, v); err == nil && !t.IsZero() {
+			return t.UTC().Format(time.RFC3339)
+		}
+	}
+	return ""
+}
+
+func merge(base, over map[string]string) map[string]string {
+	out := make(map[string]string, len(base)+len(over))
+	for k, v := range base {
+		out[k] = v
+	}
+	for k, v := range over {
+		out[k] = v
+	}
+	return out
+}
+
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// normalizeEnvironment maps common environment spellings onto the Fix Fast
+// stages, falling back to def when the value is empty or unrecognised.
+// Stages of the configured CPD model (e.g. a custom "preprod") win over the
+// built-in aliases.
+func normalizeEnvironment(env, def string) string {
+	env = strings.ToLower(strings.TrimSpace(env))
+	for _, name := range tools.CurrentCPDModel().EnvironmentNames() {
+		if strings.EqualFold(name, env) {
+			return name
+		}
+	}
+	switch env {
+	case "prod", "production", "live":
+		return "production"
+	case "stage", "staging", "stg", "preprod":
+		return "staging"
+	case "ci":
+		return "ci"
+	case "dev", "development", "local":
+		return "local_test"
+	}
+	return def
+}
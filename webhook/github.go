@@ -0,0 +1,214 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/emyjamalian/laas-ladybug/tools"
+)
+
+const defaultGitHubAPI = "https://api.github.com"
+
+// GitHubActions adapts GitHub "workflow_run" events. Failed runs are
+// analysed; a successful run resolves the incident of its workflow on its
+// branch.
+// Payloads are verified against Secret via X-Hub-Signature-256. When Token
+// is set, the adapter also fetches the files touched by the head commit and the
+// workflow's recent run history from the REST API.
+type GitHubActions struct {
+	Secret string
+	Token  string
+	// APIURL overrides the REST API base (GitHub Enterprise, tests).
+	APIURL string
+	HTTP   *http.Client
+}
+
+type workflowRunPayload struct {
+	Action      string `json:"action"`
+	WorkflowRun struct {
+		ID           int64  `json:"id"`
+		Name         string `json:"name"`
+		WorkflowID   int64  `json:"workflow_id"`
+		HeadBranch   string `json:"head_branch"`
+		HeadSHA      string `json:"head_sha"`
+		Conclusion   string `json:"conclusion"`
+		HTMLURL      string `json:"html_url"`
+		RunAttempt   int    `json:"run_attempt"`
+		CreatedAt    string `json:"created_at"`
+		UpdatedAt    string `json:"updated_at"`
+		DisplayTitle string `json:"display_title"`
+		HeadCommit   struct {
+			Message string `json:"message"`
+		} `json:"head_commit"`
+	} `json:"workflow_run"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+func (GitHubActions) Name() string { return "github" }
+
+func (g GitHubActions) Verify(r *http.Request, body []byte) error {
+	sig := r.Header.Get("X-Hub-Signature-256")
+	if g.Secret != "" && !strings.HasPrefix(sig, "sha256=") {
+		return fmt.Errorf("%w: missing sha256 signature", ErrSignature)
+	}
+	return verifyHMAC(g.Secret, strings.TrimPrefix(sig, "sha256="), body)
+}
+
+// Parse returns an event for completed workflow runs that failed, and a
+// Resolved one for those that succeeded.
+func (g GitHubActions) Parse(ctx context.Context, r *http.Request, body []byte) ([]Event, error) {
+	if r.Header.Get("X-GitHub-Event") != "workflow_run" {
+		return nil, nil
+	}
+	var p workflowRunPayload
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, err
+	}
+	run := p.WorkflowRun
+	if p.Action != "completed" {
+		return nil, nil
+	}
+	incident := fmt.Sprintf("github:%s/workflows/%d@%s", p.Repository.FullName, run.WorkflowID, run.HeadBranch)
+	id := fmt.Sprintf("%s/runs/%d", p.Repository.FullName, run.ID)
+	// A completed run was last updated when it concluded.
+	detectedAt := timestamp(run.UpdatedAt, run.CreatedAt)
+	switch run.Conclusion {
+	case "failure", "timed_out", "startup_failure":
+	case "success":
+		return []Event{{
+			Source:     "github",
+			ID:         id,
+			Incident:   incident,
+			URL:        run.HTMLURL,
+			DetectedAt: detectedAt,
+			Input: tools.DetectRegressionInput{
+				Description: fmt.Sprintf("GitHub Actions workflow %q succeeded on %s@%s (%s)",
+					run.Name, p.Repository.FullName, run.HeadBranch, shortSHA(run.HeadSHA)),
+				Environment: "ci",
+				// Clears the incident if an earlier run of the
+				// workflow on this branch failed.
+				RunHistory: []string{"fail", "pass"},
+			},
+			Resolved: true,
+		}}, nil
+	default:
+		return nil, nil
+	}
+
+	in := tools.DetectRegressionInput{
+		Description: fmt.Sprintf("GitHub Actions workflow %q %s on %s@%s (%s): %s",
+			run.Name, strings.ReplaceAll(run.Conclusion, "_", " "),
+			p.Repository.FullName, run.HeadBranch, shortSHA(run.HeadSHA), run.DisplayTitle),
+		Environment:  "ci",
+		ErrorMessage: fmt.Sprintf("Workflow run %d attempt %d concluded %s", run.ID, run.RunAttempt, run.Conclusion),
+		RunHistory:   []string{"fail"},
+	}
+
+	// Enrichment is best-effort: a failed API call still yields an event.
+	if g.Token != "" {
+		if files, err := g.commitFiles(ctx, p.Repository.FullName, run.HeadSHA); err == nil {
+			in.FilesChanged = files
+		}
+		if history, err := g.runHistory(ctx, p.Repository.FullName, run.WorkflowID, run.HeadBranch, run.ID); err == nil {
+			in.RunHistory = append(history, "fail")
+		}
+	}
+
+	return []Event{{
+		Source:     "github",
+		ID:         id,
+		Incident:   incident,
+		URL:        run.HTMLURL,
+		DetectedAt: detectedAt,
+		Input:      in,
+	}}, nil
+}
+
+// commitFiles lists the files touched by sha.
+func (g GitHubActions) commitFiles(ctx context.Context, repo, sha string) ([]string, error) {
+	var commit struct {
+		Files []struct {
+			Filename string `json:"filename"`
+		} `json:"files"`
+	}
+	if err := g.get(ctx, fmt.Sprintf("/repos/%s/commits/%s", repo, sha), &commit); err != nil {
+		return nil, err
+	}
+	files := make([]string, 0, len(commit.Files))
+	for _, f := range commit.Files {
+		files = append(files, f.Filename)
+	}
+	return files, nil
+}
+
+// runHistory returns up to nine earlier completed runs of the workflow on the
+// same branch as pass/fail, oldest first, excluding the current run.
+func (g GitHubActions) runHistory(ctx context.Context, repo string, workflowID int64, branch string, current int64) ([]string, error) {
+	var runs struct {
+		WorkflowRuns []struct {
+			ID         int64  `json:"id"`
+			Conclusion string `json:"conclusion"`
+		} `json:"workflow_runs"`
+	}
+	path := fmt.Sprintf("/repos/%s/actions/workflows/%d/runs?status=completed&per_page=10&branch=%s",
+		repo, workflowID, url.QueryEscape(branch))
+	if err := g.get(ctx, path, &runs); err != nil {
+		return nil, err
+	}
+	var history []string
+	// The API lists newest first.
+	for i := len(runs.WorkflowRuns) - 1; i >= 0; i-- {
+		wr := runs.WorkflowRuns[i]
+		switch {
+		case wr.ID == current:
+		case wr.Conclusion == "success":
+			history = append(history, "pass")
+		case wr.Conclusion == "failure" || wr.Conclusion == "timed_out":
+			history = append(history, "fail")
+		}
+	}
+	if len(history) > 9 {
+		history = history[len(history)-9:]
+	}
+	return history, nil
+}
+
+func (g GitHubActions) get(ctx context.Context, path string, out interface{}) error {
+	base := g.APIURL
+	if base == "" {
+		base = defaultGitHubAPI
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(base, "/")+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+g.Token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	client := g.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GitHub API %s returned status %d", path, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
@@ -0,0 +1,175 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/emyjamalian/laas-ladybug/tools"
+)
+
+// Sentry adapts Sentry integration-platform webhooks for the "issue" and
+// "event_alert" resources. Payloads are verified against the integration's
+// client secret via the Sentry-Hook-Signature header.
+type Sentry struct {
+	ClientSecret string
+}
+
+type sentryFrame struct {
+	Filename string `json:"filename"`
+	Function string `json:"function"`
+	LineNo   int    `json:"lineno"`
+	InApp    bool   `json:"in_app"`
+}
+
+type sentryPayload struct {
+	Action string `json:"action"`
+	Data   struct {
+		Issue *struct {
+			ID        string `json:"id"`
+			Title     string `json:"title"`
+			Culprit   string `json:"culprit"`
+			Permalink string `json:"permalink"`
+			Level     string `json:"level"`
+			UserCount int    `json:"userCount"`
+			FirstSeen string `json:"firstSeen"`
+			Metadata  struct {
+				Type     string `json:"type"`
+				Value    string `json:"value"`
+				Filename string `json:"filename"`
+				Function string `json:"function"`
+			} `json:"metadata"`
+		} `json:"issue"`
+		Event *struct {
+			EventID     string     `json:"event_id"`
+			IssueID     string     `json:"issue_id"`
+			Title       string     `json:"title"`
+			Culprit     string     `json:"culprit"`
+			DateTime    string     `json:"datetime"`
+			WebURL      string     `json:"web_url"`
+			Environment string     `json:"environment"`
+			Tags        [][]string `json:"tags"`
+			Exception   struct {
+				Values []struct {
+					Type       string `json:"type"`
+					Value      string `json:"value"`
+					Stacktrace struct {
+						Frames []sentryFrame `json:"frames"`
+					} `json:"stacktrace"`
+				} `json:"values"`
+			} `json:"exception"`
+		} `json:"event"`
+	} `json:"data"`
+}
+
+func (Sentry) Name() string { return "sentry" }
+
+func (s Sentry) Verify(r *http.Request, body []byte) error {
+	return verifyHMAC(s.ClientSecret, r.Header.Get("Sentry-Hook-Signature"), body)
+}
+
+// Parse handles created, unresolved and resolved issues and triggered issue
+// alerts. Events belong to the incident of their Sentry issue.
+func (Sentry) Parse(ctx context.Context, r *http.Request, body []byte) ([]Event, error) {
+	var p sentryPayload
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, err
+	}
+
+	switch r.Header.Get("Sentry-Hook-Resource") {
+	case "issue":
+		issue := p.Data.Issue
+		if issue == nil {
+			return nil, nil
+		}
+		history := []string{"fail"}
+		switch p.Action {
+		case "created", "unresolved":
+		case "resolved":
+			history = append(history, "pass")
+		default:
+			return nil, nil
+		}
+		var files []string
+		if issue.Metadata.Filename != "" {
+			files = append(files, issue.Metadata.Filename)
+		}
+		errMsg := strings.TrimSpace(issue.Metadata.Type + ": " + issue.Metadata.Value)
+		if issue.Metadata.Function != "" {
+			errMsg += "\n  in " + issue.Metadata.Function
+		}
+		return []Event{{
+			Source:        "sentry",
+			ID:            issue.ID,
+			Incident:      "sentry:" + issue.ID,
+			URL:           issue.Permalink,
+			AffectedUsers: issue.UserCount,
+			DetectedAt:    timestamp(issue.FirstSeen),
+			Resolved:      p.Action == "resolved",
+			Input: tools.DetectRegressionInput{
+				Description:  fmt.Sprintf("Sentry %s issue %q in %s", issue.Level, issue.Title, issue.Culprit),
+				FilesChanged: files,
+				// Issue payloads carry no environment; Sentry is
+				// overwhelmingly wired to production.
+				Environment:  "production",
+				ErrorMessage: errMsg,
+				RunHistory:   history,
+			},
+		}}, nil
+
+	case "event_alert":
+		ev := p.Data.Event
+		if ev == nil {
+			return nil, nil
+		}
+		env := ev.Environment
+		for _, tag := range ev.Tags {
+			if len(tag) == 2 && tag[0] == "environment" && env == "" {
+				env = tag[1]
+			}
+		}
+		var trace strings.Builder
+		var files []string
+		for _, exc := range ev.Exception.Values {
+			fmt.Fprintf(&trace, "%s: %s\n", exc.Type, exc.Value)
+			// Sentry orders frames oldest-call-first; print innermost first.
+			frames := exc.Stacktrace.Frames
+			for i := len(frames) - 1; i >= 0; i-- {
+				f := frames[i]
+				fmt.Fprintf(&trace, "  at %s (%s:%d)\n", f.Function, f.Filename, f.LineNo)
+				if f.InApp && f.Filename != "" {
+					files = append(files, f.Filename)
+				}
+			}
+		}
+		return []Event{{
+			Source:     "sentry",
+			ID:         ev.EventID,
+			Incident:   "sentry:" + firstNonEmpty(ev.IssueID, ev.EventID),
+			URL:        ev.WebURL,
+			DetectedAt: timestamp(ev.DateTime),
+			Input: tools.DetectRegressionInput{
+				Description:  fmt.Sprintf("Sentry alert %q in %s", ev.Title, ev.Culprit),
+				FilesChanged: dedupe(files),
+				Environment:  normalizeEnvironment(env, "production"),
+				ErrorMessage: strings.TrimSpace(trace.String()),
+				RunHistory:   []string{"fail"},
+			},
+		}}, nil
+	}
+	return nil, nil
+}
+
+func dedupe(s []string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, v := range s {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
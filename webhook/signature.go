@@ -0,0 +1,44 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// verifyHMAC checks a hex-encoded HMAC-SHA256 of body against signature.
+// Without a secret nothing verifies; see Insecure.
+func verifyHMAC(secret, signature string, body []byte) error {
+	if secret == "" {
+		return fmt.Errorf("%w: no secret configured", ErrSignature)
+	}
+	if signature == "" {
+		return fmt.Errorf("%w: missing signature header", ErrSignature)
+	}
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("%w: malformed signature", ErrSignature)
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return fmt.Errorf("%w: signature mismatch", ErrSignature)
+	}
+	return nil
+}
+
+// verifyBearer checks an "Authorization: Bearer <token>" header.
+// Without a token nothing verifies; see Insecure.
+func verifyBearer(token, header string) error {
+	if token == "" {
+		return fmt.Errorf("%w: no token configured", ErrSignature)
+	}
+	got := strings.TrimPrefix(header, "Bearer ")
+	if got == header || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+		return fmt.Errorf("%w: invalid bearer token", ErrSignature)
+	}
+	return nil
+}
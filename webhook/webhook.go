@@ -0,0 +1,208 @@
+// Package webhook turns inbound alerting and CI webhooks into Fix Fast analyses.
+//
+// Each Adapter verifies and parses one source's payload into Events carrying a
+// tools.DetectRegressionInput, so an analysis can start without a human having
+// to describe the bug first.
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/emyjamalian/laas-ladybug/agent"
+	"github.com/emyjamalian/laas-ladybug/tools"
+)
+
+// maxBodyBytes caps the size of an inbound webhook payload.
+const maxBodyBytes = 1 << 20
+
+// maxQueuedEvents bounds the events waiting for a worker; webhooks that
+// would overflow the queue are refused with 503 so the sender retries.
+const maxQueuedEvents = 64
+
+// ErrSignature is returned by Adapter.Verify when a payload is not authentic.
+var ErrSignature = errors.New("webhook signature verification failed")
+
+// Event is a single regression report extracted from a webhook payload.
+type Event struct {
+	Source string `json:"source"`
+	ID     string `json:"id"`
+	// Incident identifies the failure at its source across payloads: an
+	// alert fingerprint, a Sentry issue, a workflow on a branch. A resolved
+	// event carries the incident of the failures it clears.
+	Incident      string `json:"incident"`
+	URL           string `json:"url,omitempty"`
+	AffectedUsers int    `json:"affected_users,omitempty"`
+	// DetectedAt is when the source first saw the failure, RFC 3339 in
+	// UTC; it drives time-aware triage.
+	DetectedAt string                      `json:"detected_at,omitempty"`
+	Input      tools.DetectRegressionInput `json:"input"`
+	// Resolved marks a payload saying the failure has cleared (a resolved
+	// alert or issue, a successful run). Its run history ends in "pass".
+	Resolved bool `json:"resolved,omitempty"`
+}
+
+// Prompt renders the event as agent input.
+func (e Event) Prompt() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Automated report from %s (id %s).\n", e.Source, e.ID)
+	if e.URL != "" {
+		fmt.Fprintf(&b, "Link: %s\n", e.URL)
+	}
+	if e.AffectedUsers > 0 {
+		fmt.Fprintf(&b, "Affected users: %d\n", e.AffectedUsers)
+	}
+	if e.DetectedAt != "" {
+		fmt.Fprintf(&b, "Detected at: %s (pass it to triage_issue as detected_at)\n", e.DetectedAt)
+	}
+	b.WriteString("\n")
+	b.WriteString(agent.FormatInput(e.Input))
+	return b.String()
+}
+
+// Adapter verifies and parses the payloads of one webhook source.
+type Adapter interface {
+	// Name identifies the source; it is also the last path segment the
+	// adapter is mounted on (e.g. /webhooks/sentry).
+	Name() string
+	// Verify authenticates the request. Implementations return ErrSignature
+	// (possibly wrapped) when the payload must be rejected, including when
+	// they have no secret to check it with.
+	Verify(r *http.Request, body []byte) error
+	// Parse extracts zero or more events. Payloads saying a failure has
+	// cleared (resolved alerts, successful runs) yield Resolved events;
+	// those that describe neither yield none.
+	Parse(ctx context.Context, r *http.Request, body []byte) ([]Event, error)
+}
+
+// Insecure wraps a so its payloads are accepted without authentication,
+// for sources that cannot sign their requests on a trusted network. Any
+// client that can reach the endpoint can then start (paid) analyses.
+func Insecure(a Adapter) Adapter {
+	return insecure{a}
+}
+
+type insecure struct{ Adapter }
+
+func (insecure) Verify(*http.Request, []byte) error { return nil }
+
+// Handler serves the webhook endpoints and triggers an analysis per event.
+type Handler struct {
+	adapters map[string]Adapter
+	trigger  func(context.Context, Event)
+
+	mu    sync.Mutex // serialises enqueueing a payload's events
+	queue chan Event
+}
+
+// NewHandler returns a Handler that calls trigger for every parsed event.
+// Events are queued so the webhook can be acknowledged before the (slow)
+// analysis finishes; Run processes them.
+func NewHandler(trigger func(context.Context, Event), adapters ...Adapter) *Handler {
+	h := &Handler{
+		adapters: make(map[string]Adapter),
+		trigger:  trigger,
+		queue:    make(chan Event, maxQueuedEvents),
+	}
+	for _, a := range adapters {
+		h.adapters[a.Name()] = a
+	}
+	return h
+}
+
+// Run calls trigger for queued events on workers goroutines until ctx is
+// done, then waits for the running calls, which get ctx, to return.
+func (h *Handler) Run(ctx context.Context, workers int) {
+	var wg sync.WaitGroup
+	for range max(workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case ev := <-h.queue:
+					h.trigger(ctx, ev)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// enqueue queues all of events, or none of them if they do not fit.
+func (h *Handler) enqueue(events []Event) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(events) > cap(h.queue)-len(h.queue) {
+		return false
+	}
+	for _, ev := range events {
+		h.queue <- ev
+	}
+	return true
+}
+
+// Register mounts the adapters on mux under /webhooks/<name>.
+func (h *Handler) Register(mux *http.ServeMux) {
+	for name := range h.adapters {
+		mux.Handle("/webhooks/"+name, h)
+	}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	adapter, ok := h.adapters[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, fmt.Sprintf("payload exceeds %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		http.Error(w, "read body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := adapter.Verify(r, body); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	events, err := adapter.Parse(r.Context(), r, body)
+	if err != nil {
+		http.Error(w, "parse payload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !h.enqueue(events) {
+		w.Header().Set("Retry-After", "60")
+		http.Error(w, "too many analyses queued, retry later", http.StatusServiceUnavailable)
+		return
+	}
+	ids := make([]string, 0, len(events))
+	for _, ev := range events {
+		ids = append(ids, ev.ID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"source":   name,
+		"accepted": ids,
+	})
+}
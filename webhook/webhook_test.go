@@ -0,0 +1,288 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/emyjamalian/laas-ladybug/tools"
+)
+
+func fixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func parse(t *testing.T, a Adapter, body []byte, header http.Header) []Event {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodPost, "/webhooks/"+a.Name(), bytes.NewReader(body))
+	for k, v := range header {
+		r.Header[k] = v
+	}
+	events, err := a.Parse(context.Background(), r, body)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	return events
+}
+
+func TestAlertmanagerParse(t *testing.T) {
+	events := parse(t, Alertmanager{}, fixture(t, "alertmanager.json"), nil)
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want one firing and one resolved", len(events))
+	}
+	ev := events[0]
+	if ev.ID != "c0ffee1234" || ev.Incident != "alertmanager:c0ffee1234" || ev.Resolved {
+		t.Errorf("event = %+v, want the firing alert keyed by its fingerprint", ev)
+	}
+	if ev.DetectedAt != "2026-10-18T09:30:00Z" {
+		t.Errorf("DetectedAt = %q, want the alert's startsAt", ev.DetectedAt)
+	}
+	if !strings.Contains(ev.Prompt(), "Detected at: 2026-10-18T09:30:00Z") {
+		t.Errorf("Prompt does not carry the detection time:\n%s", ev.Prompt())
+	}
+	want := tools.DetectRegressionInput{
+		Description:  "Alert HighErrorRate firing for auth-service: 5xx rate above 5%",
+		FilesChanged: []string{"auth/login.go"},
+		Environment:  "production",
+		ErrorMessage: "panic: runtime error: invalid memory address or nil pointer dereference",
+		RunHistory:   []string{"fail"},
+	}
+	if !reflect.DeepEqual(ev.Input, want) {
+		t.Errorf("Input = %+v\nwant %+v", ev.Input, want)
+	}
+
+	resolved := events[1]
+	if !resolved.Resolved || resolved.Incident != "alertmanager:beef5678" {
+		t.Errorf("resolved event = %+v", resolved)
+	}
+	if got := resolved.Input.RunHistory; !reflect.DeepEqual(got, []string{"fail", "pass"}) {
+		t.Errorf("resolved RunHistory = %v, want a failure then a pass", got)
+	}
+}
+
+func TestSentryParseIssue(t *testing.T) {
+	events := parse(t, Sentry{}, fixture(t, "sentry_issue.json"), http.Header{"Sentry-Hook-Resource": {"issue"}})
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1", len(events))
+	}
+	ev := events[0]
+	if ev.ID != "4711" || ev.Incident != "sentry:4711" || ev.AffectedUsers != 240 || ev.URL != "https://sentry.example.com/issues/4711/" {
+		t.Errorf("event = %+v", ev)
+	}
+	if ev.DetectedAt != "2026-10-18T09:12:00Z" {
+		t.Errorf("DetectedAt = %q, want the issue's firstSeen", ev.DetectedAt)
+	}
+	if got := ev.Input.FilesChanged; !reflect.DeepEqual(got, []string{"checkout/cart.js"}) {
+		t.Errorf("FilesChanged = %v", got)
+	}
+	if want := "TypeError: Cannot read properties of undefined (reading 'id')\n  in applyCoupon"; ev.Input.ErrorMessage != want {
+		t.Errorf("ErrorMessage = %q, want %q", ev.Input.ErrorMessage, want)
+	}
+}
+
+func TestSentryParseEventAlert(t *testing.T) {
+	events := parse(t, Sentry{}, fixture(t, "sentry_event_alert.json"), http.Header{"Sentry-Hook-Resource": {"event_alert"}})
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1", len(events))
+	}
+	if events[0].Incident != "sentry:4711" {
+		t.Errorf("Incident = %q, want the event's issue", events[0].Incident)
+	}
+	if events[0].DetectedAt != "2026-10-18T09:15:00Z" {
+		t.Errorf("DetectedAt = %q, want the event's datetime", events[0].DetectedAt)
+	}
+	in := events[0].Input
+	if in.Environment != "staging" {
+		t.Errorf("Environment = %q, want the environment tag", in.Environment)
+	}
+	if !reflect.DeepEqual(in.FilesChanged, []string{"src/OrderService.java"}) {
+		t.Errorf("FilesChanged = %v, want in-app frames only", in.FilesChanged)
+	}
+	want := "NullPointerException: order.customer is null\n" +
+		"  at place (src/OrderService.java:42)\n" +
+		"  at dispatch (vendor/Framework.java:10)"
+	if in.ErrorMessage != want {
+		t.Errorf("ErrorMessage = %q, want innermost frame first: %q", in.ErrorMessage, want)
+	}
+}
+
+func TestSentryResolvedIssue(t *testing.T) {
+	body := bytes.Replace(fixture(t, "sentry_issue.json"), []byte(`"created"`), []byte(`"resolved"`), 1)
+	events := parse(t, Sentry{}, body, http.Header{"Sentry-Hook-Resource": {"issue"}})
+	if len(events) != 1 || !events[0].Resolved || events[0].Incident != "sentry:4711" {
+		t.Fatalf("events = %+v, want one resolved event for issue 4711", events)
+	}
+}
+
+func TestSentryIgnoresOtherResources(t *testing.T) {
+	if events := parse(t, Sentry{}, fixture(t, "sentry_issue.json"), http.Header{"Sentry-Hook-Resource": {"installation"}}); len(events) != 0 {
+		t.Errorf("got %d events for an installation hook", len(events))
+	}
+}
+
+func TestGitHubActionsParse(t *testing.T) {
+	body := fixture(t, "github_workflow_run.json")
+	events := parse(t, GitHubActions{}, body, http.Header{"X-Github-Event": {"workflow_run"}})
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1", len(events))
+	}
+	ev := events[0]
+	if ev.ID != "acme/shop/runs/123456" || ev.Incident != "github:acme/shop/workflows/42@main" || ev.Resolved {
+		t.Errorf("event = %+v", ev)
+	}
+	if ev.DetectedAt != "2026-10-18T09:07:00Z" {
+		t.Errorf("DetectedAt = %q, want when the run concluded", ev.DetectedAt)
+	}
+	if ev.Input.Environment != "ci" || !strings.Contains(ev.Input.Description, `workflow "CI" failure on acme/shop@main (abcdef1)`) {
+		t.Errorf("Input = %+v", ev.Input)
+	}
+
+	if events := parse(t, GitHubActions{}, body, http.Header{"X-Github-Event": {"push"}}); len(events) != 0 {
+		t.Errorf("got %d events for a push event", len(events))
+	}
+
+	success := bytes.Replace(body, []byte(`"failure"`), []byte(`"success"`), 1)
+	events = parse(t, GitHubActions{}, success, http.Header{"X-Github-Event": {"workflow_run"}})
+	if len(events) != 1 || !events[0].Resolved || events[0].Incident != ev.Incident {
+		t.Errorf("success events = %+v, want one resolving %s", events, ev.Incident)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	body := fixture(t, "github_workflow_run.json")
+	req := func(header, value string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		if header != "" {
+			r.Header.Set(header, value)
+		}
+		return r
+	}
+	tests := []struct {
+		name    string
+		adapter Adapter
+		r       *http.Request
+		ok      bool
+	}{
+		{"sentry valid", Sentry{ClientSecret: "s3cret"}, req("Sentry-Hook-Signature", sign("s3cret", body)), true},
+		{"sentry wrong secret", Sentry{ClientSecret: "s3cret"}, req("Sentry-Hook-Signature", sign("other", body)), false},
+		{"sentry malformed", Sentry{ClientSecret: "s3cret"}, req("Sentry-Hook-Signature", "zz"), false},
+		{"sentry missing", Sentry{ClientSecret: "s3cret"}, req("", ""), false},
+		{"sentry no secret", Sentry{}, req("Sentry-Hook-Signature", sign("", body)), false},
+		{"github valid", GitHubActions{Secret: "s3cret"}, req("X-Hub-Signature-256", "sha256="+sign("s3cret", body)), true},
+		{"github no prefix", GitHubActions{Secret: "s3cret"}, req("X-Hub-Signature-256", sign("s3cret", body)), false},
+		{"github no secret", GitHubActions{}, req("", ""), false},
+		{"alertmanager valid", Alertmanager{Token: "tok"}, req("Authorization", "Bearer tok"), true},
+		{"alertmanager wrong", Alertmanager{Token: "tok"}, req("Authorization", "Bearer nope"), false},
+		{"alertmanager not bearer", Alertmanager{Token: "tok"}, req("Authorization", "tok"), false},
+		{"alertmanager no token", Alertmanager{}, req("", ""), false},
+		{"insecure", Insecure(Alertmanager{}), req("", ""), true},
+	}
+	for _, tt := range tests {
+		err := tt.adapter.Verify(tt.r, body)
+		if tt.ok && err != nil {
+			t.Errorf("%s: Verify = %v, want nil", tt.name, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrSignature) {
+			t.Errorf("%s: Verify = %v, want ErrSignature", tt.name, err)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	h := NewHandler(func(context.Context, Event) {}, Alertmanager{Token: "tok"})
+	mux := http.NewServeMux()
+	h.Register(mux)
+	body := fixture(t, "alertmanager.json")
+
+	post := func(token string, body []byte) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/webhooks/alertmanager", bytes.NewReader(body))
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+	if w := post("nope", body); w.Code != http.StatusUnauthorized {
+		t.Errorf("bad token: status %d, want 401", w.Code)
+	}
+	if w := post("tok", bytes.Repeat([]byte(" "), maxBodyBytes+1)); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized body: status %d, want 413", w.Code)
+	}
+	if w := post("tok", body); w.Code != http.StatusAccepted || !strings.Contains(w.Body.String(), "c0ffee1234") {
+		t.Errorf("valid payload: status %d, body %s", w.Code, w.Body)
+	}
+	if len(h.queue) != 2 {
+		t.Errorf("%d events queued, want 2", len(h.queue))
+	}
+
+	// With no worker running the queue fills up and later payloads are
+	// refused whole.
+	for len(h.queue)+2 <= maxQueuedEvents {
+		if w := post("tok", body); w.Code != http.StatusAccepted {
+			t.Fatalf("status %d with %d events queued", w.Code, len(h.queue))
+		}
+	}
+	if w := post("tok", body); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("full queue: status %d, want 503", w.Code)
+	}
+}
+
+func TestHandlerRun(t *testing.T) {
+	got := make(chan Event, 1)
+	h := NewHandler(func(_ context.Context, ev Event) { got <- ev }, Insecure(Alertmanager{}))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		h.Run(ctx, 2)
+		close(done)
+	}()
+	if !h.enqueue([]Event{{ID: "x"}}) {
+		t.Fatal("enqueue refused an event on an empty queue")
+	}
+	if ev := <-got; ev.ID != "x" {
+		t.Errorf("triggered %q, want x", ev.ID)
+	}
+	cancel()
+	<-done
+}
+
+func TestNormalizeEnvironment(t *testing.T) {
+	for env, want := range map[string]string{
+		"prod": "production", " Staging ": "staging", "preprod": "staging", "dev": "local_test", "": "production", "qa-7": "production",
+	} {
+		if got := normalizeEnvironment(env, "production"); got != want {
+			t.Errorf("normalizeEnvironment(%q) = %q, want %q", env, got, want)
+		}
+	}
+
+	// A custom stage in the CPD model wins over the built-in aliases.
+	defer tools.SetCPDModel(tools.DefaultCPDModel())
+	m := tools.DefaultCPDModel()
+	m.Environments["preprod"] = tools.EnvironmentStage{Multiplier: 50, ShiftLeft: "staging"}
+	if err := tools.SetCPDModel(m); err != nil {
+		t.Fatal(err)
+	}
+	if got := normalizeEnvironment("PreProd", "production"); got != "preprod" {
+		t.Errorf("normalizeEnvironment(PreProd) = %q, want the custom preprod stage", got)
+	}
+}